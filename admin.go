@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/admin"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AdminHttpServer serves the admin endpoints on their own listener, so they
+// are never exposed through the API address
+type AdminHttpServer struct {
+	cfg    config
+	server *http.Server
+}
+
+func NewAdminHttpServer(cfg config, started time.Time) *AdminHttpServer {
+	handler := admin.New(admin.Options{
+		Config:  cfg.redacted(),
+		Token:   cfg.Admin.Token,
+		Started: started,
+	})
+
+	return &AdminHttpServer{
+		cfg: cfg,
+		server: &http.Server{
+			Addr:              cfg.Admin.Addr(),
+			Handler:           handler.Router(),
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			// No WriteTimeout: profiles and traces stream for up to their
+			// requested duration
+		},
+	}
+}
+
+// start listens on the admin address and serves in the background
+func (s *AdminHttpServer) start() error {
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", s.server.Addr, err)
+	}
+
+	go func() {
+		if err := s.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Admin server error")
+		}
+	}()
+
+	log.Info().Str("address", s.server.Addr).Msg("Admin server started")
+	return nil
+}
+
+// stop shuts the admin server down
+func (s *AdminHttpServer) stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
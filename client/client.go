@@ -0,0 +1,323 @@
+// Package client is a typed Go client for the service API. It signs requests
+// with the X-API-KEY, X-ACCESS-TIME and X-REQUEST-SIGNATURE headers checked by
+// the middlewares, retries failed requests with backoff and decodes
+// application/problem+json errors.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/google/uuid"
+)
+
+// Config configures a Client
+type Config struct {
+	// BaseURL is the URL of the API including its version, such as
+	// http://localhost:8080/v1
+	BaseURL string
+	// APIKey signs the requests, which are left unsigned when empty
+	APIKey string
+	// Salt is the server salt shared with the service, used for the request
+	// signature
+	Salt string
+	// Identity is sent as X-REQUEST-IDENTITY, the hostname when empty
+	Identity string
+	// HTTPClient sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+	// MaxRetries is the number of retries of a failed request, 0 for the
+	// default and a negative value to disable retries
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the delay between retries, which grows
+	// exponentially with jitter unless the response has a Retry-After header
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultConfig returns a configuration with the default retry policy
+func DefaultConfig() Config {
+	return Config{
+		BaseURL:    "http://localhost:8080/v1",
+		MaxRetries: 3,
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 5 * time.Second,
+	}
+}
+
+// Client calls the service API
+type Client struct {
+	config  Config
+	baseURL *url.URL
+	http    *http.Client
+
+	// Users manages users
+	Users *UsersService
+	// Messaging publishes messages
+	Messaging *MessagingService
+}
+
+// New creates a client
+func New(config Config) (*Client, error) {
+	// Apply default config values where needed
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultConfig().MaxRetries
+	}
+	if config.MinBackoff == 0 {
+		config.MinBackoff = DefaultConfig().MinBackoff
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = DefaultConfig().MaxBackoff
+	}
+	if config.Identity == "" {
+		config.Identity, _ = os.Hostname()
+	}
+
+	baseURL, err := url.Parse(strings.TrimSuffix(config.BaseURL, "/"))
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", config.BaseURL)
+	}
+
+	c := &Client{
+		config:  config,
+		baseURL: baseURL,
+		http:    config.HTTPClient,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	c.Users = &UsersService{client: c}
+	c.Messaging = &MessagingService{client: c}
+	return c, nil
+}
+
+// Error is returned for responses with an error status, with the problem
+// details decoded from the body
+type Error struct {
+	problem.Problem
+	// RetryAfter is the delay requested by the Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", e.Status, e.Title, e.Detail)
+	}
+	return fmt.Sprintf("%d %s", e.Status, e.Title)
+}
+
+// IsStatus reports whether err is an *Error with the given status
+func IsStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Status == status
+}
+
+// request describes a call to the API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body is encoded as JSON when not nil
+	body any
+}
+
+// envelope is the JSON body of successful responses, see utils.Response
+type envelope struct {
+	Status  int             `json:"status"`
+	Message string          `json:"message,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// do sends req, retrying when it is safe, and decodes the data of the
+// response into out when out is not nil. It returns the response headers.
+func (c *Client) do(ctx context.Context, req request, out any) (http.Header, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("encoding request body: %w", err)
+		}
+	}
+
+	// The key lets the service replay the response of a POST that is
+	// retried after the first attempt succeeded
+	header := req.header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if req.method == http.MethodPost && header.Get("Idempotency-Key") == "" {
+		header.Set("Idempotency-Key", uuid.NewString())
+	}
+
+	u := c.baseURL.JoinPath(req.path)
+	u.RawQuery = req.query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req.method, u.String(), header, body)
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, err
+			}
+		case resp.StatusCode < http.StatusBadRequest:
+			defer resp.Body.Close()
+			return resp.Header, decode(resp, out)
+		default:
+			apiErr := decodeError(resp)
+			resp.Body.Close()
+			if !retryableStatus(apiErr.Status) {
+				return nil, apiErr
+			}
+			err, delay = apiErr, apiErr.RetryAfter
+		}
+
+		if attempt >= c.config.MaxRetries || !retryableMethod(req.method, header) {
+			return nil, err
+		}
+		if delay == 0 {
+			delay = c.backoff(attempt)
+		}
+		select {
+		case <-time.After(min(delay, c.config.MaxBackoff)):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting to retry %s %s: %w", req.method, req.path, ctx.Err())
+		}
+	}
+}
+
+// send sends a single signed request
+func (c *Client) send(ctx context.Context, method, u string, header http.Header, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	httpReq.Header = header.Clone()
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	c.sign(httpReq.Header, time.Now())
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, u, err)
+	}
+	return resp, nil
+}
+
+// sign sets the headers checked by the ApiKey, AccessTime and
+// RequestSignature middlewares. The signature is the hex SHA-256 of the salt,
+// access time and API key.
+func (c *Client) sign(header http.Header, now time.Time) {
+	if c.config.APIKey == "" {
+		return
+	}
+
+	accessTime := strconv.FormatInt(now.Unix(), 10)
+	signature := sha256.Sum256([]byte(c.config.Salt + accessTime + c.config.APIKey))
+
+	header.Set("X-API-KEY", c.config.APIKey)
+	header.Set("X-ACCESS-TIME", accessTime)
+	header.Set("X-REQUEST-SIGNATURE", hex.EncodeToString(signature[:]))
+	if c.config.Identity != "" {
+		header.Set("X-REQUEST-IDENTITY", c.config.Identity)
+	}
+}
+
+// backoff returns the delay before a retry: exponential with full jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := min(c.config.MinBackoff<<attempt, c.config.MaxBackoff)
+	if ceiling <= 0 {
+		return c.config.MinBackoff
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(ceiling)))
+	if err != nil {
+		return ceiling
+	}
+	return max(time.Duration(n.Int64()), c.config.MinBackoff)
+}
+
+// retryableStatus reports whether a response status is transient
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryableMethod reports whether a request can be sent again. POST requests
+// are safe thanks to their Idempotency-Key.
+func retryableMethod(method string, header http.Header) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return header.Get("Idempotency-Key") != ""
+	default:
+		return false
+	}
+}
+
+// decode decodes the data of a successful response into out
+func decode(resp *http.Response, out any) error {
+	if out == nil || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if len(env.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("decoding response data: %w", err)
+	}
+	return nil
+}
+
+// decodeError decodes a problem response. Bodies that are not problems, such
+// as errors from a proxy, keep the status and its text.
+func decodeError(resp *http.Response) *Error {
+	apiErr := &Error{}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(body, &apiErr.Problem); err != nil || apiErr.Status == 0 {
+		apiErr.Problem = problem.Problem{Detail: strings.TrimSpace(string(body))}
+	}
+	apiErr.Status = resp.StatusCode
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(resp.StatusCode)
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
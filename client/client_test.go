@@ -0,0 +1,260 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/features/user"
+	"github.com/LexiconIndonesia/go-http-service-template/middlewares"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	testAPIKey = "client-key"
+	testSalt   = "client-salt"
+)
+
+// newTestServer serves the user endpoints behind the authentication
+// middlewares
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	hashed := sha256.Sum256([]byte(testSalt + testAPIKey))
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Use(middlewares.AccessTime())
+		r.Use(middlewares.ApiKey(hex.EncodeToString(hashed[:]), testSalt))
+		r.Use(middlewares.RequestSignature(testSalt))
+		r.Mount("/users", user.NewUser(db.NewFake(), nil).Router())
+	})
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(t *testing.T, baseURL string) *Client {
+	t.Helper()
+
+	c, err := New(Config{
+		BaseURL:    baseURL + "/v1",
+		APIKey:     testAPIKey,
+		Salt:       testSalt,
+		Identity:   "client-test",
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c
+}
+
+func TestUsers(t *testing.T) {
+	server := newTestServer(t)
+	c := newTestClient(t, server.URL)
+	ctx := context.Background()
+
+	created, err := c.Users.Create(ctx, CreateUserRequest{
+		Email:     "jane@example.com",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Password:  "Password123!",
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created.ID == "" || created.ETag == "" {
+		t.Fatalf("Create() returned user without ID or ETag: %+v", created)
+	}
+
+	got, err := c.Users.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Email != "jane@example.com" || got.FirstName != "Jane" {
+		t.Errorf("Get() returned wrong user: %+v", got)
+	}
+
+	lastName := "Smith"
+	updated, err := c.Users.Update(ctx, created.ID, UpdateUserRequest{LastName: &lastName, IfMatch: got.ETag})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.LastName != "Smith" {
+		t.Errorf("Update() returned wrong last name: got %v want %v", updated.LastName, "Smith")
+	}
+
+	// The ETag is stale after the update
+	err = c.Users.Delete(ctx, created.ID, got.ETag)
+	if !IsStatus(err, http.StatusPreconditionFailed) {
+		t.Fatalf("Delete() with stale ETag error = %v, want status %v", err, http.StatusPreconditionFailed)
+	}
+	if err := c.Users.Delete(ctx, created.ID, updated.ETag); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	_, err = c.Users.Get(ctx, created.ID)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Get() of deleted user error = %v, want *Error", err)
+	}
+	if apiErr.Status != http.StatusNotFound || apiErr.Detail != "User not found" {
+		t.Errorf("Get() of deleted user returned wrong problem: %+v", apiErr.Problem)
+	}
+}
+
+func TestUsersAll(t *testing.T) {
+	server := newTestServer(t)
+	c := newTestClient(t, server.URL)
+	ctx := context.Background()
+
+	const total = 12
+	for i := range total {
+		_, err := c.Users.Create(ctx, CreateUserRequest{
+			Email:     fmt.Sprintf("user%d@example.com", i),
+			FirstName: "User",
+			LastName:  fmt.Sprint(i),
+			Password:  "Password123!",
+		})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	page, err := c.Users.List(ctx, ListUsersOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(page.Items) != 5 || page.Meta.NextCursor == "" {
+		t.Fatalf("List() returned wrong page: %d items, next cursor %q", len(page.Items), page.Meta.NextCursor)
+	}
+
+	seen := map[string]bool{}
+	for u, err := range c.Users.All(ctx, ListUsersOptions{Limit: 5}) {
+		if err != nil {
+			t.Fatalf("All() error = %v", err)
+		}
+		if seen[u.ID] {
+			t.Fatalf("All() returned user %s twice", u.ID)
+		}
+		seen[u.ID] = true
+	}
+	if len(seen) != total {
+		t.Errorf("All() returned wrong number of users: got %v want %v", len(seen), total)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		statuses         []int
+		expectedAttempts int32
+		expectedStatus   int
+	}{
+		{"retries unavailable", http.MethodGet, []int{http.StatusServiceUnavailable, http.StatusOK}, 2, http.StatusOK},
+		{"retries post with idempotency key", http.MethodPost, []int{http.StatusBadGateway, http.StatusOK}, 2, http.StatusOK},
+		{"gives up after max retries", http.MethodGet, []int{http.StatusTooManyRequests}, 4, http.StatusTooManyRequests},
+		{"does not retry client errors", http.MethodGet, []int{http.StatusBadRequest}, 1, http.StatusBadRequest},
+		{"does not retry patch", http.MethodPatch, []int{http.StatusServiceUnavailable}, 1, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			var mu sync.Mutex
+			keys := map[string]bool{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1)) - 1
+				status := tt.statuses[min(n, len(tt.statuses)-1)]
+				mu.Lock()
+				keys[r.Header.Get("Idempotency-Key")] = true
+				mu.Unlock()
+				if status >= http.StatusBadRequest {
+					problem.Write(w, r, problem.New(status, ""))
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"status":200,"data":{"id":"1"}}`))
+			}))
+			defer server.Close()
+
+			c := newTestClient(t, server.URL)
+			var out User
+			_, err := c.do(context.Background(), request{method: tt.method, path: "users", body: struct{}{}}, &out)
+
+			if got := attempts.Load(); got != tt.expectedAttempts {
+				t.Errorf("Client made wrong number of attempts: got %v want %v", got, tt.expectedAttempts)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				if err != nil || out.ID != "1" {
+					t.Errorf("do() = %+v, %v, want user 1", out, err)
+				}
+			} else if !IsStatus(err, tt.expectedStatus) {
+				t.Errorf("do() error = %v, want status %v", err, tt.expectedStatus)
+			}
+			if tt.method == http.MethodPost && len(keys) != 1 {
+				t.Errorf("Retries used different idempotency keys: %v", keys)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Retry-After", "30")
+		problem.Write(w, r, problem.New(http.StatusTooManyRequests, ""))
+	}))
+	defer server.Close()
+
+	c := newTestClient(t, server.URL)
+	c.config.MaxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Users.Get(ctx, "1")
+
+	if err == nil || ctx.Err() == nil {
+		t.Fatalf("Get() error = %v, want the context to expire while waiting", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("Client made wrong number of attempts: got %v want %v", got, 1)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		wantErr bool
+	}{
+		{"valid", "http://localhost:8080/v1", false},
+		{"trailing slash", "http://localhost:8080/v1/", false},
+		{"missing scheme", "localhost:8080/v1", true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(Config{BaseURL: tt.baseURL})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
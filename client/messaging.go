@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PublishResult is the acknowledgement of a published message
+type PublishResult struct {
+	Stream   string `json:"stream"`
+	Sequence uint64 `json:"sequence"`
+	Subject  string `json:"subject"`
+}
+
+// MessagingService calls the messaging endpoints
+type MessagingService struct {
+	client *Client
+}
+
+// Publish publishes data, encoded as JSON, to a subject of the stream
+func (s *MessagingService) Publish(ctx context.Context, subject string, data any) (*PublishResult, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("encoding message data: %w", err)
+	}
+
+	var result PublishResult
+	body := map[string]any{"subject": subject, "data": json.RawMessage(raw)}
+	if _, err := s.client.do(ctx, request{method: http.MethodPost, path: "messaging/publish", body: body}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// User is a user of the service
+type User struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// ETag is the version of the user, pass it as IfMatch to update or
+	// delete the user only if it was not modified since
+	ETag string `json:"-"`
+}
+
+// CreateUserRequest is the request to create a user
+type CreateUserRequest struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Password  string `json:"password"`
+}
+
+// UpdateUserRequest is the request to update a user. Nil fields are left
+// unchanged.
+type UpdateUserRequest struct {
+	Email     *string `json:"email,omitempty"`
+	FirstName *string `json:"first_name,omitempty"`
+	LastName  *string `json:"last_name,omitempty"`
+	// IfMatch fails the update with 412 when the user was modified since
+	// the ETag was retrieved
+	IfMatch string `json:"-"`
+}
+
+// ListUsersOptions selects a page of users
+type ListUsersOptions struct {
+	// Limit is the page size, the server default when 0
+	Limit int
+	// Cursor is the cursor of a previous page
+	Cursor string
+	// Sort holds comma separated fields, prefixed with - for descending order
+	Sort string
+	// Filters have the form field:op:value, such as email:like:example.com
+	Filters []string
+}
+
+// values returns the options as query parameters
+func (o ListUsersOptions) values() url.Values {
+	values := url.Values{}
+	if o.Limit > 0 {
+		values.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		values.Set("cursor", o.Cursor)
+	}
+	if o.Sort != "" {
+		values.Set("sort", o.Sort)
+	}
+	for _, f := range o.Filters {
+		values.Add("filter", f)
+	}
+	return values
+}
+
+// UserPage is a page of users
+type UserPage struct {
+	Items []User   `json:"items"`
+	Meta  PageMeta `json:"meta"`
+}
+
+// PageMeta describes a page of a cursor paginated listing
+type PageMeta struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// UsersService calls the user endpoints
+type UsersService struct {
+	client *Client
+}
+
+// Create creates a user
+func (s *UsersService) Create(ctx context.Context, req CreateUserRequest) (*User, error) {
+	var user User
+	header, err := s.client.do(ctx, request{method: http.MethodPost, path: "users", body: req}, &user)
+	if err != nil {
+		return nil, err
+	}
+	user.ETag = header.Get("ETag")
+	return &user, nil
+}
+
+// Get returns the user with the given ID
+func (s *UsersService) Get(ctx context.Context, id string) (*User, error) {
+	var user User
+	header, err := s.client.do(ctx, request{method: http.MethodGet, path: "users/" + url.PathEscape(id)}, &user)
+	if err != nil {
+		return nil, err
+	}
+	user.ETag = header.Get("ETag")
+	return &user, nil
+}
+
+// Update updates the given fields of a user
+func (s *UsersService) Update(ctx context.Context, id string, req UpdateUserRequest) (*User, error) {
+	var user User
+	header, err := s.client.do(ctx, request{
+		method: http.MethodPatch,
+		path:   "users/" + url.PathEscape(id),
+		header: ifMatch(req.IfMatch),
+		body:   req,
+	}, &user)
+	if err != nil {
+		return nil, err
+	}
+	user.ETag = header.Get("ETag")
+	return &user, nil
+}
+
+// Delete deletes a user. A non-empty etag fails the deletion with 412 when
+// the user was modified since it was retrieved.
+func (s *UsersService) Delete(ctx context.Context, id, etag string) error {
+	_, err := s.client.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "users/" + url.PathEscape(id),
+		header: ifMatch(etag),
+	}, nil)
+	return err
+}
+
+// List returns a page of users
+func (s *UsersService) List(ctx context.Context, opts ListUsersOptions) (*UserPage, error) {
+	var page UserPage
+	if _, err := s.client.do(ctx, request{method: http.MethodGet, path: "users", query: opts.values()}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// All iterates over the users from the page selected by opts, following the
+// next cursors. Iteration stops after the first error.
+func (s *UsersService) All(ctx context.Context, opts ListUsersOptions) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		for {
+			page, err := s.List(ctx, opts)
+			if err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, user := range page.Items {
+				if !yield(user, nil) {
+					return
+				}
+			}
+			if page.Meta.NextCursor == "" {
+				return
+			}
+			opts.Cursor = page.Meta.NextCursor
+		}
+	}
+}
+
+// ifMatch returns the If-Match header for etag, nil when etag is empty
+func ifMatch(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+	return http.Header{"If-Match": {etag}}
+}
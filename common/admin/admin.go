@@ -0,0 +1,169 @@
+// Package admin serves operational endpoints on a listener separate from the
+// API: profiling, runtime statistics, configuration, build metadata and the
+// log level
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/buildinfo"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Options configures the admin router
+type Options struct {
+	// Config is the configuration reported by /config, secrets must already
+	// be redacted
+	Config any
+	// Token is required as a bearer token when set
+	Token string
+	// Started is the start time of the service, used for the uptime
+	Started time.Time
+}
+
+// Admin holds the state of the admin endpoints
+type Admin struct {
+	opts Options
+}
+
+// New creates the admin endpoints
+func New(opts Options) *Admin {
+	if opts.Started.IsZero() {
+		opts.Started = time.Now()
+	}
+	return &Admin{opts: opts}
+}
+
+// Router returns a router for the admin endpoints
+func (a *Admin) Router() http.Handler {
+	r := chi.NewMux()
+	r.NotFound(problem.NotFoundHandler)
+	r.MethodNotAllowed(problem.MethodNotAllowedHandler)
+
+	if a.opts.Token != "" {
+		r.Use(bearerToken(a.opts.Token))
+	}
+
+	// Profiling, registered on this router rather than http.DefaultServeMux
+	r.HandleFunc("/debug/pprof/", pprof.Index)
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	r.HandleFunc("/debug/pprof/{profile}", pprof.Index)
+
+	r.Get("/runtime", utils.Handle(http.StatusOK, a.runtimeStats))
+	r.Get("/config", utils.Handle(http.StatusOK, a.config))
+	r.Get("/build", utils.Handle(http.StatusOK, a.build))
+	r.Get("/log-level", utils.Handle(http.StatusOK, a.logLevel))
+	r.Put("/log-level", utils.Handle(http.StatusOK, a.setLogLevel))
+
+	return r
+}
+
+// RuntimeStats is a snapshot of the Go runtime
+type RuntimeStats struct {
+	GoVersion   string    `json:"go_version"`
+	Uptime      string    `json:"uptime"`
+	Goroutines  int       `json:"goroutines"`
+	NumCPU      int       `json:"num_cpu"`
+	GOMAXPROCS  int       `json:"gomaxprocs"`
+	HeapAlloc   uint64    `json:"heap_alloc_bytes"`
+	HeapInuse   uint64    `json:"heap_inuse_bytes"`
+	HeapObjects uint64    `json:"heap_objects"`
+	TotalAlloc  uint64    `json:"total_alloc_bytes"`
+	Sys         uint64    `json:"sys_bytes"`
+	NumGC       uint32    `json:"num_gc"`
+	PauseTotal  string    `json:"gc_pause_total"`
+	LastGC      time.Time `json:"last_gc,omitzero"`
+}
+
+func (a *Admin) runtimeStats(ctx context.Context, req struct{}) (RuntimeStats, error) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	stats := RuntimeStats{
+		GoVersion:   runtime.Version(),
+		Uptime:      time.Since(a.opts.Started).Round(time.Second).String(),
+		Goroutines:  runtime.NumGoroutine(),
+		NumCPU:      runtime.NumCPU(),
+		GOMAXPROCS:  runtime.GOMAXPROCS(0),
+		HeapAlloc:   m.HeapAlloc,
+		HeapInuse:   m.HeapInuse,
+		HeapObjects: m.HeapObjects,
+		TotalAlloc:  m.TotalAlloc,
+		Sys:         m.Sys,
+		NumGC:       m.NumGC,
+		PauseTotal:  time.Duration(m.PauseTotalNs).String(),
+	}
+	if m.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(m.LastGC)).UTC()
+	}
+	return stats, nil
+}
+
+func (a *Admin) config(ctx context.Context, req struct{}) (any, error) {
+	return a.opts.Config, nil
+}
+
+func (a *Admin) build(ctx context.Context, req struct{}) (buildinfo.Info, error) {
+	return buildinfo.Get(), nil
+}
+
+// LogLevelRequest changes the global log level
+type LogLevelRequest struct {
+	Level string `json:"level" validate:"required,oneof=trace debug info warn error fatal panic disabled"`
+}
+
+// LogLevelResponse reports the global log level
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
+
+func (a *Admin) logLevel(ctx context.Context, req struct{}) (LogLevelResponse, error) {
+	return LogLevelResponse{Level: zerolog.GlobalLevel().String()}, nil
+}
+
+func (a *Admin) setLogLevel(ctx context.Context, req LogLevelRequest) (LogLevelResponse, error) {
+	level, err := zerolog.ParseLevel(req.Level)
+	if err != nil {
+		return LogLevelResponse{}, problem.BadRequest("Unknown log level " + req.Level)
+	}
+
+	previous := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(level)
+	// Logged at warn so the change is visible at any level but disabled
+	log.Warn().Str("from", previous.String()).Str("to", level.String()).Msg("Log level changed")
+
+	return LogLevelResponse{Level: level.String()}, nil
+}
+
+// bearerToken rejects requests without the token in the Authorization header
+func bearerToken(token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				problem.WriteError(w, r, problem.Unauthorized("A valid admin token is required"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+
+}
@@ -0,0 +1,90 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/admin"
+
+	"github.com/rs/zerolog"
+)
+
+func TestAdmin(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	router := admin.New(admin.Options{
+		Config: map[string]string{"password": "REDACTED"},
+		Token:  "secret",
+	}).Router()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		token          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Missing token", method: "GET", path: "/runtime", expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong token", method: "GET", path: "/runtime", token: "guess", expectedStatus: http.StatusUnauthorized},
+		{name: "Runtime stats", method: "GET", path: "/runtime", token: "secret", expectedStatus: http.StatusOK, expectedBody: `"goroutines"`},
+		{name: "Config", method: "GET", path: "/config", token: "secret", expectedStatus: http.StatusOK, expectedBody: `"password":"REDACTED"`},
+		{name: "Build info", method: "GET", path: "/build", token: "secret", expectedStatus: http.StatusOK, expectedBody: `"go_version"`},
+		{name: "Profiles index", method: "GET", path: "/debug/pprof/", token: "secret", expectedStatus: http.StatusOK, expectedBody: "goroutine"},
+		{name: "Named profile", method: "GET", path: "/debug/pprof/goroutine?debug=1", token: "secret", expectedStatus: http.StatusOK, expectedBody: "goroutine profile"},
+		{name: "Log level", method: "GET", path: "/log-level", token: "secret", expectedStatus: http.StatusOK, expectedBody: `"level":"info"`},
+		{name: "Invalid log level", method: "PUT", path: "/log-level", body: `{"level":"loud"}`, token: "secret", expectedStatus: http.StatusBadRequest},
+		{name: "Change log level", method: "PUT", path: "/log-level", body: `{"level":"warn"}`, token: "secret", expectedStatus: http.StatusOK, expectedBody: `"level":"warn"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v: %s", rr.Code, tc.expectedStatus, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body to contain %s, got %s", tc.expectedBody, rr.Body.String())
+			}
+		})
+	}
+
+	if zerolog.GlobalLevel() != zerolog.WarnLevel {
+		t.Errorf("Expected the global log level to be warn, got %s", zerolog.GlobalLevel())
+	}
+}
+
+func TestAdminWithoutToken(t *testing.T) {
+	router := admin.New(admin.Options{}).Router()
+
+	req := httptest.NewRequest("GET", "/build", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var response struct {
+		Data struct {
+			Version string `json:"version"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Data.Version != "dev" {
+		t.Errorf("Expected version dev, got %s", response.Data.Version)
+	}
+}
@@ -0,0 +1,194 @@
+// Package certs serves TLS certificates that are reloaded when their files
+// change on disk, so rotated certificates are picked up without a restart
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultReloadInterval is how often Watch checks the files for changes
+const DefaultReloadInterval = 30 * time.Second
+
+// Config locates the files of a TLS listener
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the PEM encoded CAs client certificates are verified
+	// against, enabling mutual TLS
+	ClientCAFile string
+	// ClientAuth is the client certificate policy, RequireAndVerifyClientCert
+	// by default when ClientCAFile is set
+	ClientAuth tls.ClientAuthType
+}
+
+// ParseClientAuth parses a client certificate policy: none, optional
+// (verified when presented) or require
+func ParseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("invalid client auth %q, expected none, optional or require", s)
+	}
+}
+
+// Reloader keeps the TLS configuration built from the files of a Config
+type Reloader struct {
+	cfg     Config
+	current atomic.Pointer[tls.Config]
+
+	mu sync.Mutex
+	// stamps identifies the versions of the files currently loaded
+	stamps string
+}
+
+// NewReloader loads the files of cfg, failing when they are invalid
+func NewReloader(cfg Config) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("certificate and key files are required")
+	}
+	if cfg.ClientCAFile != "" && cfg.ClientAuth == tls.NoClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if cfg.ClientCAFile == "" && cfg.ClientAuth > tls.RequestClientCert {
+		return nil, errors.New("verifying client certificates requires a client CA file")
+	}
+
+	r := &Reloader{cfg: cfg}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns a configuration for http.Server that always hands out
+// the latest loaded certificates
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// Reload reads the files again, keeping the previous configuration when they
+// are invalid
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamps, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		// Configs returned by GetConfigForClient are used as is, so they
+		// advertise HTTP/2 themselves
+		NextProtos: []string{"h2", "http/1.1"},
+		ClientAuth: r.cfg.ClientAuth,
+	}
+
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.cfg.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+	}
+
+	r.current.Store(cfg)
+	r.stamps = stamps
+	return nil
+}
+
+// Watch reloads the files every interval when they changed, until ctx is
+// done. Rotations replacing files or symlinks, as done by Kubernetes secret
+// mounts, are detected as well.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := r.changed()
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to check TLS files")
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		// Files are often written one at a time, an incomplete rotation
+		// fails here and is retried on the next tick
+		if err := r.Reload(); err != nil {
+			log.Warn().Err(err).Msg("Failed to reload TLS files, keeping previous certificate")
+			continue
+		}
+		log.Info().Str("cert", r.cfg.CertFile).Msg("TLS certificate reloaded")
+	}
+}
+
+// changed reports whether the files differ from the loaded ones
+func (r *Reloader) changed() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamps, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+	return stamps != r.stamps, nil
+}
+
+// stat returns the size and modification time of the files
+func (r *Reloader) stat() (string, error) {
+	var b strings.Builder
+	for _, name := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return "", fmt.Errorf("checking TLS file: %w", err)
+		}
+		fmt.Fprintf(&b, "%d/%d;", info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,233 @@
+package certs_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/certs"
+)
+
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates a certificate for cn signed by parent, or self-signed
+func issue(t *testing.T, cn string, parent *keyPair, isCA bool) *keyPair {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &keyPair{cert: cert, key: key}
+}
+
+func (k *keyPair) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: k.cert.Raw})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if keyFile == "" {
+		return
+	}
+	der, _ := x509.MarshalECPrivateKey(k.key)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func (k *keyPair) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{k.cert.Raw}, PrivateKey: k.key}
+}
+
+// serve starts an HTTPS server using the certificates of reloader
+func serve(t *testing.T, reloader *certs.Reloader) string {
+	t.Helper()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return ln.Addr().String()
+}
+
+// handshake connects to addr and returns the server certificate
+func handshake(addr string, roots *x509.CertPool, client *tls.Certificate) (*x509.Certificate, error) {
+	cfg := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	if client != nil {
+		cfg.Certificates = []tls.Certificate{*client}
+	}
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// TLS 1.3 reports rejected client certificates on the first read
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return nil, err
+	}
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	ca := issue(t, "ca", nil, true)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	issue(t, "first", ca, false).write(t, certFile, keyFile)
+	reloader, err := certs.NewReloader(certs.Config{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	addr := serve(t, reloader)
+
+	cert, err := handshake(addr, roots, nil)
+	if err != nil || cert.Subject.CommonName != "first" {
+		t.Fatalf("Expected certificate first, got %v, %v", cert, err)
+	}
+
+	// Invalid files keep the previous certificate
+	if err := os.WriteFile(keyFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Errorf("Expected Reload() to fail with an invalid key")
+	}
+	if cert, err := handshake(addr, roots, nil); err != nil || cert.Subject.CommonName != "first" {
+		t.Errorf("Expected previous certificate after a failed reload, got %v, %v", cert, err)
+	}
+
+	// Rotated files are picked up by Watch
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	issue(t, "second", ca, false).write(t, certFile, keyFile)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cert, err := handshake(addr, roots, nil)
+		if err == nil && cert.Subject.CommonName == "second" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Certificate was not reloaded, got %v, %v", cert, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestReloaderClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+
+	ca := issue(t, "ca", nil, true)
+	ca.write(t, caFile, "")
+	issue(t, "server", ca, false).write(t, certFile, keyFile)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	reloader, err := certs.NewReloader(certs.Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	addr := serve(t, reloader)
+
+	trusted := issue(t, "client", ca, false).tls()
+	untrusted := issue(t, "stranger", issue(t, "other-ca", nil, true), false).tls()
+
+	tests := []struct {
+		name    string
+		client  *tls.Certificate
+		wantErr bool
+	}{
+		{name: "Trusted client certificate", client: &trusted},
+		{name: "Missing client certificate", client: nil, wantErr: true},
+		{name: "Untrusted client certificate", client: &untrusted, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := handshake(addr, roots, tc.client)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("handshake() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseClientAuth(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    tls.ClientAuthType
+		wantErr bool
+	}{
+		{input: "", want: tls.NoClientCert},
+		{input: "none", want: tls.NoClientCert},
+		{input: "optional", want: tls.VerifyClientCertIfGiven},
+		{input: "Require", want: tls.RequireAndVerifyClientCert},
+		{input: "always", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := certs.ParseClientAuth(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseClientAuth(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseClientAuth(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
@@ -3,33 +3,66 @@ package db
 import (
 	"context"
 	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/LexiconIndonesia/go-http-service-template/common/pagination"
 	"github.com/LexiconIndonesia/go-http-service-template/repository"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Store is the persistence API used by features. It is implemented by DB for
+// Postgres and by FakeDB for tests.
+type Store interface {
+	repository.Querier
+	ListUsers(ctx context.Context, p pagination.Params) ([]repository.User, error)
+	Ping(ctx context.Context) error
+	WithTx(ctx context.Context, opts TxOptions, fn TxFunc) error
+}
+
 // DB provides access to the database
 type DB struct {
-	Pool    *pgxpool.Pool
-	Queries *repository.Queries
+	Pool *pgxpool.Pool
+	*repository.Queries
+
+	replicas             []*replica
+	nextReplica          atomic.Uint64
+	replicaCheckInterval time.Duration
+	stopReplicaChecks    chan struct{}
+	replicaChecks        sync.WaitGroup
 }
 
-// New creates a new DB instance
-func New(pool *pgxpool.Pool, queries *repository.Queries) (*DB, error) {
+var _ Store = (*DB)(nil)
+
+// New creates a new DB instance. When replicas are configured the queries are
+// rebuilt on top of a router that can send reads to them.
+func New(pool *pgxpool.Pool, queries *repository.Queries, opts ...Option) (*DB, error) {
 	if pool == nil {
 		return nil, errors.New("cannot use nil database pool")
 	}
 	if queries == nil {
 		return nil, errors.New("cannot use nil queries")
 	}
-	return &DB{
+
+	db := &DB{
 		Pool:    pool,
 		Queries: queries,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	if len(db.replicas) > 0 {
+		db.Queries = repository.New(routingDBTX{db: db})
+		db.startReplicaHealthChecks()
+	}
+	return db, nil
 }
 
-// Close closes the database connection
+// Close closes the database connection and any read replicas
 func (db *DB) Close() {
+	db.closeReplicas()
 	if db.Pool != nil {
 		db.Pool.Close()
 	}
@@ -1,3 +1,272 @@
 package db
 
-// 'in memory' fake database, so to speak
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/pagination"
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// FakeDB is a thread-safe in-memory Store for tests. It mirrors the behaviour
+// of the Postgres schema: missing rows return pgx.ErrNoRows and duplicate
+// emails return a unique violation on users_email_key.
+type FakeDB struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]repository.User
+	keys  map[string]repository.IdempotencyKey
+	now   func() time.Time
+	// version counts the writes, so transactions detect concurrent writers
+	version uint64
+}
+
+var _ Store = (*FakeDB)(nil)
+
+// NewFake creates a new empty in-memory database
+func NewFake() *FakeDB {
+	return &FakeDB{
+		users: make(map[uuid.UUID]repository.User),
+		keys:  make(map[string]repository.IdempotencyKey),
+		now:   time.Now,
+	}
+}
+
+// Ping always succeeds
+func (f *FakeDB) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// WithTx runs fn against a snapshot of the data and applies the changes only
+// when fn succeeds. The lock is not held while fn runs, so fn may use the outer
+// store. When another write was committed in the meantime the transaction
+// fails with a serialization failure and is retried, like DB.WithTx.
+func (f *FakeDB) WithTx(ctx context.Context, opts TxOptions, fn TxFunc) error {
+	opts = opts.withDefaults()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = f.runTx(ctx, opts, fn)
+		if err == nil || !IsRetryable(err) || attempt >= opts.MaxRetries {
+			return err
+		}
+	}
+}
+
+// runTx runs a single attempt of WithTx
+func (f *FakeDB) runTx(ctx context.Context, opts TxOptions, fn TxFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.mu.RLock()
+	tx := &FakeDB{
+		users: maps.Clone(f.users),
+		keys:  maps.Clone(f.keys),
+		now:   f.now,
+	}
+	version := f.version
+	f.mu.RUnlock()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if opts.ReadOnly {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.version != version {
+		return &pgconn.PgError{
+			Severity: "ERROR",
+			Code:     sqlStateSerializationFailure,
+			Message:  "could not serialize access due to concurrent update",
+		}
+	}
+	f.users = tx.users
+	f.keys = tx.keys
+	f.version++
+	return nil
+}
+
+// CreateUser inserts a user, generating its ID and timestamps
+func (f *FakeDB) CreateUser(ctx context.Context, arg repository.CreateUserParams) (repository.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkEmailUnique(uuid.Nil, arg.Email); err != nil {
+		return repository.User{}, err
+	}
+
+	now := f.now().UTC()
+	user := repository.User{
+		ID:        uuid.New(),
+		Name:      arg.Name,
+		Email:     arg.Email,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	f.users[user.ID] = user
+	f.version++
+	return user, nil
+}
+
+// DeleteUser deletes a user, deleting a missing user is not an error
+func (f *FakeDB) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.users, id)
+	f.version++
+	return nil
+}
+
+// GetUser returns the user with the given ID
+func (f *FakeDB) GetUser(ctx context.Context, id uuid.UUID) (repository.User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	user, ok := f.users[id]
+	if !ok {
+		return repository.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+// ListUsers returns up to p.Limit+1 users matching p
+func (f *FakeDB) ListUsers(ctx context.Context, p pagination.Params) ([]repository.User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return pagination.Apply(slices.Collect(maps.Values(f.users)), p, UserValue), nil
+}
+
+// GetUserForUpdate returns the user with the given ID. Transactions of the
+// fake detect concurrent writers on commit instead of locking rows.
+func (f *FakeDB) GetUserForUpdate(ctx context.Context, id uuid.UUID) (repository.User, error) {
+	return f.GetUser(ctx, id)
+}
+
+// UpdateUser updates the name, email and update time of an existing user
+func (f *FakeDB) UpdateUser(ctx context.Context, arg repository.UpdateUserParams) (repository.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, ok := f.users[arg.ID]
+	if !ok {
+		return repository.User{}, pgx.ErrNoRows
+	}
+
+	if err := f.checkEmailUnique(arg.ID, arg.Email); err != nil {
+		return repository.User{}, err
+	}
+
+	user.Name = arg.Name
+	user.Email = arg.Email
+	user.UpdatedAt = f.now().UTC()
+	f.users[user.ID] = user
+	f.version++
+	return user, nil
+}
+
+// checkEmailUnique returns a unique violation when another user has the email,
+// callers must hold the lock
+func (f *FakeDB) checkEmailUnique(id uuid.UUID, email string) error {
+	for _, u := range f.users {
+		if u.Email == email && u.ID != id {
+			return &pgconn.PgError{
+				Severity:       "ERROR",
+				Code:           sqlStateUniqueViolation,
+				Message:        `duplicate key value violates unique constraint "users_email_key"`,
+				Detail:         fmt.Sprintf("Key (email)=(%s) already exists.", email),
+				TableName:      "users",
+				ConstraintName: "users_email_key",
+			}
+		}
+	}
+	return nil
+}
+
+// ReserveIdempotencyKey inserts the key, or replaces an expired one. It returns
+// pgx.ErrNoRows when an unexpired key exists.
+func (f *FakeDB) ReserveIdempotencyKey(ctx context.Context, arg repository.ReserveIdempotencyKeyParams) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if existing, ok := f.keys[arg.Key]; ok && existing.ExpiresAt.After(f.now()) {
+		return "", pgx.ErrNoRows
+	}
+	f.keys[arg.Key] = repository.IdempotencyKey{
+		Key:         arg.Key,
+		RequestHash: arg.RequestHash,
+		ExpiresAt:   arg.ExpiresAt,
+	}
+	f.version++
+	return arg.Key, nil
+}
+
+// GetIdempotencyKey returns the record of key
+func (f *FakeDB) GetIdempotencyKey(ctx context.Context, key string) (repository.IdempotencyKey, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	record, ok := f.keys[key]
+	if !ok {
+		return repository.IdempotencyKey{}, pgx.ErrNoRows
+	}
+	return record, nil
+}
+
+// CompleteIdempotencyKey stores the response of an existing key
+func (f *FakeDB) CompleteIdempotencyKey(ctx context.Context, arg repository.CompleteIdempotencyKeyParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record, ok := f.keys[arg.Key]
+	if !ok {
+		return nil
+	}
+	record.Status = arg.Status
+	record.Header = arg.Header
+	record.Body = arg.Body
+	record.ExpiresAt = arg.ExpiresAt
+	f.keys[arg.Key] = record
+	f.version++
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes the key while its request is in flight
+func (f *FakeDB) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if record, ok := f.keys[key]; ok && record.Status == 0 {
+		delete(f.keys, key)
+		f.version++
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes the expired keys
+func (f *FakeDB) PurgeIdempotencyKeys(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	before := len(f.keys)
+	maps.DeleteFunc(f.keys, func(_ string, record repository.IdempotencyKey) bool {
+		return !record.ExpiresAt.After(now)
+	})
+	if len(f.keys) != before {
+		f.version++
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/pagination"
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+	"github.com/google/uuid"
+)
+
+func TestFakeDBSemantics(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	user, err := fake.CreateUser(ctx, repository.CreateUserParams{Name: "John Doe", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	if _, err := fake.CreateUser(ctx, repository.CreateUserParams{Name: "Jane Doe", Email: "john@example.com"}); !IsUniqueViolation(err) {
+		t.Errorf("expected unique violation for duplicate email, got %v", err)
+	}
+
+	if _, err := fake.GetUser(ctx, uuid.New()); !IsNotFound(err) {
+		t.Errorf("expected not found for unknown user, got %v", err)
+	}
+
+	if _, err := fake.UpdateUser(ctx, repository.UpdateUserParams{ID: uuid.New(), Name: "x", Email: "x@example.com"}); !IsNotFound(err) {
+		t.Errorf("expected not found when updating unknown user, got %v", err)
+	}
+
+	if err := fake.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := fake.GetUser(ctx, user.ID); !IsNotFound(err) {
+		t.Errorf("expected not found after delete, got %v", err)
+	}
+}
+
+func TestFakeDBWithTxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	errAbort := errors.New("abort")
+
+	var created repository.User
+	err := fake.WithTx(ctx, TxOptions{}, func(q repository.Querier) error {
+		var err error
+		created, err = q.CreateUser(ctx, repository.CreateUserParams{Name: "John Doe", Email: "john@example.com"})
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx() error = %v, want %v", err, errAbort)
+	}
+
+	if _, err := fake.GetUser(ctx, created.ID); !IsNotFound(err) {
+		t.Errorf("expected user to be rolled back, got %v", err)
+	}
+
+	err = fake.WithTx(ctx, TxOptions{}, func(q repository.Querier) error {
+		created, err = q.CreateUser(ctx, repository.CreateUserParams{Name: "John Doe", Email: "john@example.com"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+
+	if _, err := fake.GetUser(ctx, created.ID); err != nil {
+		t.Errorf("expected committed user, got %v", err)
+	}
+}
+
+func TestFakeDBWithTxConcurrentWriter(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	// The outer store is usable from the callback, a write to it conflicts
+	// with the transaction, which is retried
+	attempts := 0
+	err := fake.WithTx(ctx, TxOptions{}, func(q repository.Querier) error {
+		attempts++
+		if attempts == 1 {
+			if _, err := fake.CreateUser(ctx, repository.CreateUserParams{Name: "Jane Doe", Email: "jane@example.com"}); err != nil {
+				return err
+			}
+		}
+		_, err := q.CreateUser(ctx, repository.CreateUserParams{Name: "John Doe", Email: "john@example.com"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("WithTx() ran fn %d times, want 2", attempts)
+	}
+
+	users, err := fake.ListUsers(ctx, pagination.Params{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if len(users) != 2 {
+		t.Errorf("expected both users to be committed, got %d", len(users))
+	}
+
+	// A writer racing every attempt exhausts the retries
+	err = fake.WithTx(ctx, TxOptions{MaxRetries: 1}, func(q repository.Querier) error {
+		if _, err := fake.CreateUser(ctx, repository.CreateUserParams{Name: "X", Email: uuid.NewString() + "@example.com"}); err != nil {
+			return err
+		}
+		_, err := q.CreateUser(ctx, repository.CreateUserParams{Name: "Y", Email: "y@example.com"})
+		return err
+	})
+	if !IsRetryable(err) {
+		t.Errorf("WithTx() error = %v, want a serialization failure", err)
+	}
+}
+
+func TestFakeDBPurgeWithoutExpiredKeys(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	// A purge deleting nothing is not a concurrent write
+	attempts := 0
+	err := fake.WithTx(ctx, TxOptions{}, func(q repository.Querier) error {
+		attempts++
+		if err := fake.PurgeIdempotencyKeys(ctx); err != nil {
+			return err
+		}
+		_, err := q.CreateUser(ctx, repository.CreateUserParams{Name: "Jane Doe", Email: "jane@example.com"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("WithTx() ran fn %d times, want 1", attempts)
+	}
+}
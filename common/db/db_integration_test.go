@@ -0,0 +1,179 @@
+//go:build integration
+
+package db_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db"
+	"github.com/LexiconIndonesia/go-http-service-template/common/db/dbtest"
+	"github.com/LexiconIndonesia/go-http-service-template/common/pagination"
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestQueriesIntegration(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.New(t)
+
+	user, err := store.CreateUser(ctx, repository.CreateUserParams{Name: "John Doe", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	got, err := store.GetUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if got.Email != user.Email || got.Name != user.Name {
+		t.Errorf("GetUser() = %+v, want %+v", got, user)
+	}
+
+	if _, err := store.CreateUser(ctx, repository.CreateUserParams{Name: "Jane Doe", Email: "john@example.com"}); !db.IsUniqueViolation(err) {
+		t.Errorf("expected unique violation for duplicate email, got %v", err)
+	}
+
+	updated, err := store.UpdateUser(ctx, repository.UpdateUserParams{ID: user.ID, Name: "Johnny Doe", Email: "johnny@example.com"})
+	if err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	if updated.Name != "Johnny Doe" {
+		t.Errorf("UpdateUser() name = %s, want Johnny Doe", updated.Name)
+	}
+
+	if err := store.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := store.GetUser(ctx, user.ID); !db.IsNotFound(err) {
+		t.Errorf("expected not found after delete, got %v", err)
+	}
+}
+
+func TestListUsersIntegration(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.New(t)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@test.com", "d@example.com"} {
+		if _, err := store.CreateUser(ctx, repository.CreateUserParams{Name: "User", Email: email}); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	signer := pagination.NewSigner([]byte("secret"))
+	values := url.Values{"sort": {"email"}, "filter": {"email:like:EXAMPLE"}, "limit": {"2"}}
+
+	var emails []string
+	for {
+		p, err := db.UserListSchema.Parse(values, signer)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		rows, err := store.ListUsers(ctx, p)
+		if err != nil {
+			t.Fatalf("ListUsers() error = %v", err)
+		}
+		page := pagination.NewPage(rows, p, db.UserValue, signer)
+		for _, u := range page.Items {
+			emails = append(emails, u.Email)
+		}
+		if page.Next == "" {
+			break
+		}
+		values.Set("cursor", page.Next)
+	}
+
+	want := []string{"a@example.com", "b@example.com", "d@example.com"}
+	if !slices.Equal(emails, want) {
+		t.Errorf("ListUsers() emails = %v, want %v", emails, want)
+	}
+}
+
+func TestWithTxIntegration(t *testing.T) {
+	ctx := context.Background()
+	store := dbtest.New(t)
+	errAbort := errors.New("abort")
+
+	t.Run("Rollback on error", func(t *testing.T) {
+		var created repository.User
+		err := store.WithTx(ctx, db.TxOptions{}, func(q repository.Querier) error {
+			var err error
+			created, err = q.CreateUser(ctx, repository.CreateUserParams{Name: "Rolled Back", Email: "rollback@example.com"})
+			if err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("WithTx() error = %v, want %v", err, errAbort)
+		}
+		if _, err := store.GetUser(ctx, created.ID); !db.IsNotFound(err) {
+			t.Errorf("expected user to be rolled back, got %v", err)
+		}
+	})
+
+	t.Run("Nested savepoint rollback", func(t *testing.T) {
+		var outer, inner repository.User
+		err := store.WithTxContext(ctx, db.TxOptions{}, func(ctx context.Context, q repository.Querier) error {
+			var err error
+			outer, err = q.CreateUser(ctx, repository.CreateUserParams{Name: "Outer", Email: "outer@example.com"})
+			if err != nil {
+				return err
+			}
+
+			err = store.WithTxContext(ctx, db.TxOptions{}, func(ctx context.Context, q repository.Querier) error {
+				inner, err = q.CreateUser(ctx, repository.CreateUserParams{Name: "Inner", Email: "inner@example.com"})
+				if err != nil {
+					return err
+				}
+				return errAbort
+			})
+			if !errors.Is(err, errAbort) {
+				t.Errorf("nested WithTxContext() error = %v, want %v", err, errAbort)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WithTxContext() error = %v", err)
+		}
+
+		if _, err := store.GetUser(ctx, outer.ID); err != nil {
+			t.Errorf("expected outer user to be committed, got %v", err)
+		}
+		if _, err := store.GetUser(ctx, inner.ID); !db.IsNotFound(err) {
+			t.Errorf("expected inner user to be rolled back, got %v", err)
+		}
+	})
+
+	t.Run("Read only", func(t *testing.T) {
+		err := store.WithTx(ctx, db.TxOptions{ReadOnly: true}, func(q repository.Querier) error {
+			_, err := q.CreateUser(ctx, repository.CreateUserParams{Name: "Read Only", Email: "readonly@example.com"})
+			return err
+		})
+		if err == nil {
+			t.Fatal("expected write in read only transaction to fail")
+		}
+	})
+
+	t.Run("Retry on serialization failure", func(t *testing.T) {
+		attempts := 0
+		err := store.WithTx(ctx, db.TxOptions{IsoLevel: pgx.Serializable, MaxRetries: 2}, func(q repository.Querier) error {
+			attempts++
+			if attempts < 3 {
+				return &pgconn.PgError{Code: "40001"}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WithTx() error = %v", err)
+		}
+		if attempts != 3 {
+			t.Errorf("expected 3 attempts, got %d", attempts)
+		}
+	})
+}
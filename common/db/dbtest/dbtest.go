@@ -0,0 +1,133 @@
+//go:build integration
+
+// Package dbtest provides a Postgres harness for integration tests. Each test
+// gets its own schema with all migrations applied, so tests can run in
+// parallel against a single database.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db"
+	"github.com/LexiconIndonesia/go-http-service-template/migrations"
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EnvDatabaseURL is the environment variable holding the connection string of
+// the Postgres server used by integration tests
+const EnvDatabaseURL = "TEST_DATABASE_URL"
+
+// setupTimeout bounds connecting to Postgres and applying migrations
+const setupTimeout = 30 * time.Second
+
+// New returns a DB connected to a fresh schema with all migrations applied.
+// The schema is dropped and the pool closed when the test finishes. The test
+// is skipped when TEST_DATABASE_URL is not set or the server is unreachable.
+func New(t testing.TB) *db.DB {
+	t.Helper()
+
+	connStr := os.Getenv(EnvDatabaseURL)
+	if connStr == "" {
+		t.Skipf("%s not set, skipping Postgres integration test", EnvDatabaseURL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), setupTimeout)
+	defer cancel()
+
+	admin, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Skipf("Postgres not reachable, skipping integration test: %v", err)
+	}
+	defer admin.Close(context.Background())
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if err := createSchema(ctx, admin, schema); err != nil {
+		t.Fatalf("creating test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		dropSchema(t, connStr, schema)
+	})
+
+	config, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		t.Fatalf("parsing database config: %v", err)
+	}
+	// Extensions such as uuid-ossp live in public, keep it on the search path
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("connecting to database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := Migrate(ctx, pool); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	dbConn, err := db.New(pool, repository.New(pool))
+	if err != nil {
+		t.Fatalf("creating DB handler: %v", err)
+	}
+	return dbConn
+}
+
+// Migrate applies the embedded migrations in file name order
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	files, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		return fmt.Errorf("listing migrations: %w", err)
+	}
+	sort.Strings(files)
+
+	for _, name := range files {
+		content, err := fs.ReadFile(migrations.FS, name)
+		if err != nil {
+			return fmt.Errorf("reading migration %s: %w", name, err)
+		}
+		if _, err := pool.Exec(ctx, string(content)); err != nil {
+			return fmt.Errorf("applying migration %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func createSchema(ctx context.Context, conn *pgx.Conn, schema string) error {
+	// Concurrent CREATE EXTENSION calls can race on the catalog, which is
+	// harmless as long as the extension ends up installed
+	if _, err := conn.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS "uuid-ossp" SCHEMA public`); err != nil && !db.IsUniqueViolation(err) {
+		return fmt.Errorf("creating uuid-ossp extension: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func dropSchema(t testing.TB, connStr, schema string) {
+	ctx, cancel := context.WithTimeout(context.Background(), setupTimeout)
+	defer cancel()
+
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Logf("connecting to drop test schema %s: %v", schema, err)
+		return
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "DROP SCHEMA IF EXISTS "+pgx.Identifier{schema}.Sanitize()+" CASCADE"); err != nil {
+		t.Logf("dropping test schema %s: %v", schema, err)
+	}
+}
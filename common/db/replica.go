@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultReplicaCheckInterval is how often replicas are pinged
+	defaultReplicaCheckInterval = 5 * time.Second
+	// replicaPingTimeout bounds a single replica health check
+	replicaPingTimeout = 2 * time.Second
+)
+
+// Option configures a DB created with New
+type Option func(*DB)
+
+// WithReplicas adds read replicas. Queries made with a context marked by
+// ReadFromReplica are sent to a healthy replica in round-robin order, falling
+// back to the primary when none is healthy. Writes and transactions always use
+// the primary. The replica pools are closed by DB.Close.
+func WithReplicas(pools ...*pgxpool.Pool) Option {
+	return func(db *DB) {
+		for _, pool := range pools {
+			if pool != nil {
+				db.replicas = append(db.replicas, &replica{pool: pool})
+			}
+		}
+	}
+}
+
+// WithReplicaCheckInterval sets how often replicas are health checked
+func WithReplicaCheckInterval(interval time.Duration) Option {
+	return func(db *DB) {
+		db.replicaCheckInterval = interval
+	}
+}
+
+type readModeContextKey struct{}
+
+type readMode int
+
+const (
+	readPrimary readMode = iota
+	readReplica
+)
+
+// ReadFromReplica marks ctx so that read queries made with it may be served by
+// a read replica. Use it for queries that tolerate replication lag.
+func ReadFromReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readModeContextKey{}, readReplica)
+}
+
+// ReadFromPrimary marks ctx so that read queries made with it are always served
+// by the primary, overriding an enclosing ReadFromReplica
+func ReadFromPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readModeContextKey{}, readPrimary)
+}
+
+func wantsReplica(ctx context.Context) bool {
+	mode, _ := ctx.Value(readModeContextKey{}).(readMode)
+	return mode == readReplica
+}
+
+// replica is a read replica pool and its last known health
+type replica struct {
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// Replica returns a querier bound to the next healthy replica, or to the
+// primary when no replica is healthy. It ignores context marks, use it for
+// code paths that are always read only.
+func (db *DB) Replica() repository.Querier {
+	return repository.New(db.readPool())
+}
+
+// readPool returns the next healthy replica pool in round-robin order, or the
+// primary pool when none is available
+func (db *DB) readPool() *pgxpool.Pool {
+	n := len(db.replicas)
+	if n == 0 {
+		return db.Pool
+	}
+
+	start := db.nextReplica.Add(1)
+	for i := range n {
+		r := db.replicas[(int(start)+i)%n]
+		if r.healthy.Load() {
+			return r.pool
+		}
+	}
+
+	log.Debug().Msg("No healthy read replica, falling back to primary")
+	return db.Pool
+}
+
+// startReplicaHealthChecks pings replicas immediately and then periodically
+// until DB.Close is called. Replicas start unhealthy so reads stay on the
+// primary until a replica has answered a ping.
+func (db *DB) startReplicaHealthChecks() {
+	if len(db.replicas) == 0 {
+		return
+	}
+
+	interval := db.replicaCheckInterval
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+
+	db.stopReplicaChecks = make(chan struct{})
+	db.replicaChecks.Add(1)
+	go func() {
+		defer db.replicaChecks.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			db.checkReplicas()
+
+			select {
+			case <-db.stopReplicaChecks:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkReplicas pings every replica concurrently and records its health
+func (db *DB) checkReplicas() {
+	var wg sync.WaitGroup
+	for i, r := range db.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+			defer cancel()
+
+			err := r.pool.Ping(ctx)
+			healthy := err == nil
+			if r.healthy.Swap(healthy) != healthy {
+				if healthy {
+					log.Info().Int("replica", i).Msg("Read replica is healthy")
+				} else {
+					log.Warn().Err(err).Int("replica", i).Msg("Read replica is unhealthy")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// closeReplicas stops the health checks and closes the replica pools
+func (db *DB) closeReplicas() {
+	if db.stopReplicaChecks != nil {
+		close(db.stopReplicaChecks)
+		db.replicaChecks.Wait()
+		db.stopReplicaChecks = nil
+	}
+	for _, r := range db.replicas {
+		r.pool.Close()
+	}
+}
+
+// routingDBTX sends reads made with a ReadFromReplica context to a replica and
+// everything else to the primary
+type routingDBTX struct {
+	db *DB
+}
+
+var _ repository.DBTX = routingDBTX{}
+
+func (r routingDBTX) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return r.db.Pool.Exec(ctx, sql, args...)
+}
+
+func (r routingDBTX) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return r.pool(ctx, sql).Query(ctx, sql, args...)
+}
+
+func (r routingDBTX) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return r.pool(ctx, sql).QueryRow(ctx, sql, args...)
+}
+
+// pool picks the pool for a query. sqlc also uses QueryRow for statements such
+// as INSERT ... RETURNING, so only plain SELECT statements go to a replica.
+func (r routingDBTX) pool(ctx context.Context, sql string) *pgxpool.Pool {
+	if wantsReplica(ctx) && isSelect(sql) {
+		return r.db.readPool()
+	}
+	return r.db.Pool
+}
+
+// lockingClause matches the row locking clauses of a SELECT, which replicas
+// cannot serve
+var lockingClause = regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|NO\s+KEY\s+UPDATE|SHARE|KEY\s+SHARE)\b`)
+
+// isSelect reports whether sql is a SELECT statement without a locking clause,
+// skipping the leading "-- name:" comment emitted by sqlc. Statements starting
+// with WITH may modify data and are treated as writes.
+func isSelect(sql string) bool {
+	for {
+		sql = strings.TrimLeft(sql, " \t\r\n")
+		if !strings.HasPrefix(sql, "--") {
+			break
+		}
+		_, rest, found := strings.Cut(sql, "\n")
+		if !found {
+			return false
+		}
+		sql = rest
+	}
+	if len(sql) < len("SELECT") || !strings.EqualFold(sql[:len("SELECT")], "SELECT") {
+		return false
+	}
+	return !lockingClause.MatchString(sql)
+}
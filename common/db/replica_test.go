@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestIsSelect(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected bool
+	}{
+		{name: "sqlc select", sql: "-- name: GetUser :one\nSELECT id, name FROM users WHERE id = $1\n", expected: true},
+		{name: "Lowercase select", sql: "  select 1", expected: true},
+		{name: "sqlc insert returning", sql: "-- name: CreateUser :one\nINSERT INTO users (name) VALUES ($1) RETURNING id\n", expected: false},
+		{name: "Common table expression", sql: "WITH x AS (DELETE FROM users RETURNING id) SELECT * FROM x", expected: false},
+		{name: "Select for update", sql: "-- name: GetUserForUpdate :one\nSELECT id FROM users WHERE id = $1 FOR UPDATE\n", expected: false},
+		{name: "Select for no key update", sql: "SELECT id FROM users FOR NO KEY UPDATE SKIP LOCKED", expected: false},
+		{name: "Select for share", sql: "select id from users for share", expected: false},
+		{name: "Select for key share", sql: "SELECT id FROM users\nFOR KEY SHARE OF users", expected: false},
+		{name: "Only a comment", sql: "-- nothing", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isSelect(tc.sql); got != tc.expected {
+				t.Errorf("isSelect() = %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	// Pools connect lazily, so no server is needed to test routing
+	newPool := func() *pgxpool.Pool {
+		pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(pool.Close)
+		return pool
+	}
+
+	primary, first, second := newPool(), newPool(), newPool()
+	db := &DB{Pool: primary, Queries: repository.New(primary)}
+	WithReplicas(first, second)(db)
+
+	if got := db.readPool(); got != primary {
+		t.Error("expected primary while no replica is healthy")
+	}
+
+	db.replicas[0].healthy.Store(true)
+	db.replicas[1].healthy.Store(true)
+	seen := map[*pgxpool.Pool]int{}
+	for range 4 {
+		seen[db.readPool()]++
+	}
+	if seen[first] != 2 || seen[second] != 2 {
+		t.Errorf("expected round-robin across replicas, got %d and %d", seen[first], seen[second])
+	}
+
+	db.replicas[0].healthy.Store(false)
+	for range 2 {
+		if got := db.readPool(); got != second {
+			t.Error("expected the only healthy replica to be used")
+		}
+	}
+
+	router := routingDBTX{db: db}
+	ctx := context.Background()
+	if got := router.pool(ctx, "SELECT 1"); got != primary {
+		t.Error("expected unmarked reads to use the primary")
+	}
+	if got := router.pool(ReadFromReplica(ctx), "SELECT 1"); got != second {
+		t.Error("expected marked reads to use a replica")
+	}
+	if got := router.pool(ReadFromReplica(ctx), "INSERT INTO users DEFAULT VALUES RETURNING id"); got != primary {
+		t.Error("expected writes to use the primary")
+	}
+	if got := router.pool(ReadFromPrimary(ReadFromReplica(ctx)), "SELECT 1"); got != primary {
+		t.Error("expected ReadFromPrimary to override ReadFromReplica")
+	}
+}
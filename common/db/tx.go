@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sqlStateSerializationFailure is returned when a serializable transaction cannot be committed
+	sqlStateSerializationFailure = "40001"
+	// sqlStateDeadlockDetected is returned when the transaction was chosen as a deadlock victim
+	sqlStateDeadlockDetected = "40P01"
+)
+
+// TxOptions configures a transaction started by WithTx
+type TxOptions struct {
+	// IsoLevel is the isolation level, defaults to the server default (read committed)
+	IsoLevel pgx.TxIsoLevel
+	// ReadOnly starts the transaction in read only access mode
+	ReadOnly bool
+	// MaxRetries is the number of times the transaction is retried on serialization
+	// failures and deadlocks, zero uses DefaultTxOptions().MaxRetries
+	MaxRetries int
+	// BaseBackoff is the initial delay between retries, doubled on each attempt
+	BaseBackoff time.Duration
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+}
+
+// DefaultTxOptions returns the default transaction options
+func DefaultTxOptions() TxOptions {
+	return TxOptions{
+		MaxRetries:  3,
+		BaseBackoff: 10 * time.Millisecond,
+		MaxBackoff:  500 * time.Millisecond,
+	}
+}
+
+func (o TxOptions) withDefaults() TxOptions {
+	def := DefaultTxOptions()
+	if o.MaxRetries == 0 {
+		o.MaxRetries = def.MaxRetries
+	}
+	if o.BaseBackoff == 0 {
+		o.BaseBackoff = def.BaseBackoff
+	}
+	if o.MaxBackoff == 0 {
+		o.MaxBackoff = def.MaxBackoff
+	}
+	return o
+}
+
+func (o TxOptions) pgxOptions() pgx.TxOptions {
+	opts := pgx.TxOptions{IsoLevel: o.IsoLevel}
+	if o.ReadOnly {
+		opts.AccessMode = pgx.ReadOnly
+	}
+	return opts
+}
+
+// TxFunc is the function executed inside a transaction
+type TxFunc func(q repository.Querier) error
+
+type txContextKey struct{}
+
+// txFromContext returns the transaction started by an enclosing WithTx call, if any
+func txFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// WithTx runs fn inside a transaction, committing when fn returns nil and rolling
+// back otherwise.
+//
+// The transaction is retried with exponential backoff when it fails with a
+// serialization failure (40001) or a deadlock (40P01), so fn must be safe to run
+// more than once. Nested calls made through WithTxContext run inside a savepoint
+// of the outer transaction instead of starting a new one, and are never retried
+// on their own.
+func (db *DB) WithTx(ctx context.Context, opts TxOptions, fn TxFunc) error {
+	return db.WithTxContext(ctx, opts, func(_ context.Context, q repository.Querier) error {
+		return fn(q)
+	})
+}
+
+// WithTxContext is like WithTx but also passes a context carrying the transaction,
+// so that nested WithTx calls made with it use savepoints
+func (db *DB) WithTxContext(ctx context.Context, opts TxOptions, fn func(ctx context.Context, q repository.Querier) error) error {
+	if parent, ok := txFromContext(ctx); ok {
+		return db.runSavepoint(ctx, parent, fn)
+	}
+
+	if db.Pool == nil {
+		return errors.New("cannot start transaction on nil database pool")
+	}
+
+	opts = opts.withDefaults()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = db.runTx(ctx, opts, fn)
+		if err == nil || !IsRetryable(err) || attempt >= opts.MaxRetries {
+			return err
+		}
+
+		delay := backoff(opts, attempt)
+		log.Warn().
+			Err(err).
+			Int("attempt", attempt+1).
+			Dur("backoff", delay).
+			Msg("Retrying transaction")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting to retry transaction: %w", errors.Join(ctx.Err(), err))
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (db *DB) runTx(ctx context.Context, opts TxOptions, fn func(ctx context.Context, q repository.Querier) error) error {
+	tx, err := db.Pool.BeginTx(ctx, opts.pgxOptions())
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+
+	return finishTx(ctx, tx, db.Queries, fn)
+}
+
+func (db *DB) runSavepoint(ctx context.Context, parent pgx.Tx, fn func(ctx context.Context, q repository.Querier) error) error {
+	// Begin on an existing pgx.Tx creates a savepoint
+	sp, err := parent.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("creating savepoint: %w", err)
+	}
+
+	return finishTx(ctx, sp, db.Queries, fn)
+}
+
+// finishTx runs fn with tx and commits or rolls it back depending on the outcome
+func finishTx(ctx context.Context, tx pgx.Tx, queries *repository.Queries, fn func(ctx context.Context, q repository.Querier) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+	}()
+
+	q := queries.WithTx(tx)
+	if err = fn(context.WithValue(ctx, txContextKey{}, tx), q); err != nil {
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			return errors.Join(err, fmt.Errorf("rolling back transaction: %w", rbErr))
+		}
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// IsRetryable reports whether err is a serialization failure or a deadlock,
+// in which case the whole transaction can safely be retried
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected
+}
+
+// backoff returns the delay before the given retry attempt using exponential
+// backoff with full jitter
+func backoff(opts TxOptions, attempt int) time.Duration {
+	delay := opts.BaseBackoff << attempt
+	if delay <= 0 || delay > opts.MaxBackoff {
+		delay = opts.MaxBackoff
+	}
+	return time.Duration(rand.Int64N(int64(delay)) + 1)
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Serialization failure", err: &pgconn.PgError{Code: "40001"}, expected: true},
+		{name: "Deadlock", err: &pgconn.PgError{Code: "40P01"}, expected: true},
+		{name: "Wrapped serialization failure", err: fmt.Errorf("committing transaction: %w", &pgconn.PgError{Code: "40001"}), expected: true},
+		{name: "Unique violation", err: &pgconn.PgError{Code: "23505"}, expected: false},
+		{name: "Plain error", err: errors.New("boom"), expected: false},
+		{name: "Nil error", err: nil, expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsRetryable(tc.err); got != tc.expected {
+				t.Errorf("IsRetryable() = %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	opts := TxOptions{BaseBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+	for attempt := 0; attempt < 70; attempt++ {
+		delay := backoff(opts, attempt)
+		if delay <= 0 || delay > opts.MaxBackoff {
+			t.Fatalf("attempt %d: backoff %v out of range (0, %v]", attempt, delay, opts.MaxBackoff)
+		}
+	}
+}
+
+func TestWithTxRequiresPool(t *testing.T) {
+	db := &DB{}
+	err := db.WithTx(t.Context(), TxOptions{}, nil)
+	if err == nil {
+		t.Fatal("expected error when starting a transaction without a pool")
+	}
+}
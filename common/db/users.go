@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/pagination"
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// UserListSchema whitelists the fields users can be sorted and filtered by
+var UserListSchema = &pagination.Schema{
+	Fields: map[string]pagination.Field{
+		"id":         {Column: "id", Type: pagination.UUID, Sortable: true, Filterable: true},
+		"email":      {Column: "email", Type: pagination.String, Sortable: true, Filterable: true},
+		"name":       {Column: "name", Type: pagination.String, Sortable: true, Filterable: true},
+		"created_at": {Column: "created_at", Type: pagination.Time, Sortable: true, Filterable: true},
+		"updated_at": {Column: "updated_at", Type: pagination.Time, Sortable: true, Filterable: true},
+	},
+	DefaultSort: []pagination.SortField{{Name: "created_at", Desc: true}},
+	TieBreaker:  "id",
+}
+
+// UserValue returns the UserListSchema field of user
+func UserValue(user repository.User, field string) any {
+	switch field {
+	case "id":
+		return user.ID
+	case "email":
+		return user.Email
+	case "name":
+		return user.Name
+	case "created_at":
+		return user.CreatedAt
+	case "updated_at":
+		return user.UpdatedAt
+	default:
+		return nil
+	}
+}
+
+const listUsers = `SELECT id, name, email, created_at, updated_at FROM users`
+
+// ListUsers returns up to p.Limit+1 users matching p, for pagination.NewPage.
+// It is not generated by sqlc because the WHERE and ORDER BY clauses are built
+// from the request.
+func (db *DB) ListUsers(ctx context.Context, p pagination.Params) ([]repository.User, error) {
+	clause, args := p.SQL(1)
+
+	var q repository.DBTX = routingDBTX{db: db}
+	if tx, ok := txFromContext(ctx); ok {
+		q = tx
+	}
+
+	rows, err := q.Query(ctx, listUsers+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	users, err := pgx.CollectRows(rows, pgx.RowToStructByPos[repository.User])
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	return users, nil
+}
@@ -0,0 +1,99 @@
+// Package idempotency stores the responses of requests made with an
+// Idempotency-Key so that retries can be answered without running them again
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Record is the stored state of an idempotent request
+type Record struct {
+	// RequestHash fingerprints the request, a key reused with another request
+	// is rejected
+	RequestHash string `json:"request_hash"`
+	// Status is the response status, zero while the request is in flight
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	// ExpiresAt is when the record may be replaced. In-flight records expire
+	// after a lock timeout so that a crashed request does not block its key.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// InFlight reports whether the request of the record has not completed
+func (r Record) InFlight() bool {
+	return r.Status == 0
+}
+
+// Store persists records. Implementations must make Reserve atomic across
+// all instances of the service sharing the store.
+type Store interface {
+	// Reserve stores rec for key unless an unexpired record exists, in which
+	// case the existing record is returned and reserved is false
+	Reserve(ctx context.Context, key string, rec Record) (existing Record, reserved bool, err error)
+	// Complete replaces the record of a reserved key with the response
+	Complete(ctx context.Context, key string, rec Record) error
+	// Release deletes the in-flight record of key so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore is a Store for a single instance, used in development and tests
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	now     func() time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]Record),
+		now:     time.Now,
+	}
+}
+
+// Reserve stores rec unless an unexpired record exists for key
+func (s *MemoryStore) Reserve(ctx context.Context, key string, rec Record) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if existing, ok := s.records[key]; ok && existing.ExpiresAt.After(now) {
+		return existing, false, nil
+	}
+
+	// Drop expired records while holding the lock anyway
+	for k, r := range s.records {
+		if !r.ExpiresAt.After(now) {
+			delete(s.records, k)
+		}
+	}
+
+	s.records[key] = rec
+	return rec, true, nil
+}
+
+// Complete stores the response for key
+func (s *MemoryStore) Complete(ctx context.Context, key string, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = rec
+	return nil
+}
+
+// Release deletes the record of key if it is still in flight
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok && rec.InFlight() {
+		delete(s.records, key)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db"
+	"github.com/LexiconIndonesia/go-http-service-template/common/idempotency"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging/natstest"
+)
+
+// testStore checks the behaviour shared by all stores
+func testStore(t *testing.T, store idempotency.Store) {
+	ctx := context.Background()
+	inFlight := idempotency.Record{RequestHash: "hash", ExpiresAt: time.Now().Add(time.Minute)}
+
+	// The first reservation wins
+	if _, reserved, err := store.Reserve(ctx, "key", inFlight); err != nil || !reserved {
+		t.Fatalf("Reserve() = %v, %v, want reserved", reserved, err)
+	}
+	existing, reserved, err := store.Reserve(ctx, "key", inFlight)
+	if err != nil || reserved {
+		t.Fatalf("Second Reserve() = %v, %v, want not reserved", reserved, err)
+	}
+	if !existing.InFlight() || existing.RequestHash != "hash" {
+		t.Errorf("Expected in-flight record, got %+v", existing)
+	}
+
+	// Completed responses are returned to later reservations
+	done := idempotency.Record{
+		RequestHash: "hash",
+		Status:      http.StatusCreated,
+		Header:      http.Header{"Content-Type": {"application/json"}},
+		Body:        []byte(`{"id":1}`),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	if err := store.Complete(ctx, "key", done); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	existing, reserved, err = store.Reserve(ctx, "key", inFlight)
+	if err != nil || reserved {
+		t.Fatalf("Reserve() after Complete() = %v, %v, want not reserved", reserved, err)
+	}
+	if existing.Status != http.StatusCreated || string(existing.Body) != `{"id":1}` || existing.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected completed record %+v", existing)
+	}
+
+	// Completed records are not released
+	if err := store.Release(ctx, "key"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, reserved, _ := store.Reserve(ctx, "key", inFlight); reserved {
+		t.Errorf("Release() deleted a completed record")
+	}
+
+	// Released in-flight records can be reserved again
+	if _, reserved, _ := store.Reserve(ctx, "other", inFlight); !reserved {
+		t.Fatalf("Expected to reserve other key")
+	}
+	if err := store.Release(ctx, "other"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, reserved, _ := store.Reserve(ctx, "other", inFlight); !reserved {
+		t.Errorf("Expected to reserve a released key")
+	}
+
+	// Expired records are replaced
+	expired := idempotency.Record{RequestHash: "old", ExpiresAt: time.Now().Add(-time.Second)}
+	if _, reserved, _ := store.Reserve(ctx, "expired", expired); !reserved {
+		t.Fatalf("Expected to reserve expired key")
+	}
+	existing, reserved, err = store.Reserve(ctx, "expired", inFlight)
+	if err != nil || !reserved || existing.RequestHash != "hash" {
+		t.Errorf("Expected to replace expired record, got %+v, %v, %v", existing, reserved, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, idempotency.NewMemoryStore())
+}
+
+func TestPostgresStoreQueries(t *testing.T) {
+	// The fake database runs the store queries in memory
+	testStore(t, idempotency.NewPostgresStore(db.NewFake()))
+}
+
+func TestKVStore(t *testing.T) {
+	client := natstest.NewClient(t)
+
+	store, err := idempotency.NewKVStore(context.Background(), client.GetJetStream(), idempotency.DefaultBucket, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKVStore() error = %v", err)
+	}
+	testStore(t, store)
+}
@@ -0,0 +1,116 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// DefaultBucket is the name of the key-value bucket used by NewKVStore
+const DefaultBucket = "IDEMPOTENCY"
+
+// KVStore is a Store backed by a JetStream key-value bucket. Keys must be
+// valid KV keys, the middleware uses hex encoded hashes.
+type KVStore struct {
+	kv  jetstream.KeyValue
+	now func() time.Time
+}
+
+var _ Store = (*KVStore)(nil)
+
+// NewKVStore creates or updates the bucket and returns a store using it.
+// Entries are removed by the bucket after ttl, which should not be shorter
+// than the record lifetime used by the middleware.
+func NewKVStore(ctx context.Context, js jetstream.JetStream, bucket string, ttl time.Duration) (*KVStore, error) {
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      bucket,
+		Description: "Idempotency-Key responses",
+		TTL:         ttl,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating idempotency bucket: %w", err)
+	}
+	return &KVStore{kv: kv, now: time.Now}, nil
+}
+
+// Reserve creates the entry for key, or replaces an expired one using the
+// entry revision so that only one instance wins
+func (s *KVStore) Reserve(ctx context.Context, key string, rec Record) (Record, bool, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("encoding idempotency record: %w", err)
+	}
+
+	for {
+		_, err := s.kv.Create(ctx, key, data)
+		if err == nil {
+			return rec, true, nil
+		}
+		if !errors.Is(err, jetstream.ErrKeyExists) {
+			return Record{}, false, fmt.Errorf("reserving idempotency key: %w", err)
+		}
+
+		entry, err := s.kv.Get(ctx, key)
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			// Released in the meantime
+			continue
+		}
+		if err != nil {
+			return Record{}, false, fmt.Errorf("getting idempotency key: %w", err)
+		}
+
+		var existing Record
+		if err := json.Unmarshal(entry.Value(), &existing); err != nil {
+			return Record{}, false, fmt.Errorf("decoding idempotency record: %w", err)
+		}
+		if existing.ExpiresAt.After(s.now()) {
+			return existing, false, nil
+		}
+
+		_, err = s.kv.Update(ctx, key, data, entry.Revision())
+		if err == nil {
+			return rec, true, nil
+		}
+		var apiErr *jetstream.APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorCode != jetstream.JSErrCodeStreamWrongLastSequence {
+			return Record{}, false, fmt.Errorf("replacing idempotency key: %w", err)
+		}
+		// Another instance replaced it first, read it again
+	}
+}
+
+// Complete stores the response of a reserved key
+func (s *KVStore) Complete(ctx context.Context, key string, rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding idempotency record: %w", err)
+	}
+	if _, err := s.kv.Put(ctx, key, data); err != nil {
+		return fmt.Errorf("completing idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release deletes the entry of key if it is still in flight
+func (s *KVStore) Release(ctx context.Context, key string) error {
+	entry, err := s.kv.Get(ctx, key)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting idempotency key: %w", err)
+	}
+
+	var rec Record
+	if err := json.Unmarshal(entry.Value(), &rec); err != nil || !rec.InFlight() {
+		return nil
+	}
+	if err := s.kv.Delete(ctx, key, jetstream.LastRevision(entry.Revision())); err != nil {
+		return fmt.Errorf("releasing idempotency key: %w", err)
+	}
+	return nil
+}
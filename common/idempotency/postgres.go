@@ -0,0 +1,114 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// purgeInterval is the minimum time between deletions of expired records
+const purgeInterval = time.Minute
+
+// PostgresStore is a Store backed by the idempotency_keys table
+type PostgresStore struct {
+	queries   repository.Querier
+	lastPurge atomic.Int64
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore creates a store running the queries of q, usually on the
+// primary pool
+func NewPostgresStore(q repository.Querier) *PostgresStore {
+	return &PostgresStore{queries: q}
+}
+
+// Reserve inserts rec, or replaces an expired record, in a single statement
+func (s *PostgresStore) Reserve(ctx context.Context, key string, rec Record) (Record, bool, error) {
+	s.purge(ctx)
+
+	for {
+		_, err := s.queries.ReserveIdempotencyKey(ctx, repository.ReserveIdempotencyKeyParams{
+			Key:         key,
+			RequestHash: rec.RequestHash,
+			ExpiresAt:   rec.ExpiresAt,
+		})
+		if err == nil {
+			return rec, true, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return Record{}, false, fmt.Errorf("reserving idempotency key: %w", err)
+		}
+
+		// An unexpired record exists, it may have been released in the
+		// meantime, in which case the insert is tried again
+		row, err := s.queries.GetIdempotencyKey(ctx, key)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, fmt.Errorf("getting idempotency key: %w", err)
+		}
+
+		existing := Record{
+			RequestHash: row.RequestHash,
+			Status:      int(row.Status),
+			Body:        row.Body,
+			ExpiresAt:   row.ExpiresAt,
+		}
+		if row.Header != nil {
+			if err := json.Unmarshal(row.Header, &existing.Header); err != nil {
+				return Record{}, false, fmt.Errorf("decoding idempotency key headers: %w", err)
+			}
+		}
+		return existing, false, nil
+	}
+}
+
+// Complete stores the response of a reserved key
+func (s *PostgresStore) Complete(ctx context.Context, key string, rec Record) error {
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return fmt.Errorf("encoding idempotency key headers: %w", err)
+	}
+	err = s.queries.CompleteIdempotencyKey(ctx, repository.CompleteIdempotencyKeyParams{
+		Key:       key,
+		Status:    int32(rec.Status),
+		Header:    header,
+		Body:      rec.Body,
+		ExpiresAt: rec.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("completing idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release deletes the in-flight record of key
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	if err := s.queries.ReleaseIdempotencyKey(ctx, key); err != nil {
+		return fmt.Errorf("releasing idempotency key: %w", err)
+	}
+	return nil
+}
+
+// purge deletes expired records at most once per purgeInterval. Expired
+// records are replaced by Reserve anyway, this only keeps the table small.
+func (s *PostgresStore) purge(ctx context.Context) {
+	now := time.Now().UnixNano()
+	last := s.lastPurge.Load()
+	if now-last < int64(purgeInterval) || !s.lastPurge.CompareAndSwap(last, now) {
+		return
+	}
+
+	if err := s.queries.PurgeIdempotencyKeys(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to purge expired idempotency keys")
+	}
+}
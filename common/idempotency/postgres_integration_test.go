@@ -0,0 +1,15 @@
+//go:build integration
+
+package idempotency_test
+
+import (
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db/dbtest"
+	"github.com/LexiconIndonesia/go-http-service-template/common/idempotency"
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+)
+
+func TestPostgresStoreIntegration(t *testing.T) {
+	testStore(t, idempotency.NewPostgresStore(repository.New(dbtest.New(t).Pool)))
+}
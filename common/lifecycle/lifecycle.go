@@ -0,0 +1,180 @@
+// Package lifecycle starts and stops the components of the service in a fixed
+// order, so that traffic stops before the connections it needs are closed
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultTimeout bounds hooks that do not set their own timeout
+const DefaultTimeout = 10 * time.Second
+
+// Phase orders the hooks on shutdown. Hooks stop phase by phase in the order
+// below and start in the reverse order, so components are running before
+// the ones depending on them.
+type Phase int
+
+const (
+	// PhaseIngress stops accepting traffic and drains in-flight requests
+	PhaseIngress Phase = iota
+	// PhaseConsumers stops message consumers and waits for their handlers
+	PhaseConsumers
+	// PhaseFlush flushes outstanding outgoing messages
+	PhaseFlush
+	// PhaseClose closes connections and pools
+	PhaseClose
+)
+
+// String returns the name of the phase used in logs
+func (p Phase) String() string {
+	switch p {
+	case PhaseIngress:
+		return "ingress"
+	case PhaseConsumers:
+		return "consumers"
+	case PhaseFlush:
+		return "flush"
+	case PhaseClose:
+		return "close"
+	default:
+		return fmt.Sprintf("phase(%d)", int(p))
+	}
+}
+
+// Hook is a component managed by a Manager
+type Hook struct {
+	Name  string
+	Phase Phase
+	// Start starts the component, nil for components that are already
+	// running when registered
+	Start func(ctx context.Context) error
+	// Stop stops the component, its context is done after Timeout
+	Stop func(ctx context.Context) error
+	// Timeout bounds Stop, DefaultTimeout when zero
+	Timeout time.Duration
+}
+
+type entry struct {
+	Hook
+	started bool
+}
+
+// Manager runs the hooks registered by the components of the service
+type Manager struct {
+	mu    sync.Mutex
+	hooks []*entry
+}
+
+// New creates a manager without hooks
+func New() *Manager {
+	return &Manager{}
+}
+
+// Register adds a hook. Hooks without Start are considered started.
+func (m *Manager) Register(h Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks = append(m.hooks, &entry{Hook: h, started: h.Start == nil})
+}
+
+// Start starts the hooks from the last phase to the first, in registration
+// order within a phase. When a hook fails the started hooks are stopped.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := slices.Clone(m.hooks)
+	m.mu.Unlock()
+
+	slices.SortStableFunc(hooks, func(a, b *entry) int { return int(b.Phase) - int(a.Phase) })
+
+	for _, h := range hooks {
+		if h.started {
+			continue
+		}
+
+		log.Info().Str("component", h.Name).Str("phase", h.Phase.String()).Msg("Starting component")
+		if err := h.Start(ctx); err != nil {
+			err = fmt.Errorf("starting %s: %w", h.Name, err)
+			if stopErr := m.Stop(ctx); stopErr != nil {
+				return errors.Join(err, stopErr)
+			}
+			return err
+		}
+
+		m.mu.Lock()
+		h.started = true
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Stop stops the started hooks phase by phase, in reverse registration order
+// within a phase. Each hook gets its own timeout within ctx, and a hook that
+// does not return in time is abandoned so the next ones still run.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := slices.Clone(m.hooks)
+	m.mu.Unlock()
+
+	slices.Reverse(hooks)
+	slices.SortStableFunc(hooks, func(a, b *entry) int { return int(a.Phase) - int(b.Phase) })
+
+	var errs []error
+	phase := Phase(-1)
+	for _, h := range hooks {
+		m.mu.Lock()
+		started := h.started
+		h.started = false
+		m.mu.Unlock()
+		if !started || h.Stop == nil {
+			continue
+		}
+
+		if h.Phase != phase {
+			phase = h.Phase
+			log.Info().Str("phase", phase.String()).Msg("Shutdown phase")
+		}
+
+		if err := stop(ctx, h.Hook); err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", h.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stop runs the Stop function of h within its timeout
+func stop(ctx context.Context, h Hook) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Stop(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	logger := log.Info()
+	if err != nil {
+		logger = log.Error().Err(err)
+	}
+	logger.Str("component", h.Name).Dur("elapsed", time.Since(start)).Msg("Stopped component")
+	return err
+}
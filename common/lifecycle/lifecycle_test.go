@@ -0,0 +1,108 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/lifecycle"
+)
+
+// recorder records the calls of the hooks it creates
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) hook(name string, phase lifecycle.Phase, startErr error) lifecycle.Hook {
+	return lifecycle.Hook{
+		Name:  name,
+		Phase: phase,
+		Start: func(ctx context.Context) error {
+			r.calls = append(r.calls, "start "+name)
+			return startErr
+		},
+		Stop: func(ctx context.Context) error {
+			r.calls = append(r.calls, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func TestManager(t *testing.T) {
+	rec := &recorder{}
+	m := lifecycle.New()
+	m.Register(rec.hook("db", lifecycle.PhaseClose, nil))
+	m.Register(rec.hook("nats", lifecycle.PhaseClose, nil))
+	m.Register(rec.hook("publishes", lifecycle.PhaseFlush, nil))
+	m.Register(rec.hook("http", lifecycle.PhaseIngress, nil))
+	m.Register(rec.hook("consumers", lifecycle.PhaseConsumers, nil))
+
+	ctx := context.Background()
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := m.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	expected := []string{
+		"start db", "start nats", "start publishes", "start consumers", "start http",
+		"stop http", "stop consumers", "stop publishes", "stop nats", "stop db",
+	}
+	if !slices.Equal(rec.calls, expected) {
+		t.Errorf("Unexpected calls:\ngot  %v\nwant %v", rec.calls, expected)
+	}
+
+	// Stopped hooks are not stopped again
+	rec.calls = nil
+	if err := m.Stop(ctx); err != nil || len(rec.calls) != 0 {
+		t.Errorf("Expected a second Stop() to do nothing, got %v, %v", rec.calls, err)
+	}
+}
+
+func TestManagerStartFailure(t *testing.T) {
+	rec := &recorder{}
+	m := lifecycle.New()
+	m.Register(rec.hook("db", lifecycle.PhaseClose, nil))
+	m.Register(rec.hook("http", lifecycle.PhaseIngress, errors.New("address in use")))
+
+	if err := m.Start(context.Background()); err == nil {
+		t.Fatal("Expected Start() to fail")
+	}
+
+	expected := []string{"start db", "start http", "stop db"}
+	if !slices.Equal(rec.calls, expected) {
+		t.Errorf("Expected started hooks to be stopped:\ngot  %v\nwant %v", rec.calls, expected)
+	}
+}
+
+func TestManagerStopTimeout(t *testing.T) {
+	var closed bool
+	m := lifecycle.New()
+	m.Register(lifecycle.Hook{
+		Name:    "stuck",
+		Phase:   lifecycle.PhaseIngress,
+		Timeout: 10 * time.Millisecond,
+		Stop: func(ctx context.Context) error {
+			select {}
+		},
+	})
+	m.Register(lifecycle.Hook{
+		Name:  "db",
+		Phase: lifecycle.PhaseClose,
+		Stop: func(ctx context.Context) error {
+			closed = true
+			return nil
+		},
+	})
+
+	err := m.Stop(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error, got %v", err)
+	}
+	if !closed {
+		t.Errorf("Expected later hooks to run after a timeout")
+	}
+}
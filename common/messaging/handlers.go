@@ -2,6 +2,7 @@ package messaging
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -117,7 +118,7 @@ func SubscribeToJetStream(client *NatsClient, streamName, subject string, handle
 	}
 
 	// Create a durable consumer for the subject
-	consumerName := "consumer_" + subject
+	consumerName := ConsumerName(subject)
 	consumerConfig := jetstream.ConsumerConfig{
 		Name:          consumerName,
 		FilterSubject: subject,
@@ -148,11 +149,12 @@ func SubscribeToJetStream(client *NatsClient, streamName, subject string, handle
 		}
 	}
 
-	// Start consuming messages
-	_, err = consumer.Consume(msgHandler)
+	// Start consuming messages, stopped by StopConsumers on shutdown
+	consumeCtx, err := consumer.Consume(msgHandler)
 	if err != nil {
 		return nil, err
 	}
+	client.trackConsumer(consumeCtx)
 
 	log.Info().
 		Str("stream", streamName).
@@ -168,6 +170,14 @@ func SubscribeToAllJetStream(client *NatsClient, streamName string, handler JetS
 	return SubscribeToJetStream(client, streamName, ">", handler)
 }
 
+// ConsumerName returns the durable consumer name used for a subject. Consumer
+// names cannot contain token separators or wildcards, so they are replaced.
+func ConsumerName(subject string) string {
+	return "consumer_" + consumerNameReplacer.Replace(subject)
+}
+
+var consumerNameReplacer = strings.NewReplacer(".", "_", "*", "any", ">", "all")
+
 // EnsureStream ensures a stream exists with the specified subjects
 func EnsureStream(ctx context.Context, client *NatsClient, name string, subjects []string) (jetstream.Stream, error) {
 	// Try to get the stream first
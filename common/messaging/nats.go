@@ -44,7 +44,11 @@ type NatsClient struct {
 	js          jetstream.JetStream
 	config      Config
 	subscribers map[string]*nats.Subscription
-	mu          sync.Mutex
+	// consumers are the JetStream consume contexts stopped by StopConsumers
+	consumers []jetstream.ConsumeContext
+	// closed is closed once the connection is closed
+	closed chan struct{}
+	mu     sync.Mutex
 }
 
 // NewNatsClient creates a new NATS client
@@ -69,6 +73,7 @@ func NewNatsClient(config Config) (*NatsClient, error) {
 	client := &NatsClient{
 		config:      config,
 		subscribers: make(map[string]*nats.Subscription),
+		closed:      make(chan struct{}),
 	}
 
 	// Connect to NATS
@@ -103,6 +108,9 @@ func (c *NatsClient) connect() error {
 		}),
 		nats.ClosedHandler(func(nc *nats.Conn) {
 			log.Info().Msg("NATS connection closed")
+			if c.closed != nil {
+				close(c.closed)
+			}
 		}),
 	}
 
@@ -117,8 +125,12 @@ func (c *NatsClient) connect() error {
 		return fmt.Errorf("failed to connect to NATS: %w", err)
 	}
 
-	// Create JetStream context
-	js, err := jetstream.New(c.conn)
+	// Create JetStream context, logging failed async publishes
+	js, err := jetstream.New(c.conn, jetstream.WithPublishAsyncErrHandler(func(_ jetstream.JetStream, msg *nats.Msg, err error) {
+		log.Error().Err(err).
+			Str("subject", msg.Subject).
+			Msg("Error publishing message")
+	}))
 	if err != nil {
 		return fmt.Errorf("failed to create JetStream context: %w", err)
 	}
@@ -128,18 +140,97 @@ func (c *NatsClient) connect() error {
 	return nil
 }
 
-// Close closes the NATS connection
+// Close drains the NATS connection, letting subscriptions process the
+// messages they already received, and waits until it is closed. The drain is
+// bounded by the drain timeout of the connection.
 func (c *NatsClient) Close() error {
+	return c.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but stops waiting for the drain when ctx is done,
+// closing the connection right away
+func (c *NatsClient) CloseContext(ctx context.Context) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	conn, closed := c.conn, c.closed
+	if conn == nil || conn.IsClosed() {
+		c.mu.Unlock()
+		return nil
+	}
+
+	// A connection that is not connected cannot drain
+	if !conn.IsConnected() {
+		conn.Close()
+		c.mu.Unlock()
+		return nil
+	}
+
+	err := conn.Drain()
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("draining NATS connection: %w", err)
+	}
+
+	if closed == nil {
+		return nil
+	}
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		conn.Close()
+		return fmt.Errorf("waiting for the NATS connection to drain: %w", ctx.Err())
+	}
+}
+
+// StopConsumers drains the JetStream consumers started through the client
+// and waits until their handlers have processed the buffered messages
+func (c *NatsClient) StopConsumers(ctx context.Context) error {
+	c.mu.Lock()
+	consumers := c.consumers
+	c.consumers = nil
+	c.mu.Unlock()
+
+	for _, cc := range consumers {
+		cc.Drain()
+	}
+	for _, cc := range consumers {
+		select {
+		case <-cc.Closed():
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for JetStream consumers: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// FlushPublishes waits until the server acknowledged the messages published
+// with PublishAsync and flushes the connection
+func (c *NatsClient) FlushPublishes(ctx context.Context) error {
+	if c.js != nil {
+		if pending := c.js.PublishAsyncPending(); pending > 0 {
+			log.Info().Int("pending", pending).Msg("Waiting for JetStream publish acknowledgements")
+		}
+		select {
+		case <-c.js.PublishAsyncComplete():
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for JetStream publish acknowledgements: %w", ctx.Err())
+		}
+	}
 
-	// Drain the connection (gracefully unsubscribe)
 	if c.conn != nil && c.conn.IsConnected() {
-		return c.conn.Drain()
+		return c.conn.FlushWithContext(ctx)
 	}
 	return nil
 }
 
+// trackConsumer registers cc to be stopped by StopConsumers
+func (c *NatsClient) trackConsumer(cc jetstream.ConsumeContext) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.consumers = append(c.consumers, cc)
+}
+
 // Publish publishes a message to a subject
 func (c *NatsClient) Publish(subject string, data []byte) error {
 	if c.conn == nil || !c.conn.IsConnected() {
@@ -149,46 +240,19 @@ func (c *NatsClient) Publish(subject string, data []byte) error {
 	return c.conn.Publish(subject, data)
 }
 
-// PublishAsync publishes a message to a subject asynchronously
+// PublishAsync publishes a message to a subject asynchronously. The caller owns
+// the returned future: only one reader receives the acknowledgement. Publish
+// errors are also logged by the JetStream error handler.
 func (c *NatsClient) PublishAsync(subject string, data []byte) (jetstream.PubAckFuture, error) {
 	if c.js == nil {
 		return nil, fmt.Errorf("JetStream not initialized")
 	}
 
-	// Publish to JetStream with context
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
 	ack, err := c.js.PublishAsync(subject, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to publish message to %s: %w", subject, err)
 	}
 
-	// Wait for ack in a goroutine
-	go func() {
-		select {
-		case pubAck := <-ack.Ok():
-			// Message was received by server
-			if pubAck != nil {
-				log.Debug().Str("subject", subject).
-					Str("stream", pubAck.Stream).
-					Uint64("seq", pubAck.Sequence).
-					Msg("Message acknowledged")
-			}
-		case err := <-ack.Err():
-			// There was an error with the message
-			if err != nil {
-				log.Error().Str("error", err.Error()).
-					Str("subject", subject).
-					Msg("Error publishing message")
-			}
-		case <-ctx.Done():
-			// Timeout waiting for ack
-			log.Warn().Str("subject", subject).
-				Msg("Timeout waiting for message acknowledgement")
-		}
-	}()
-
 	return ack, nil
 }
 
@@ -316,6 +380,7 @@ func (c *NatsClient) Consume(ctx context.Context, consumer jetstream.Consumer, h
 	if err != nil {
 		return nil, fmt.Errorf("failed to consume from consumer: %w", err)
 	}
+	c.trackConsumer(consumeCtx)
 
 	return consumeCtx, nil
 }
@@ -0,0 +1,385 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging/natstest"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestEnsureStream(t *testing.T) {
+	client := natstest.NewClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := messaging.EnsureStream(ctx, client, "TEST", []string{"test.>"})
+	if err != nil {
+		t.Fatalf("EnsureStream() error = %v", err)
+	}
+
+	// Calling it again returns the existing stream
+	again, err := messaging.EnsureStream(ctx, client, "TEST", []string{"other.>"})
+	if err != nil {
+		t.Fatalf("EnsureStream() second call error = %v", err)
+	}
+	if again.CachedInfo().Config.Name != stream.CachedInfo().Config.Name {
+		t.Errorf("expected the existing stream to be returned")
+	}
+
+	info, err := again.Info(ctx)
+	if err != nil {
+		t.Fatalf("Info() error = %v", err)
+	}
+	if len(info.Config.Subjects) != 1 || info.Config.Subjects[0] != "test.>" {
+		t.Errorf("expected existing subjects to be kept, got %v", info.Config.Subjects)
+	}
+}
+
+func TestPublishAsync(t *testing.T) {
+	client := natstest.NewClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := messaging.EnsureStream(ctx, client, "TEST", []string{"test.>"}); err != nil {
+		t.Fatalf("EnsureStream() error = %v", err)
+	}
+
+	ack, err := client.PublishAsync("test.subject", []byte(`{"message":"hello"}`))
+	if err != nil {
+		t.Fatalf("PublishAsync() error = %v", err)
+	}
+
+	select {
+	case pubAck := <-ack.Ok():
+		if pubAck.Stream != "TEST" {
+			t.Errorf("expected message to be stored in TEST, got %s", pubAck.Stream)
+		}
+		if pubAck.Sequence != 1 {
+			t.Errorf("expected sequence 1, got %d", pubAck.Sequence)
+		}
+	case err := <-ack.Err():
+		t.Fatalf("publish failed: %v", err)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for publish acknowledgement")
+	}
+}
+
+func TestSubscribeToJetStream(t *testing.T) {
+	client := natstest.NewClient(t)
+
+	received := make(chan jetstream.Msg, 1)
+	_, err := messaging.SubscribeToJetStream(client, "TEST", "test.subject", func(msg jetstream.Msg) error {
+		received <- msg
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubscribeToJetStream() error = %v", err)
+	}
+
+	if _, err := client.PublishAsync("test.subject", []byte("hello")); err != nil {
+		t.Fatalf("PublishAsync() error = %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if string(msg.Data()) != "hello" {
+			t.Errorf("expected data hello, got %s", msg.Data())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for JetStream message")
+	}
+}
+
+func TestSubscribeToJetStreamWildcard(t *testing.T) {
+	client := natstest.NewClient(t)
+
+	received := make(chan jetstream.Msg, 1)
+	_, err := messaging.SubscribeToJetStream(client, "EVENTS", "events.>", func(msg jetstream.Msg) error {
+		received <- msg
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubscribeToJetStream() error = %v", err)
+	}
+
+	if _, err := client.PublishAsync("events.user.created", []byte("created")); err != nil {
+		t.Fatalf("PublishAsync() error = %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg.Subject() != "events.user.created" {
+			t.Errorf("expected subject events.user.created, got %s", msg.Subject())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for JetStream message")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	client := natstest.NewClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	_, err := messaging.SubscribeToJetStream(client, "TEST", "test.subject", func(msg jetstream.Msg) error {
+		close(started)
+		<-release
+		close(finished)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SubscribeToJetStream() error = %v", err)
+	}
+
+	if _, err := client.PublishAsync("test.subject", []byte("hello")); err != nil {
+		t.Fatalf("PublishAsync() error = %v", err)
+	}
+	if err := client.FlushPublishes(ctx); err != nil {
+		t.Fatalf("FlushPublishes() error = %v", err)
+	}
+
+	select {
+	case <-started:
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for JetStream message")
+	}
+
+	// StopConsumers waits for the handler in progress
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- client.StopConsumers(ctx)
+	}()
+	select {
+	case err := <-stopped:
+		t.Fatalf("StopConsumers() returned before the handler finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("StopConsumers() error = %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Errorf("expected the handler to finish before StopConsumers returned")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !client.GetConn().IsClosed() {
+		t.Errorf("expected Close() to wait for the connection to close")
+	}
+}
+
+func TestCloseContext(t *testing.T) {
+	client := natstest.NewClient(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	if _, err := client.Subscribe("test.block", func(msg *nats.Msg) {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := client.GetConn().Publish("test.block", nil); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	<-started
+
+	// The drain waits for the blocked handler, ctx bounds the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	closed := make(chan error, 1)
+	go func() {
+		closed <- client.CloseContext(ctx)
+	}()
+
+	// The client is not locked while the drain is awaited
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- client.StopConsumers(context.Background())
+	}()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("StopConsumers() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StopConsumers() blocked while CloseContext() waited")
+	}
+
+	select {
+	case err := <-closed:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("CloseContext() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("CloseContext() did not return when its context expired")
+	}
+	if !client.GetConn().IsClosed() {
+		t.Error("expected CloseContext() to close the connection")
+	}
+}
+
+func TestRequestPolicyAllowed(t *testing.T) {
+	policy := messaging.RequestPolicy{Subjects: []string{"users.lookup", "orders.*.status", "reports.>"}}
+
+	tests := []struct {
+		subject string
+		allowed bool
+	}{
+		{"users.lookup", true},
+		{"users.lookup.extra", false},
+		{"users", false},
+		{"orders.42.status", true},
+		{"orders.status", false},
+		{"orders.42.total", false},
+		{"reports.daily", true},
+		{"reports.daily.pdf", true},
+		{"reports", false},
+		{"payments.refund", false},
+		{"reports.*", false},
+		{"reports.>", false},
+		{"orders..status", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			if got := policy.Allowed(tt.subject); got != tt.allowed {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.subject, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestLiteralSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		literal bool
+	}{
+		{"users.lookup", true},
+		{"users-v2.lookup_by_id", true},
+		{"", false},
+		{"users.*", false},
+		{"users.>", false},
+		{"users.look*", false},
+		{"users..lookup", false},
+		{".users", false},
+		{"users.", false},
+		{"users.look up", false},
+		{"users.lookup\t", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			if got := messaging.LiteralSubject(tt.subject); got != tt.literal {
+				t.Errorf("LiteralSubject(%q) = %v, want %v", tt.subject, got, tt.literal)
+			}
+		})
+	}
+}
+
+func TestRequestPolicyReplyTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeout   time.Duration
+		requested time.Duration
+		expected  time.Duration
+	}{
+		{"policy timeout", 2 * time.Second, 0, 2 * time.Second},
+		{"shorter request", 2 * time.Second, time.Second, time.Second},
+		{"capped request", 2 * time.Second, time.Minute, 2 * time.Second},
+		{"default timeout", 0, 0, messaging.DefaultRequestTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := messaging.RequestPolicy{Timeout: tt.timeout}
+			if got := policy.ReplyTimeout(tt.requested); got != tt.expected {
+				t.Errorf("ReplyTimeout(%v) = %v, want %v", tt.requested, got, tt.expected)
+			}
+		})
+	}
+}
+
+type greetRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type greetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func TestRespond(t *testing.T) {
+	client := natstest.NewClient(t)
+
+	_, err := messaging.Respond(client, "greet", "greeters", func(ctx context.Context, req greetRequest) (greetResponse, error) {
+		if req.Name == "nobody" {
+			return greetResponse{}, problem.NotFound("Nobody to greet")
+		}
+		messaging.ReplyHeader(ctx).Set("Tenant", messaging.RequestHeader(ctx).Get("Tenant"))
+		return greetResponse{Greeting: "Hello " + req.Name}, nil
+	})
+	if err != nil {
+		t.Fatalf("Respond() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		data           string
+		expectedStatus int
+		expectedData   string
+	}{
+		{"reply", `{"name":"Jane"}`, 0, `{"greeting":"Hello Jane"}`},
+		{"handler error", `{"name":"nobody"}`, http.StatusNotFound, ""},
+		{"validation error", `{}`, http.StatusBadRequest, ""},
+		{"invalid json", `{`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			msg := nats.NewMsg("greet")
+			msg.Data = []byte(tt.data)
+			msg.Header.Set("Tenant", "acme")
+			reply, err := client.RequestMsg(ctx, msg)
+			if err != nil {
+				t.Fatalf("RequestMsg() error = %v", err)
+			}
+
+			perr := messaging.ReplyError(reply)
+			if tt.expectedStatus == 0 {
+				if perr != nil {
+					t.Fatalf("ReplyError() = %v, want nil", perr)
+				}
+				if string(reply.Data) != tt.expectedData {
+					t.Errorf("Reply data = %s, want %s", reply.Data, tt.expectedData)
+				}
+				if tenant := reply.Header.Get("Tenant"); tenant != "acme" {
+					t.Errorf("Reply header Tenant = %q, want %q", tenant, "acme")
+				}
+				return
+			}
+			if perr == nil || perr.Status != tt.expectedStatus {
+				t.Errorf("ReplyError() = %v, want status %v", perr, tt.expectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Package natstest runs an in-process NATS server with JetStream for tests
+package natstest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// readyTimeout bounds how long to wait for the server to accept connections
+const readyTimeout = 10 * time.Second
+
+// NewServer starts a NATS server with JetStream enabled on a random local port,
+// storing data in a temporary directory. It is shut down when the test finishes.
+func NewServer(t testing.TB) *server.Server {
+	t.Helper()
+
+	opts := &server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	}
+
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatalf("creating NATS server: %v", err)
+	}
+
+	go srv.Start()
+	if !srv.ReadyForConnections(readyTimeout) {
+		srv.Shutdown()
+		t.Fatal("NATS server not ready for connections")
+	}
+
+	t.Cleanup(func() {
+		srv.Shutdown()
+		srv.WaitForShutdown()
+	})
+	return srv
+}
+
+// NewClient starts a NATS server and returns a client connected to it. The
+// client is closed when the test finishes.
+func NewClient(t testing.TB) *messaging.NatsClient {
+	t.Helper()
+
+	srv := NewServer(t)
+
+	config := messaging.DefaultConfig()
+	config.URL = srv.ClientURL()
+
+	client, err := messaging.NewNatsClient(config)
+	if err != nil {
+		t.Fatalf("creating NATS client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
@@ -0,0 +1,96 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultRequestTimeout bounds the wait for a reply when the policy has no
+// timeout
+const DefaultRequestTimeout = 5 * time.Second
+
+// RequestPolicy restricts the subjects that may be requested through the HTTP
+// bridge and bounds how long a reply is awaited
+type RequestPolicy struct {
+	// Subjects are the allowed subjects, with the NATS wildcards * for one
+	// token and > for the remaining tokens. Empty allows nothing.
+	Subjects []string
+	// Timeout is the default and maximum time to wait for a reply
+	Timeout time.Duration
+}
+
+// Allowed reports whether subject is literal and matches one of the allowed
+// subjects. Wildcards in subject would reach every matching responder, so they
+// are never allowed.
+func (p RequestPolicy) Allowed(subject string) bool {
+	if !LiteralSubject(subject) {
+		return false
+	}
+	for _, pattern := range p.Subjects {
+		if subjectMatches(pattern, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplyTimeout returns how long to wait for a reply: requested when it is
+// positive and shorter than the policy timeout
+func (p RequestPolicy) ReplyTimeout(requested time.Duration) time.Duration {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	if requested > 0 {
+		return min(timeout, requested)
+	}
+	return timeout
+}
+
+// LiteralSubject reports whether subject is a valid subject without wildcards:
+// dot separated tokens that are not empty and contain no whitespace, * or >
+func LiteralSubject(subject string) bool {
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" || strings.ContainsAny(token, "*> \t\r\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// subjectMatches reports whether subject matches pattern, token by token
+func subjectMatches(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range patternTokens {
+		if token == ">" {
+			return i == len(patternTokens)-1 && len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) {
+			return false
+		}
+		if token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
+// RequestMsg sends msg as a request and waits for the reply until ctx is done.
+// It returns nats.ErrNoResponders when nothing listens on the subject.
+func (c *NatsClient) RequestMsg(ctx context.Context, msg *nats.Msg) (*nats.Msg, error) {
+	if c.conn == nil || !c.conn.IsConnected() {
+		return nil, fmt.Errorf("not connected to NATS")
+	}
+
+	reply, err := c.conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s: %w", msg.Subject, err)
+	}
+	return reply, nil
+}
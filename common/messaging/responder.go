@@ -0,0 +1,153 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/validation"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog/log"
+)
+
+// Headers of error replies, the same as the nats.go micro package uses
+const (
+	// ErrorHeader holds the problem detail of an error reply
+	ErrorHeader = "Nats-Service-Error"
+	// ErrorCodeHeader holds the HTTP status of an error reply
+	ErrorCodeHeader = "Nats-Service-Error-Code"
+)
+
+// RequestHandler is a typed NATS request handler. It receives the decoded and
+// validated request and returns the reply data, or an error that is replied as
+// a problem.
+type RequestHandler[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+type headerKey struct{}
+
+// messageHeaders are the headers of a request and of its reply
+type messageHeaders struct {
+	request nats.Header
+	reply   nats.Header
+}
+
+// RequestHeader returns the headers of the request handled by a
+// RequestHandler, nil outside of one
+func RequestHeader(ctx context.Context) nats.Header {
+	if h, ok := ctx.Value(headerKey{}).(*messageHeaders); ok {
+		return h.request
+	}
+	return nil
+}
+
+// ReplyHeader returns the headers of the reply of a RequestHandler, which
+// handlers may set. Outside of a handler it returns a header that is dropped.
+func ReplyHeader(ctx context.Context) nats.Header {
+	if h, ok := ctx.Value(headerKey{}).(*messageHeaders); ok {
+		return h.reply
+	}
+	return nats.Header{}
+}
+
+// Respond subscribes h to requests on subject, in the queue group queue when it
+// is not empty so that instances share the requests. Request data is decoded
+// from JSON and validated with the shared validator, and the reply is the JSON
+// encoded response. Errors are replied as problem+json bodies with the
+// ErrorHeader and ErrorCodeHeader headers set.
+func Respond[Req, Resp any](client *NatsClient, subject, queue string, h RequestHandler[Req, Resp]) (*nats.Subscription, error) {
+	handler := func(msg *nats.Msg) {
+		headers := &messageHeaders{request: msg.Header, reply: nats.Header{}}
+		ctx := context.WithValue(context.Background(), headerKey{}, headers)
+
+		resp, err := handle(ctx, msg, h)
+		var data []byte
+		if err == nil {
+			data, err = json.Marshal(resp)
+			if err != nil {
+				err = problem.Internal("Failed to encode reply", err)
+			}
+		}
+		if err != nil {
+			data = errorReply(msg.Subject, headers.reply, err)
+		}
+
+		reply := &nats.Msg{Subject: msg.Reply, Header: headers.reply, Data: data}
+		if err := msg.RespondMsg(reply); err != nil {
+			log.Error().Err(err).Str("subject", msg.Subject).Msg("Failed to reply to request")
+		}
+	}
+
+	if queue != "" {
+		return client.QueueSubscribe(subject, queue, handler)
+	}
+	return client.Subscribe(subject, handler)
+}
+
+// handle decodes, validates and handles a request
+func handle[Req, Resp any](ctx context.Context, msg *nats.Msg, h RequestHandler[Req, Resp]) (Resp, error) {
+	var req Req
+	var zero Resp
+
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return zero, problem.BadRequest("Request data must be valid JSON").Wrap(err)
+		}
+	}
+	if err := validation.Struct(req); err != nil {
+		if fieldErrors := validation.FieldErrors(err, validation.English); fieldErrors != nil {
+			return zero, problem.Validation("Request validation failed", fieldErrors)
+		}
+		return zero, problem.BadRequest("Invalid request").Wrap(err)
+	}
+	return h(ctx, req)
+}
+
+// errorReply sets the error headers of a reply and returns its problem body.
+// Server errors are logged with their cause, which is not replied.
+func errorReply(subject string, header nats.Header, err error) []byte {
+	p := problem.FromError(err)
+	if p.Status >= http.StatusInternalServerError {
+		log.Error().Err(err).Int("status", p.Status).Str("subject", subject).Msg("Request failed")
+	}
+	p.Instance = subject
+
+	header.Set(ErrorHeader, p.Detail)
+	header.Set(ErrorCodeHeader, strconv.Itoa(p.Status))
+	header.Set("Content-Type", problem.ContentType)
+
+	data, _ := json.Marshal(p)
+	return data
+}
+
+// ReplyError returns the problem of an error reply, nil when reply has no
+// ErrorCodeHeader. The problem is decoded from the body when it holds one.
+func ReplyError(reply *nats.Msg) *problem.Error {
+	code, err := strconv.Atoi(reply.Header.Get(ErrorCodeHeader))
+	if err != nil || code < http.StatusBadRequest {
+		return nil
+	}
+
+	var p problem.Problem
+	if json.Unmarshal(reply.Data, &p) != nil || p.Status != code {
+		p = *problem.New(code, reply.Header.Get(ErrorHeader))
+	}
+	return &problem.Error{
+		Status: code,
+		Type:   problemType(p.Type),
+		Detail: p.Detail,
+		Errors: p.Errors,
+	}
+}
+
+// problemType returns the type slug of a problem type URI
+func problemType(uri string) string {
+	slug, ok := strings.CutPrefix(uri, problem.TypeBaseURI)
+	if !ok {
+		return ""
+	}
+	return slug
+}
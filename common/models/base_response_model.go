@@ -8,8 +8,3 @@ type BasePaginationResponse struct {
 type BaseResponse struct {
 	Data interface{} `json:"data"`
 }
-
-type ErrorResponse struct {
-	Error string `json:"error"`
-	Msg   string `json:"message"`
-}
@@ -0,0 +1,9 @@
+package models
+
+// CursorMetaResponse describes a page of a cursor paginated listing. The
+// cursors are also sent as next and prev links in the Link header.
+type CursorMetaResponse struct {
+	Limit      int    `json:"limit" example:"20"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
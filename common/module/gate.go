@@ -0,0 +1,136 @@
+package module
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GatesOptions configures the feature gates
+type GatesOptions struct {
+	// Environment selects the profile, such as development or production
+	Environment string
+	// Profiles lists the features enabled by default in each environment.
+	// Entries are path.Match patterns such as messaging.*
+	Profiles map[string][]string
+	// Flags explicitly enable or disable features, whatever the profile
+	Flags map[string]bool
+	// DisabledStatus answers disabled routes, http.StatusNotFound or
+	// http.StatusForbidden. Defaults to http.StatusNotFound.
+	DisabledStatus int
+}
+
+// GatedRoute is a route registered through Gates
+type GatedRoute struct {
+	Method  string
+	Pattern string
+	Feature string
+	Enabled bool
+	// Reason explains why the feature is enabled or disabled
+	Reason string
+}
+
+// gatesState is shared by the Gates scoped to each module
+type gatesState struct {
+	opts   GatesOptions
+	mu     sync.Mutex
+	routes []GatedRoute
+}
+
+// Gates enables the routes of a feature depending on the environment and
+// the explicit feature flags. A nil *Gates enables every route.
+type Gates struct {
+	*gatesState
+	// prefix is the mount path of the module, recorded in the routes
+	prefix string
+}
+
+// NewGates creates feature gates
+func NewGates(opts GatesOptions) *Gates {
+	if opts.DisabledStatus != http.StatusForbidden {
+		opts.DisabledStatus = http.StatusNotFound
+	}
+	return &Gates{gatesState: &gatesState{opts: opts}}
+}
+
+// scoped returns gates recording routes under prefix
+func (g *Gates) scoped(prefix string) *Gates {
+	if g == nil {
+		return nil
+	}
+	return &Gates{gatesState: g.gatesState, prefix: g.prefix + prefix}
+}
+
+// Enabled reports whether feature is enabled and why. Explicit flags take
+// precedence over the profile of the environment.
+func (g *Gates) Enabled(feature string) (bool, string) {
+	if g == nil {
+		return true, "no gates"
+	}
+
+	if enabled, ok := g.opts.Flags[feature]; ok {
+		return enabled, fmt.Sprintf("flag %s=%t", feature, enabled)
+	}
+	for _, pattern := range g.opts.Profiles[g.opts.Environment] {
+		if matched, _ := path.Match(pattern, feature); matched {
+			return true, fmt.Sprintf("enabled by the %s profile", g.opts.Environment)
+		}
+	}
+	return false, fmt.Sprintf("not enabled by the %s profile", g.opts.Environment)
+}
+
+// Route registers h for method and pattern on r when feature is enabled.
+// Otherwise the route answers the disabled status with a problem naming the
+// feature, so clients can tell a disabled route from a mistyped one.
+func (g *Gates) Route(r chi.Router, method, pattern, feature string, h http.HandlerFunc) {
+	enabled, reason := g.Enabled(feature)
+	if g != nil {
+		g.mu.Lock()
+		g.routes = append(g.routes, GatedRoute{
+			Method:  method,
+			Pattern: strings.TrimSuffix(g.prefix, "/") + pattern,
+			Feature: feature,
+			Enabled: enabled,
+			Reason:  reason,
+		})
+		g.mu.Unlock()
+	}
+
+	if enabled {
+		r.Method(method, pattern, h)
+		return
+	}
+	r.Method(method, pattern, g.disabled(feature))
+}
+
+// disabled answers the routes of a disabled feature
+func (g *Gates) disabled(feature string) http.HandlerFunc {
+	detail := fmt.Sprintf("The %s feature is disabled in the %s environment", feature, g.opts.Environment)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if g.opts.DisabledStatus == http.StatusForbidden {
+			problem.WriteError(w, r, problem.Forbidden(detail))
+			return
+		}
+		problem.WriteError(w, r, problem.NotFound(detail))
+	}
+}
+
+// Routes returns the routes registered through the gates, patterns include
+// the mount path of their module
+func (g *Gates) Routes() []GatedRoute {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return slices.Clone(g.routes)
+}
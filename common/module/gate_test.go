@@ -0,0 +1,102 @@
+package module_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestGatesEnabled(t *testing.T) {
+	profiles := map[string][]string{
+		"development": {"*"},
+		"staging":     {"reports.*"},
+		"production":  {},
+	}
+
+	tests := []struct {
+		name        string
+		environment string
+		flags       map[string]bool
+		feature     string
+		expected    bool
+	}{
+		{"development profile", "development", nil, "messaging.publish", true},
+		{"staging profile pattern", "staging", nil, "reports.export", true},
+		{"staging profile miss", "staging", nil, "messaging.publish", false},
+		{"production profile", "production", nil, "messaging.publish", false},
+		{"unknown environment", "qa", nil, "messaging.publish", false},
+		{"flag enables", "production", map[string]bool{"messaging.publish": true}, "messaging.publish", true},
+		{"flag disables", "development", map[string]bool{"messaging.publish": false}, "messaging.publish", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := module.NewGates(module.GatesOptions{Environment: tt.environment, Profiles: profiles, Flags: tt.flags})
+			if enabled, reason := g.Enabled(tt.feature); enabled != tt.expected {
+				t.Errorf("Enabled(%s) = %v (%s), want %v", tt.feature, enabled, reason, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGatesRoute(t *testing.T) {
+	tests := []struct {
+		name           string
+		gates          *module.Gates
+		expectedStatus int
+	}{
+		{"enabled", module.NewGates(module.GatesOptions{Flags: map[string]bool{"test.ping": true}}), http.StatusOK},
+		{"disabled", module.NewGates(module.GatesOptions{Environment: "production"}), http.StatusNotFound},
+		{"disabled forbidden", module.NewGates(module.GatesOptions{DisabledStatus: http.StatusForbidden}), http.StatusForbidden},
+		{"no gates", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			tt.gates.Route(r, http.MethodGet, "/ping", "test.ping", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK && rr.Header().Get("Content-Type") != problem.ContentType {
+				t.Errorf("Disabled route returned content type %q", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestGatesRoutesScopedToModule(t *testing.T) {
+	gates := module.NewGates(module.GatesOptions{Environment: "production"})
+	r := module.NewRegistry()
+	r.Register(module.Definition{
+		Name: "reports",
+		Path: "/reports",
+		New: func(c *module.Container) (module.Module, error) {
+			c.Gates.Route(chi.NewRouter(), http.MethodPost, "/export", "reports.export", http.NotFound)
+			return &testModule{}, nil
+		},
+	})
+
+	if _, err := r.Build(&module.Container{Gates: gates}, nil); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	routes := gates.Routes()
+	if len(routes) != 1 {
+		t.Fatalf("Routes() returned %d routes, want 1", len(routes))
+	}
+	if got := routes[0]; got.Pattern != "/reports/export" || got.Method != http.MethodPost || got.Enabled {
+		t.Errorf("Unexpected gated route %+v", got)
+	}
+}
@@ -0,0 +1,245 @@
+// Package module defines the feature modules of the service. Features register
+// a Definition from an init function, and the server builds the enabled
+// modules from a Container holding the shared dependencies, then mounts their
+// routers, starts them and checks their health.
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db"
+	"github.com/LexiconIndonesia/go-http-service-template/common/lifecycle"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/pagination"
+)
+
+// Dependency names a shared dependency of the Container
+type Dependency string
+
+const (
+	// DependencyDB requires Container.DB and Container.Store
+	DependencyDB Dependency = "db"
+	// DependencyNats requires Container.Nats
+	DependencyNats Dependency = "nats"
+)
+
+// Container holds the dependencies shared by the modules
+type Container struct {
+	// DB is the database, nil when not configured
+	DB *db.DB
+	// Store is the query interface of DB, or a fake in tests
+	Store db.Store
+	// Nats is the NATS client, nil when not configured
+	Nats *messaging.NatsClient
+	// NatsRequests restricts the NATS requests sent from HTTP
+	NatsRequests messaging.RequestPolicy
+	// Cursors signs pagination cursors
+	Cursors *pagination.Signer
+	// Environment is the environment of the service, such as development
+	Environment string
+	// Gates enable feature routes per environment, modules receive gates
+	// scoped to their path. Nil enables every route.
+	Gates *Gates
+}
+
+// available reports whether the container provides dep
+func (c *Container) available(dep Dependency) bool {
+	switch dep {
+	case DependencyDB:
+		return c.Store != nil
+	case DependencyNats:
+		return c.Nats != nil
+	default:
+		return false
+	}
+}
+
+// Module is a feature of the service. Modules may also implement Starter,
+// Stopper, HealthChecker and Subscriber.
+type Module interface {
+	// Router returns the routes of the module
+	Router() http.Handler
+}
+
+// Starter is implemented by modules that start background work
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper is implemented by modules that stop background work on shutdown
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// HealthChecker is implemented by modules that report their health
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Subscriber is implemented by modules that handle NATS messages. Subscribe
+// is called once the module is built, when NATS is available.
+type Subscriber interface {
+	Subscribe(client *messaging.NatsClient) error
+}
+
+// Definition describes a module
+type Definition struct {
+	// Name identifies the module in configuration, logs and route groups
+	Name string
+	// Path is where the router of the module is mounted under /v1
+	Path string
+	// Dependencies must be available in the Container for the module to be
+	// built
+	Dependencies []Dependency
+	// New builds the module from the container
+	New func(c *Container) (Module, error)
+}
+
+// Registry holds module definitions in registration order
+type Registry struct {
+	mu   sync.Mutex
+	defs []Definition
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the registry features register with
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a definition to the default registry, it is meant to be
+// called from the init function of a feature
+func Register(def Definition) {
+	defaultRegistry.Register(def)
+}
+
+// Register adds a definition. It panics when the name is empty or already
+// registered, as both are programming errors.
+func (r *Registry) Register(def Definition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if def.Name == "" || def.New == nil {
+		panic("module: definition needs a name and a constructor")
+	}
+	if slices.ContainsFunc(r.defs, func(d Definition) bool { return d.Name == def.Name }) {
+		panic("module: " + def.Name + " registered twice")
+	}
+	r.defs = append(r.defs, def)
+}
+
+// Definitions returns the registered definitions
+func (r *Registry) Definitions() []Definition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.defs)
+}
+
+// Status is the outcome of building a module
+type Status string
+
+const (
+	// StatusEnabled modules are built and mounted
+	StatusEnabled Status = "enabled"
+	// StatusDisabled modules are turned off by configuration
+	StatusDisabled Status = "disabled"
+	// StatusUnavailable modules miss a dependency of the container
+	StatusUnavailable Status = "unavailable"
+)
+
+// Loaded is a module built by a registry
+type Loaded struct {
+	Definition
+	Status Status
+	// Missing lists the dependencies an unavailable module lacks
+	Missing []Dependency
+	// Module is nil unless the module is enabled
+	Module Module
+}
+
+// Build builds the modules enabled by enabled, or all of them when enabled is
+// nil. Modules missing a dependency are reported as unavailable rather than
+// failing, so the service still starts without an optional dependency.
+func (r *Registry) Build(c *Container, enabled func(name string) bool) ([]Loaded, error) {
+	var loaded []Loaded
+	for _, def := range r.Definitions() {
+		l := Loaded{Definition: def, Status: StatusEnabled}
+
+		if enabled != nil && !enabled(def.Name) {
+			l.Status = StatusDisabled
+			loaded = append(loaded, l)
+			continue
+		}
+
+		for _, dep := range def.Dependencies {
+			if !c.available(dep) {
+				l.Missing = append(l.Missing, dep)
+			}
+		}
+		if len(l.Missing) > 0 {
+			l.Status = StatusUnavailable
+			loaded = append(loaded, l)
+			continue
+		}
+
+		scoped := *c
+		scoped.Gates = c.Gates.scoped(def.Path)
+		m, err := def.New(&scoped)
+		if err != nil {
+			return nil, fmt.Errorf("building module %s: %w", def.Name, err)
+		}
+		l.Module = m
+
+		if s, ok := m.(Subscriber); ok && c.Nats != nil {
+			if err := s.Subscribe(c.Nats); err != nil {
+				return nil, fmt.Errorf("subscribing module %s: %w", def.Name, err)
+			}
+		}
+		loaded = append(loaded, l)
+	}
+	return loaded, nil
+}
+
+// Hook returns the lifecycle hook starting and stopping the module, false
+// when the module has neither Start nor Stop. Module hooks belong to the
+// consumers phase: they start before the server accepts traffic and stop
+// once it is drained.
+func (l Loaded) Hook() (lifecycle.Hook, bool) {
+	starter, canStart := l.Module.(Starter)
+	stopper, canStop := l.Module.(Stopper)
+	if !canStart && !canStop {
+		return lifecycle.Hook{}, false
+	}
+
+	hook := lifecycle.Hook{Name: "module " + l.Name, Phase: lifecycle.PhaseConsumers}
+	if canStart {
+		hook.Start = starter.Start
+	}
+	if canStop {
+		hook.Stop = stopper.Stop
+	}
+	return hook, true
+}
+
+// HealthCheck checks the enabled modules implementing HealthChecker and
+// returns the result of each by module name
+func HealthCheck(ctx context.Context, modules []Loaded) map[string]error {
+	results := map[string]error{}
+	for _, l := range modules {
+		if hc, ok := l.Module.(HealthChecker); ok {
+			results[l.Name] = hc.HealthCheck(ctx)
+		}
+	}
+	return results
+}
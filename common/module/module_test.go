@@ -0,0 +1,161 @@
+package module_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db"
+	"github.com/LexiconIndonesia/go-http-service-template/common/lifecycle"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+)
+
+// testModule records the calls of the optional module interfaces
+type testModule struct {
+	healthErr  error
+	subscribed bool
+}
+
+func (m *testModule) Router() http.Handler { return http.NotFoundHandler() }
+
+func (m *testModule) HealthCheck(ctx context.Context) error { return m.healthErr }
+
+func (m *testModule) Start(ctx context.Context) error { return nil }
+
+func (m *testModule) Subscribe(client *messaging.NatsClient) error {
+	m.subscribed = true
+	return nil
+}
+
+func definition(name string, m module.Module, deps ...module.Dependency) module.Definition {
+	return module.Definition{
+		Name:         name,
+		Path:         "/" + name,
+		Dependencies: deps,
+		New: func(c *module.Container) (module.Module, error) {
+			return m, nil
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	r := module.NewRegistry()
+	r.Register(definition("hello", &testModule{}))
+	r.Register(definition("users", &testModule{}, module.DependencyDB))
+	r.Register(definition("messaging", &testModule{}, module.DependencyNats))
+	r.Register(definition("reports", &testModule{}))
+
+	c := &module.Container{Store: db.NewFake()}
+	loaded, err := r.Build(c, func(name string) bool { return name != "reports" })
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	expected := map[string]module.Status{
+		"hello":     module.StatusEnabled,
+		"users":     module.StatusEnabled,
+		"messaging": module.StatusUnavailable,
+		"reports":   module.StatusDisabled,
+	}
+	if len(loaded) != len(expected) {
+		t.Fatalf("Build() returned %d modules, want %d", len(loaded), len(expected))
+	}
+	for _, l := range loaded {
+		if l.Status != expected[l.Name] {
+			t.Errorf("Module %s has wrong status: got %v want %v", l.Name, l.Status, expected[l.Name])
+		}
+		if (l.Module != nil) != (l.Status == module.StatusEnabled) {
+			t.Errorf("Module %s with status %v has module %v", l.Name, l.Status, l.Module)
+		}
+	}
+
+	// Modules keep the registration order
+	if loaded[0].Name != "hello" || loaded[3].Name != "reports" {
+		t.Errorf("Modules not in registration order: %v", loaded)
+	}
+	if !slices.Equal(loaded[2].Missing, []module.Dependency{module.DependencyNats}) {
+		t.Errorf("Wrong missing dependencies: got %v want [nats]", loaded[2].Missing)
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	r := module.NewRegistry()
+	r.Register(module.Definition{
+		Name: "broken",
+		New: func(c *module.Container) (module.Module, error) {
+			return nil, errors.New("boom")
+		},
+	})
+
+	if _, err := r.Build(&module.Container{}, nil); err == nil {
+		t.Error("Build() expected an error from the constructor")
+	}
+}
+
+func TestBuildWithoutNatsDoesNotSubscribe(t *testing.T) {
+	m := &testModule{}
+	r := module.NewRegistry()
+	r.Register(definition("hello", m))
+
+	if _, err := r.Build(&module.Container{}, nil); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if m.subscribed {
+		t.Error("Subscribe called without a NATS client")
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []module.Definition
+	}{
+		{"duplicate name", []module.Definition{definition("hello", &testModule{}), definition("hello", &testModule{})}},
+		{"empty name", []module.Definition{definition("", &testModule{})}},
+		{"no constructor", []module.Definition{{Name: "hello"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Register() did not panic")
+				}
+			}()
+
+			r := module.NewRegistry()
+			for _, def := range tt.defs {
+				r.Register(def)
+			}
+		})
+	}
+}
+
+func TestHookAndHealthCheck(t *testing.T) {
+	failing := errors.New("not connected")
+	r := module.NewRegistry()
+	r.Register(definition("healthy", &testModule{}))
+	r.Register(definition("failing", &testModule{healthErr: failing}))
+	r.Register(definition("disabled", &testModule{}))
+
+	loaded, err := r.Build(&module.Container{}, func(name string) bool { return name != "disabled" })
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	results := module.HealthCheck(context.Background(), loaded)
+	if len(results) != 2 || results["healthy"] != nil || !errors.Is(results["failing"], failing) {
+		t.Errorf("Unexpected health checks: %v", results)
+	}
+
+	hook, ok := loaded[0].Hook()
+	if !ok || hook.Start == nil || hook.Stop != nil || hook.Phase != lifecycle.PhaseConsumers {
+		t.Errorf("Unexpected hook %+v, ok %v", hook, ok)
+	}
+	if _, ok := loaded[2].Hook(); ok {
+		t.Error("Disabled module returned a hook")
+	}
+}
@@ -0,0 +1,319 @@
+package module
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/openapi"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// Operation is a route of a module router served as a NATS micro service
+// endpoint
+type Operation struct {
+	// Name identifies the endpoint within the module, such as get
+	Name string
+	// Method and Pattern select the route of the module router, such as
+	// GET /{id}
+	Method  string
+	Pattern string
+}
+
+// Operator is implemented by modules exposing some of their routes over NATS
+type Operator interface {
+	Operations() []Operation
+}
+
+// ServiceOptions configures the NATS micro service of the modules
+type ServiceOptions struct {
+	// Name and Version identify the service in discovery
+	Name        string
+	Version     string
+	Description string
+	// Prefix starts the endpoint subjects, which are
+	// <prefix>.<module>.<operation> followed by one token per path parameter
+	Prefix string
+	// QueueGroup spreads the requests over the instances, micro's default
+	// when empty
+	QueueGroup string
+	// BasePath is where the module routers are mounted, such as /v1
+	BasePath string
+	// Spec annotates the endpoints with the schemas of their routes, nil to
+	// leave them out
+	Spec *openapi.Spec
+	// Middlewares returns the middlewares wrapping the router of a module,
+	// usually those of its HTTP route group so that the same timeouts, limits
+	// and validation apply. All NATS callers share one identity, so
+	// middlewares whose state must not be shared between clients, such as
+	// idempotency replaying responses, should be left out. Nil serves the
+	// routers as is.
+	Middlewares func(module string) chi.Middlewares
+}
+
+// Serve registers the operations of the enabled modules implementing Operator
+// as endpoints of a micro service, which answers the discovery, stats and info
+// requests of the NATS micro protocol.
+//
+// Requests are served by the router of the module wrapped in opts.Middlewares,
+// so they go through the same middlewares, binding, validation and handlers as
+// over HTTP. Requests have no client address or verified identity, so per-client
+// state such as rate limits is shared by all NATS callers.
+//
+// Path parameters are the last tokens of the subject, NATS headers become HTTP
+// headers and the data is the JSON body, or for methods without a body a JSON
+// object of query parameters. The reply is the HTTP response body, and error
+// responses set the Nats-Service-Error and Nats-Service-Error-Code headers.
+func Serve(nc *nats.Conn, modules []Loaded, opts ServiceOptions) (micro.Service, error) {
+	svc, err := micro.AddService(nc, micro.Config{
+		Name:        opts.Name,
+		Version:     opts.Version,
+		Description: opts.Description,
+		QueueGroup:  opts.QueueGroup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("adding micro service: %w", err)
+	}
+
+	for _, m := range modules {
+		operator, ok := m.Module.(Operator)
+		if !ok || m.Status != StatusEnabled {
+			continue
+		}
+
+		router := m.Module.Router()
+		if opts.Middlewares != nil {
+			router = opts.Middlewares(m.Name).Handler(router)
+		}
+		for _, op := range operator.Operations() {
+			params := pathParams(op.Pattern)
+			tokens := []string{opts.Prefix, m.Name, op.Name}
+			for range params {
+				tokens = append(tokens, "*")
+			}
+
+			e := endpoint{
+				op:     op,
+				params: params,
+				router: router,
+				prefix: opts.BasePath + m.Path,
+			}
+			err := svc.AddEndpoint(m.Name+"_"+op.Name, e,
+				micro.WithEndpointSubject(strings.Join(tokens, ".")),
+				micro.WithEndpointMetadata(e.metadata(opts.Spec)),
+			)
+			if err != nil {
+				_ = svc.Stop()
+				return nil, fmt.Errorf("adding endpoint %s of module %s: %w", op.Name, m.Name, err)
+			}
+		}
+	}
+	return svc, nil
+}
+
+// paramPattern matches the parameters of a chi pattern, such as {id} or
+// {id:[0-9]+}
+var paramPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// pathParams returns the names of the parameters of a chi pattern in order
+func pathParams(pattern string) []string {
+	var names []string
+	for _, match := range paramPattern.FindAllStringSubmatch(pattern, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// endpoint serves an operation with the router of its module
+type endpoint struct {
+	op     Operation
+	params []string
+	router http.Handler
+	// prefix is where the router is mounted, such as /v1/users
+	prefix string
+}
+
+// metadata describes the route and, when spec is set, the schemas of the
+// operation
+func (e endpoint) metadata(spec *openapi.Spec) map[string]string {
+	route := openapi.Route{Method: e.op.Method, Path: path.Clean(e.prefix + e.op.Pattern)}
+	metadata := map[string]string{
+		"method": route.Method,
+		"path":   route.Path,
+	}
+	if len(e.params) > 0 {
+		metadata["params"] = strings.Join(e.params, ",")
+	}
+	if spec == nil {
+		return metadata
+	}
+
+	if op, ok := spec.Operation(route); ok && op.Summary != "" {
+		metadata["summary"] = op.Summary
+	}
+	if schema := spec.RequestSchema(route); schema != nil {
+		metadata["request_schema"] = string(schema)
+	}
+	if schema := spec.ResponseSchema(route); schema != nil {
+		metadata["response_schema"] = string(schema)
+	}
+	return metadata
+}
+
+// Handle serves a NATS request with the router of the module
+func (e endpoint) Handle(req micro.Request) {
+	r, err := e.request(req)
+	if err != nil {
+		p := problem.FromError(err)
+		data, _ := json.Marshal(p)
+		_ = req.Error(strconv.Itoa(p.Status), p.Detail, data, micro.WithHeaders(micro.Headers{"Content-Type": {problem.ContentType}}))
+		return
+	}
+
+	w := &replyWriter{header: http.Header{}, status: http.StatusOK}
+	e.router.ServeHTTP(w, r)
+
+	headers := micro.Headers{}
+	for _, key := range []string{"Content-Type", "ETag", "Link", "Location"} {
+		if values := w.header.Values(key); len(values) > 0 {
+			headers[key] = values
+		}
+	}
+
+	if w.status < http.StatusBadRequest {
+		_ = req.Respond(w.body.Bytes(), micro.WithHeaders(headers))
+		return
+	}
+	var p problem.Problem
+	if json.Unmarshal(w.body.Bytes(), &p) != nil || p.Detail == "" {
+		p.Detail = http.StatusText(w.status)
+	}
+	_ = req.Error(strconv.Itoa(w.status), p.Detail, w.body.Bytes(), micro.WithHeaders(headers))
+}
+
+// request builds the HTTP request routed to the operation. The route context
+// points chi at the operation, while the URL keeps the full path for problem
+// instances and links.
+func (e endpoint) request(req micro.Request) (*http.Request, error) {
+	tokens := strings.Split(req.Subject(), ".")
+	if len(tokens) < len(e.params) {
+		return nil, problem.BadRequest("Missing path parameters in the subject")
+	}
+	values := tokens[len(tokens)-len(e.params):]
+
+	i := 0
+	routePath := paramPattern.ReplaceAllStringFunc(e.op.Pattern, func(string) string {
+		value := url.PathEscape(values[i])
+		i++
+		return value
+	})
+
+	header := http.Header{}
+	for key, values := range req.Headers() {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	if header.Get("Accept") == "" {
+		header.Set("Accept", "application/json")
+	}
+
+	var body []byte
+	query := url.Values{}
+	switch e.op.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		body = req.Data()
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/json")
+		}
+	default:
+		var err error
+		if query, err = queryValues(req.Data()); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := context.Background()
+	if id := header.Get(middleware.RequestIDHeader); id != "" {
+		ctx = context.WithValue(ctx, middleware.RequestIDKey, id)
+	}
+	rctx := chi.NewRouteContext()
+	rctx.RoutePath = routePath
+	ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+
+	target := &url.URL{Path: path.Clean(e.prefix + routePath), RawQuery: query.Encode()}
+	r, err := http.NewRequestWithContext(ctx, e.op.Method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, problem.BadRequest("Invalid request").Wrap(err)
+	}
+	r.Header = header
+	r.RemoteAddr = "nats"
+	return r, nil
+}
+
+// queryValues converts a JSON object of strings, numbers, booleans and arrays
+// of those to query parameters
+func queryValues(data []byte) (url.Values, error) {
+	values := url.Values{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return values, nil
+	}
+
+	var params map[string]any
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, problem.BadRequest("Request data must be a JSON object of query parameters").Wrap(err)
+	}
+	for name, param := range params {
+		items, ok := param.([]any)
+		if !ok {
+			items = []any{param}
+		}
+		for _, item := range items {
+			switch item := item.(type) {
+			case string:
+				values.Add(name, item)
+			case float64, bool:
+				values.Add(name, fmt.Sprint(item))
+			default:
+				return nil, problem.BadRequest(fmt.Sprintf("Query parameter %s must be a string, number, boolean or an array of those", name))
+			}
+		}
+	}
+	return values, nil
+}
+
+// replyWriter records the response of the router
+type replyWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *replyWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *replyWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+func (w *replyWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
@@ -0,0 +1,258 @@
+package module_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging/natstest"
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+	"github.com/LexiconIndonesia/go-http-service-template/common/openapi"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nats-io/nats.go"
+)
+
+type item struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Owner string `json:"owner,omitempty"`
+}
+
+type getItemRequest struct {
+	ID string `json:"-" path:"id" validate:"required"`
+}
+
+type createItemRequest struct {
+	Name  string `json:"name" validate:"required"`
+	Owner string `json:"-" header:"X-Owner"`
+}
+
+type searchItemsRequest struct {
+	Tags []string `json:"-" query:"tag"`
+	Page int      `json:"-" query:"page"`
+}
+
+// itemModule serves items with typed handlers, like the feature modules
+type itemModule struct{}
+
+func (itemModule) Router() http.Handler {
+	r := chi.NewRouter()
+	r.Post("/", utils.Handle(http.StatusCreated, func(ctx context.Context, req createItemRequest) (item, error) {
+		utils.ResponseHeader(ctx).Set("ETag", `"1"`)
+		return item{ID: "1", Name: req.Name, Owner: req.Owner}, nil
+	}))
+	r.Get("/{id}", utils.Handle(http.StatusOK, func(ctx context.Context, req getItemRequest) (item, error) {
+		if req.ID != "1" {
+			return item{}, problem.NotFound("Item not found")
+		}
+		return item{ID: req.ID, Name: "first"}, nil
+	}))
+	r.Get("/", utils.Handle(http.StatusOK, func(ctx context.Context, req searchItemsRequest) ([]string, error) {
+		return append(req.Tags, utils.RequestURL(ctx).Path), nil
+	}))
+	return r
+}
+
+func (itemModule) Operations() []module.Operation {
+	return []module.Operation{
+		{Name: "create", Method: http.MethodPost, Pattern: "/"},
+		{Name: "get", Method: http.MethodGet, Pattern: "/{id}"},
+		{Name: "search", Method: http.MethodGet, Pattern: "/"},
+	}
+}
+
+const testServiceSpec = `{
+	"swagger": "2.0",
+	"basePath": "/v1",
+	"paths": {
+		"/items/{id}": {"get": {
+			"summary": "Get an item",
+			"responses": {"200": {"schema": {"$ref": "#/definitions/Item"}}, "404": {"schema": {"$ref": "#/definitions/Problem"}}}
+		}}
+	},
+	"definitions": {
+		"Item": {"type": "object", "properties": {"id": {"type": "string"}, "owner": {"$ref": "#/definitions/Owner"}}},
+		"Owner": {"type": "object", "properties": {"name": {"type": "string"}}},
+		"Problem": {"type": "object"}
+	}
+}`
+
+func TestServe(t *testing.T) {
+	client := natstest.NewClient(t)
+	spec, err := openapi.Load([]byte(testServiceSpec))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	modules := []module.Loaded{
+		{Definition: definition("items", nil), Status: module.StatusEnabled, Module: itemModule{}},
+		{Definition: definition("disabled", nil), Status: module.StatusDisabled},
+	}
+	svc, err := module.Serve(client.GetConn(), modules, module.ServiceOptions{
+		Name:     "items",
+		Version:  "1.0.0",
+		Prefix:   "api",
+		BasePath: "/v1",
+		Spec:     spec,
+	})
+	if err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	t.Cleanup(func() { _ = svc.Stop() })
+
+	tests := []struct {
+		name           string
+		subject        string
+		data           string
+		header         nats.Header
+		expectedStatus int
+		expectedData   string
+	}{
+		{"create", "api.items.create", `{"name":"first"}`, nats.Header{"X-Owner": {"jane"}}, 0, `{"id":"1","name":"first","owner":"jane"}`},
+		{"create invalid", "api.items.create", `{}`, nil, http.StatusBadRequest, ""},
+		{"get", "api.items.get.1", "", nil, 0, `{"id":"1","name":"first"}`},
+		{"get missing", "api.items.get.2", "", nil, http.StatusNotFound, ""},
+		{"search", "api.items.search", `{"tag":["a","b"],"page":2}`, nil, 0, `["a","b","/v1/items"]`},
+		{"search invalid query", "api.items.search", `{"tag":{}}`, nil, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			msg := nats.NewMsg(tt.subject)
+			msg.Data = []byte(tt.data)
+			for key, values := range tt.header {
+				msg.Header[key] = values
+			}
+			reply, err := client.RequestMsg(ctx, msg)
+			if err != nil {
+				t.Fatalf("RequestMsg() error = %v", err)
+			}
+
+			perr := messaging.ReplyError(reply)
+			if tt.expectedStatus != 0 {
+				if perr == nil || perr.Status != tt.expectedStatus {
+					t.Errorf("ReplyError() = %v, want status %v", perr, tt.expectedStatus)
+				}
+				return
+			}
+			if perr != nil {
+				t.Fatalf("ReplyError() = %v, want nil", perr)
+			}
+
+			var response struct {
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(reply.Data, &response); err != nil {
+				t.Fatalf("Failed to decode reply: %v", err)
+			}
+			if string(response.Data) != tt.expectedData {
+				t.Errorf("Reply data = %s, want %s", response.Data, tt.expectedData)
+			}
+		})
+	}
+
+	info := svc.Info()
+	if len(info.Endpoints) != 3 {
+		t.Fatalf("Service has wrong number of endpoints: got %v want %v", len(info.Endpoints), 3)
+	}
+	get := info.Endpoints[1]
+	if get.Subject != "api.items.get.*" {
+		t.Errorf("Endpoint has wrong subject: got %v want %v", get.Subject, "api.items.get.*")
+	}
+	if get.Metadata["path"] != "/v1/items/{id}" || get.Metadata["summary"] != "Get an item" {
+		t.Errorf("Endpoint has wrong metadata: %v", get.Metadata)
+	}
+	schema := get.Metadata["response_schema"]
+	if !strings.Contains(schema, `"Item"`) || !strings.Contains(schema, `"Owner"`) || strings.Contains(schema, `"Problem"`) {
+		t.Errorf("Response schema does not embed the referenced definitions only: %s", schema)
+	}
+}
+
+func TestServeMiddlewares(t *testing.T) {
+	client := natstest.NewClient(t)
+
+	var groups []string
+	timeout := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	guard := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); !ok {
+				problem.WriteError(w, r, problem.Internal("Request has no deadline", nil))
+				return
+			}
+			if r.Header.Get("X-Reject") != "" {
+				problem.WriteError(w, r, problem.Forbidden("Rejected by middleware"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	modules := []module.Loaded{{Definition: definition("items", nil), Status: module.StatusEnabled, Module: itemModule{}}}
+	svc, err := module.Serve(client.GetConn(), modules, module.ServiceOptions{
+		Name:     "items",
+		Version:  "1.0.0",
+		Prefix:   "api",
+		BasePath: "/v1",
+		Middlewares: func(name string) chi.Middlewares {
+			groups = append(groups, name)
+			return chi.Middlewares{timeout, guard}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	t.Cleanup(func() { _ = svc.Stop() })
+
+	if len(groups) != 1 || groups[0] != "items" {
+		t.Errorf("Middlewares were requested for %v, want [items]", groups)
+	}
+
+	tests := []struct {
+		name           string
+		header         nats.Header
+		expectedStatus int
+	}{
+		{"passes the middlewares", nil, 0},
+		{"rejected by a middleware", nats.Header{"X-Reject": {"1"}}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			msg := nats.NewMsg("api.items.get.1")
+			for key, values := range tt.header {
+				msg.Header[key] = values
+			}
+			reply, err := client.RequestMsg(ctx, msg)
+			if err != nil {
+				t.Fatalf("RequestMsg() error = %v", err)
+			}
+
+			perr := messaging.ReplyError(reply)
+			if tt.expectedStatus == 0 && perr != nil {
+				t.Errorf("ReplyError() = %v, want nil", perr)
+			}
+			if tt.expectedStatus != 0 && (perr == nil || perr.Status != tt.expectedStatus) {
+				t.Errorf("ReplyError() = %v, want status %v", perr, tt.expectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Package openapi reads the Swagger 2.0 spec generated by swag and compares
+// it with the routes mounted on a chi router
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Spec is the part of a Swagger 2.0 document describing the endpoints
+type Spec struct {
+	BasePath    string                          `json:"basePath"`
+	Paths       map[string]map[string]Operation `json:"paths"`
+	Definitions map[string]json.RawMessage      `json:"definitions"`
+}
+
+// Operation is an endpoint of the spec
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Parameters  []Parameter         `json:"parameters"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is a parameter of an operation, Schema is set for the body
+type Parameter struct {
+	Name   string          `json:"name"`
+	In     string          `json:"in"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// Response is a documented response of an operation
+type Response struct {
+	Schema json.RawMessage `json:"schema"`
+}
+
+// Load parses a Swagger 2.0 document, such as docs.SwaggerInfo.ReadDoc()
+func Load(doc []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI spec: %w", err)
+	}
+	spec.BasePath = strings.TrimSuffix(spec.BasePath, "/")
+	return &spec, nil
+}
+
+// Route is an endpoint, with chi style {param} placeholders
+type Route struct {
+	Method string
+	Path   string
+}
+
+// String returns the route as "METHOD /path"
+func (r Route) String() string {
+	return r.Method + " " + r.Path
+}
+
+// Routes returns the endpoints documented by the spec, prefixed with the
+// base path and sorted by path then method
+func (s *Spec) Routes() []Route {
+	var routes []Route
+	for path, operations := range s.Paths {
+		for method := range operations {
+			routes = append(routes, Route{
+				Method: strings.ToUpper(method),
+				Path:   normalize(s.BasePath + path),
+			})
+		}
+	}
+	sortRoutes(routes)
+	return routes
+}
+
+// Walk returns the routes mounted on r, sorted by path then method
+func Walk(r chi.Routes) ([]Route, error) {
+	var routes []Route
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, Route{Method: method, Path: normalize(route)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking routes: %w", err)
+	}
+	sortRoutes(routes)
+	return slices.Compact(routes), nil
+}
+
+// Drift lists the differences between the mounted and the documented routes
+type Drift struct {
+	// Undocumented routes are mounted but missing from the spec
+	Undocumented []Route
+	// Unmounted routes are documented but not mounted
+	Unmounted []Route
+}
+
+// Empty reports whether the routes match the spec
+func (d Drift) Empty() bool {
+	return len(d.Undocumented) == 0 && len(d.Unmounted) == 0
+}
+
+// Compare compares the mounted routes under the base path of the spec with
+// the documented ones. Routes outside the base path, such as /swagger/* or
+// /healthz, are not part of the API and are skipped.
+func (s *Spec) Compare(mounted []Route) Drift {
+	documented := s.Routes()
+
+	var drift Drift
+	for _, route := range mounted {
+		if !s.Covers(route) {
+			continue
+		}
+		if !slices.Contains(documented, route) {
+			drift.Undocumented = append(drift.Undocumented, route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(mounted, route) {
+			drift.Unmounted = append(drift.Unmounted, route)
+		}
+	}
+	return drift
+}
+
+// Covers reports whether route is under the base path of the spec
+func (s *Spec) Covers(route Route) bool {
+	return s.BasePath == "" || route.Path == s.BasePath || strings.HasPrefix(route.Path, s.BasePath+"/")
+}
+
+// paramPattern matches the regular expression of chi parameters such as
+// {id:[0-9]+}
+var paramPattern = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+
+// normalize strips the trailing slash of mounted routers and the regular
+// expressions of parameters, so chi and spec paths compare equal
+func normalize(path string) string {
+	path = paramPattern.ReplaceAllString(path, "{$1}")
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+func sortRoutes(routes []Route) {
+	slices.SortFunc(routes, func(a, b Route) int {
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+}
@@ -0,0 +1,66 @@
+package openapi_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/openapi"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const testSpec = `{
+	"swagger": "2.0",
+	"basePath": "/v1",
+	"paths": {
+		"/users": {"get": {}, "post": {}},
+		"/users/{id}": {"get": {}},
+		"/reports": {"get": {}}
+	}
+}`
+
+func TestCompare(t *testing.T) {
+	spec, err := openapi.Load([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	users := chi.NewRouter()
+	users.Get("/", ok)
+	users.Post("/", ok)
+	users.Get("/{id:[0-9]+}", ok)
+	users.Delete("/{id}", ok)
+
+	r := chi.NewRouter()
+	r.Get("/healthz", ok)
+	r.Route("/v1", func(r chi.Router) {
+		r.Mount("/users", users)
+	})
+
+	routes, err := openapi.Walk(r)
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	drift := spec.Compare(routes)
+	expectedUndocumented := []openapi.Route{{Method: http.MethodDelete, Path: "/v1/users/{id}"}}
+	expectedUnmounted := []openapi.Route{{Method: http.MethodGet, Path: "/v1/reports"}}
+
+	if !slices.Equal(drift.Undocumented, expectedUndocumented) {
+		t.Errorf("Wrong undocumented routes: got %v want %v", drift.Undocumented, expectedUndocumented)
+	}
+	if !slices.Equal(drift.Unmounted, expectedUnmounted) {
+		t.Errorf("Wrong unmounted routes: got %v want %v", drift.Unmounted, expectedUnmounted)
+	}
+	if drift.Empty() {
+		t.Error("Empty() = true for a drift")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	if _, err := openapi.Load([]byte("not json")); err == nil {
+		t.Error("Load() expected an error for an invalid document")
+	}
+}
@@ -0,0 +1,120 @@
+package openapi
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// definitionPrefix starts the references to the definitions of the spec
+const definitionPrefix = "#/definitions/"
+
+// Operation returns the operation documented for route, whose path includes
+// the base path
+func (s *Spec) Operation(route Route) (Operation, bool) {
+	path, ok := strings.CutPrefix(route.Path, s.BasePath)
+	if !ok {
+		return Operation{}, false
+	}
+	for documented, operations := range s.Paths {
+		if normalize(documented) != normalize(path) {
+			continue
+		}
+		op, ok := operations[strings.ToLower(route.Method)]
+		return op, ok
+	}
+	return Operation{}, false
+}
+
+// RequestSchema returns the JSON schema of the body of route, nil when it has
+// none. The schema embeds the definitions it references.
+func (s *Spec) RequestSchema(route Route) json.RawMessage {
+	op, ok := s.Operation(route)
+	if !ok {
+		return nil
+	}
+	for _, p := range op.Parameters {
+		if p.In == "body" && len(p.Schema) > 0 {
+			return s.standalone(p.Schema)
+		}
+	}
+	return nil
+}
+
+// ResponseSchema returns the JSON schema of the first successful response of
+// route with a body, nil when there is none. The schema embeds the
+// definitions it references.
+func (s *Spec) ResponseSchema(route Route) json.RawMessage {
+	op, ok := s.Operation(route)
+	if !ok {
+		return nil
+	}
+	statuses := slices.Sorted(maps.Keys(op.Responses))
+	for _, status := range statuses {
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 200 || code > 299 {
+			continue
+		}
+		if schema := op.Responses[status].Schema; len(schema) > 0 {
+			return s.standalone(schema)
+		}
+	}
+	return nil
+}
+
+// standalone returns schema with the definitions it references, directly or
+// through other definitions, so it can be read without the spec
+func (s *Spec) standalone(schema json.RawMessage) json.RawMessage {
+	var doc map[string]any
+	if err := json.Unmarshal(schema, &doc); err != nil {
+		return schema
+	}
+
+	definitions := map[string]any{}
+	pending := references(doc, nil)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if _, done := definitions[name]; done {
+			continue
+		}
+		var def any
+		if err := json.Unmarshal(s.Definitions[name], &def); err != nil {
+			continue
+		}
+		definitions[name] = def
+		pending = references(def, pending)
+	}
+	if len(definitions) > 0 {
+		doc["definitions"] = definitions
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return schema
+	}
+	return out
+}
+
+// references appends the names of the definitions referenced in v to names
+func references(v any, names []string) []string {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if ref, ok := value.(string); ok && key == "$ref" {
+				if name, ok := strings.CutPrefix(ref, definitionPrefix); ok {
+					names = append(names, name)
+				}
+				continue
+			}
+			names = references(value, names)
+		}
+	case []any:
+		for _, value := range v {
+			names = references(value, names)
+		}
+	}
+	return names
+}
@@ -0,0 +1,224 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/go-chi/chi/v5"
+)
+
+// Validator validates requests and responses against the operations of a
+// Swagger 2.0 spec
+type Validator struct {
+	doc      *openapi3.T
+	basePath string
+	// router matches request paths to the paths of the spec
+	router *chi.Mux
+}
+
+// NewValidator creates a validator from a Swagger 2.0 document, such as
+// docs.SwaggerInfo.ReadDoc(). The document is converted to OpenAPI 3.
+func NewValidator(doc []byte) (*Validator, error) {
+	var doc2 openapi2.T
+	if err := json.Unmarshal(doc, &doc2); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI spec: %w", err)
+	}
+	doc3, err := openapi2conv.ToV3(&doc2)
+	if err != nil {
+		return nil, fmt.Errorf("converting OpenAPI spec: %w", err)
+	}
+	if err := openapi3.NewLoader().ResolveRefsIn(doc3, nil); err != nil {
+		return nil, fmt.Errorf("resolving OpenAPI spec references: %w", err)
+	}
+
+	v := &Validator{
+		doc:      doc3,
+		basePath: strings.TrimSuffix(doc2.BasePath, "/"),
+		router:   chi.NewMux(),
+	}
+	for path, item := range doc3.Paths.Map() {
+		for method := range item.Operations() {
+			v.router.MethodFunc(method, path, http.NotFound)
+		}
+	}
+	return v, nil
+}
+
+// Endpoint is the documented operation matched by a request
+type Endpoint struct {
+	route  *routers.Route
+	params map[string]string
+}
+
+// Find returns the endpoint documented for r, false when r is outside the
+// spec
+func (v *Validator) Find(r *http.Request) (*Endpoint, bool) {
+	path, ok := strings.CutPrefix(r.URL.Path, v.basePath)
+	if !ok {
+		return nil, false
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	rctx := chi.NewRouteContext()
+	if !v.router.Match(rctx, r.Method, path) {
+		return nil, false
+	}
+	pattern := rctx.RoutePattern()
+	item := v.doc.Paths.Value(pattern)
+	if item == nil {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, key := range rctx.URLParams.Keys {
+		params[key] = rctx.URLParams.Values[i]
+	}
+	return &Endpoint{
+		route: &routers.Route{
+			Spec:      v.doc,
+			Path:      pattern,
+			PathItem:  item,
+			Method:    r.Method,
+			Operation: item.GetOperation(r.Method),
+		},
+		params: params,
+	}, true
+}
+
+// options returns the validation options. Authentication is enforced by the
+// middlewares, not by the spec, and only JSON bodies are validated.
+func options(excludeBody bool) *openapi3filter.Options {
+	return &openapi3filter.Options{
+		ExcludeRequestBody:  excludeBody,
+		ExcludeResponseBody: excludeBody,
+		MultiError:          true,
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+		SkipSettingDefaults: true,
+	}
+}
+
+// ValidateRequest validates the parameters and JSON body of r. It returns a
+// problem listing the violations: 400 for invalid parameters or bodies, 413
+// for bodies over the limit and 415 for undocumented content types.
+func (e *Endpoint) ValidateRequest(ctx context.Context, r *http.Request) error {
+	input := &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: e.params,
+		Route:      e.route,
+		Options:    options(!isJSON(r.Header.Get("Content-Type"))),
+	}
+	err := openapi3filter.ValidateRequest(ctx, input)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors []problem.FieldError
+	for _, err := range flatten(err) {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			return problem.PayloadTooLarge(fmt.Sprintf("Request body must not be larger than %d bytes", maxBytes.Limit)).Wrap(err)
+		}
+
+		var reqErr *openapi3filter.RequestError
+		if !errors.As(err, &reqErr) {
+			fieldErrors = append(fieldErrors, problem.FieldError{Rule: "openapi", Message: err.Error()})
+			continue
+		}
+		if reqErr.RequestBody != nil && strings.HasPrefix(reqErr.Reason, "header Content-Type") {
+			return problem.UnsupportedMediaType(fmt.Sprintf("Content type %q is not supported", r.Header.Get("Content-Type"))).Wrap(err)
+		}
+
+		field := "body"
+		if reqErr.Parameter != nil {
+			field = reqErr.Parameter.Name
+		}
+		fieldErrors = append(fieldErrors, requestFieldErrors(field, reqErr)...)
+	}
+	return problem.Validation("The request does not match the API specification", fieldErrors).Wrap(err)
+}
+
+// ValidateResponse validates a JSON response body. Problem responses are
+// validated as JSON, and responses with other content types only by status.
+func (e *Endpoint) ValidateResponse(ctx context.Context, r *http.Request, status int, header http.Header, body []byte) error {
+	header = header.Clone()
+	contentType := header.Get("Content-Type")
+	if strings.HasPrefix(contentType, problem.ContentType) {
+		header.Set("Content-Type", "application/json")
+	}
+
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: e.params,
+			Route:      e.route,
+		},
+		Status:  status,
+		Header:  header,
+		Options: options(len(body) == 0 || !isJSON(header.Get("Content-Type"))),
+	}
+	input.SetBodyBytes(body)
+
+	if err := openapi3filter.ValidateResponse(ctx, input); err != nil {
+		return fmt.Errorf("response %d of %s %s does not match the API specification: %w", status, r.Method, e.route.Path, err)
+	}
+	return nil
+}
+
+// requestFieldErrors describes a request violation. Schema errors point at
+// the invalid field of the body, such as body.email.
+func requestFieldErrors(field string, err *openapi3filter.RequestError) []problem.FieldError {
+	if err.Err == nil {
+		return []problem.FieldError{{Field: field, Rule: "openapi", Message: err.Reason}}
+	}
+
+	var fieldErrors []problem.FieldError
+	for _, cause := range flatten(err.Err) {
+		var schemaErr *openapi3.SchemaError
+		if !errors.As(cause, &schemaErr) {
+			fieldErrors = append(fieldErrors, problem.FieldError{Field: field, Rule: "openapi", Message: cause.Error()})
+			continue
+		}
+
+		name := field
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			name += "." + strings.Join(pointer, ".")
+		}
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: name, Rule: schemaErr.SchemaField, Message: schemaErr.Reason})
+	}
+	return fieldErrors
+}
+
+// flatten returns the errors of nested openapi3.MultiError
+func flatten(err error) []error {
+	multi, ok := err.(openapi3.MultiError)
+	if !ok {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, err := range multi {
+		errs = append(errs, flatten(err)...)
+	}
+	return errs
+}
+
+// isJSON reports whether contentType is JSON, such as application/json or
+// application/problem+json
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
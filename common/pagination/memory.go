@@ -0,0 +1,124 @@
+package pagination
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ValueFunc returns the value of the named schema field of item. Values must
+// have the Go type matching the field type: string, int64, bool, time.Time or
+// uuid.UUID.
+type ValueFunc[T any] func(item T, field string) any
+
+// Apply evaluates p against items in memory the way the SQL clause does in
+// the database, returning at most p.Limit+1 matching items in query order.
+// It backs in-memory stores such as fakes used in tests.
+func Apply[T any](items []T, p Params, value ValueFunc[T]) []T {
+	var matched []T
+	for _, item := range items {
+		if matches(item, p, value) {
+			matched = append(matched, item)
+		}
+	}
+
+	slices.SortStableFunc(matched, func(a, b T) int {
+		for _, sf := range p.Sort {
+			c := compare(value(a, sf.Name), value(b, sf.Name))
+			if p.descending(sf) {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	if len(matched) > p.Limit+1 {
+		matched = matched[:p.Limit+1]
+	}
+	return matched
+}
+
+func matches[T any](item T, p Params, value ValueFunc[T]) bool {
+	for _, f := range p.Filters {
+		if !matchFilter(value(item, f.Name), f) {
+			return false
+		}
+	}
+	if len(p.After) == 0 {
+		return true
+	}
+
+	// Strictly after the cursor in the effective sort order
+	for i, sf := range p.Sort {
+		c := compare(value(item, sf.Name), p.After[i])
+		if p.descending(sf) {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0
+		}
+	}
+	return false
+}
+
+func matchFilter(v any, f Filter) bool {
+	switch f.Op {
+	case Like:
+		s, _ := v.(string)
+		return strings.Contains(strings.ToLower(s), strings.ToLower(f.Values[0].(string)))
+	case In:
+		return slices.ContainsFunc(f.Values, func(o any) bool { return compare(v, o) == 0 })
+	}
+
+	c := compare(v, f.Values[0])
+	switch f.Op {
+	case Eq:
+		return c == 0
+	case Ne:
+		return c != 0
+	case Lt:
+		return c < 0
+	case Lte:
+		return c <= 0
+	case Gt:
+		return c > 0
+	case Gte:
+		return c >= 0
+	default:
+		return false
+	}
+}
+
+// compare orders two values of the same field type like PostgreSQL does
+func compare(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case bool:
+		b := b.(bool)
+		switch {
+		case a == b:
+			return 0
+		case b:
+			return -1
+		default:
+			return 1
+		}
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case uuid.UUID:
+		b := b.(uuid.UUID)
+		return bytes.Compare(a[:], b[:])
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,86 @@
+package pagination
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Page is one page of a keyset paginated listing
+type Page[T any] struct {
+	Items []T
+	// Next and Prev are the cursors of the adjacent pages, empty when there
+	// is no such page
+	Next string
+	Prev string
+}
+
+// NewPage builds the page for p from rows fetched with the SQL clause or
+// Apply, which hold up to p.Limit+1 items in query order. Rows of previous
+// pages are put back in the requested sort order.
+func NewPage[T any](rows []T, p Params, value ValueFunc[T], signer *Signer) Page[T] {
+	hasMore := len(rows) > p.Limit
+	if hasMore {
+		rows = rows[:p.Limit]
+	}
+	if p.Direction == Prev {
+		rows = slices.Clone(rows)
+		slices.Reverse(rows)
+	}
+
+	page := Page[T]{Items: rows}
+	if len(rows) == 0 {
+		return page
+	}
+
+	// Paging backwards means the page we came from follows, and paging
+	// forwards from a cursor means the page we came from precedes
+	hasNext := hasMore || p.Direction == Prev
+	hasPrev := (hasMore && p.Direction == Prev) || (len(p.After) > 0 && p.Direction == Next)
+
+	if hasNext {
+		page.Next = signer.Encode(newCursor(p, Next, rows[len(rows)-1], value))
+	}
+	if hasPrev {
+		page.Prev = signer.Encode(newCursor(p, Prev, rows[0], value))
+	}
+	return page
+}
+
+// newCursor returns the cursor paging in dir from the boundary row item
+func newCursor[T any](p Params, dir Direction, item T, value ValueFunc[T]) Cursor {
+	values := make([]string, len(p.Sort))
+	for i, sf := range p.Sort {
+		values[i] = formatValue(value(item, sf.Name))
+	}
+	return Cursor{Direction: dir, Values: values, Query: p.fingerprint}
+}
+
+// Links returns the Link header value pointing at the next and previous
+// pages, built from u with the cursor parameter replaced
+func (pg Page[T]) Links(u *url.URL) string {
+	var links []string
+	if pg.Next != "" {
+		links = append(links, `<`+withCursor(u, pg.Next)+`>; rel="next"`)
+	}
+	if pg.Prev != "" {
+		links = append(links, `<`+withCursor(u, pg.Prev)+`>; rel="prev"`)
+	}
+	return strings.Join(links, ", ")
+}
+
+// SetLinkHeader sets the Link header of h from Links, if there are any links
+func (pg Page[T]) SetLinkHeader(h http.Header, u *url.URL) {
+	if links := pg.Links(u); links != "" {
+		h.Set("Link", links)
+	}
+}
+
+func withCursor(u *url.URL, cursor string) string {
+	next := *u
+	query := next.Query()
+	query.Set(ParamCursor, cursor)
+	next.RawQuery = query.Encode()
+	return next.String()
+}
@@ -0,0 +1,246 @@
+package pagination
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/google/uuid"
+)
+
+type item struct {
+	ID        uuid.UUID
+	Name      string
+	Score     int64
+	CreatedAt time.Time
+}
+
+func itemValue(it item, field string) any {
+	switch field {
+	case "id":
+		return it.ID
+	case "name":
+		return it.Name
+	case "score":
+		return it.Score
+	case "created_at":
+		return it.CreatedAt
+	default:
+		return nil
+	}
+}
+
+var testSchema = &Schema{
+	Fields: map[string]Field{
+		"id":         {Column: "id", Type: UUID, Sortable: true},
+		"name":       {Column: "name", Type: String, Sortable: true, Filterable: true},
+		"score":      {Column: "score", Type: Int, Sortable: true, Filterable: true},
+		"created_at": {Column: "t.created_at", Type: Time, Sortable: true, Filterable: true},
+	},
+	DefaultSort: []SortField{{Name: "created_at", Desc: true}},
+	TieBreaker:  "id",
+	MaxLimit:    50,
+}
+
+func TestSignerRejectsTamperedCursor(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	token := signer.Encode(Cursor{Direction: Next, Values: []string{"a"}, Query: "q"})
+
+	c, err := signer.Decode(token)
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if c.Direction != Next || c.Values[0] != "a" {
+		t.Errorf("Decoded wrong cursor: %+v", c)
+	}
+
+	forged := NewSigner([]byte("other")).Encode(Cursor{Direction: Next, Values: []string{"b"}, Query: "q"})
+	payload, _, _ := strings.Cut(forged, ".")
+	_, sig, _ := strings.Cut(token, ".")
+
+	for _, tampered := range []string{"", "garbage", token + "x", forged, payload + "." + sig} {
+		if _, err := signer.Decode(tampered); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", tampered, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		expectErr bool
+		sort      string
+	}{
+		{name: "Defaults", query: "", sort: "-created_at,id"},
+		{name: "Custom sort", query: "sort=name,-score", sort: "name,-score,id"},
+		{name: "Tie breaker given", query: "sort=-id", sort: "-id"},
+		{name: "Filters", query: "filter=name:like:jo&filter=score:in:1|2|3", sort: "-created_at,id"},
+		{name: "Unknown sort field", query: "sort=password", expectErr: true},
+		{name: "Duplicate sort field", query: "sort=name,-name", expectErr: true},
+		{name: "Unfilterable field", query: "filter=id:eq:" + uuid.NewString(), expectErr: true},
+		{name: "Unknown operator", query: "filter=name:regex:x", expectErr: true},
+		{name: "Like on number", query: "filter=score:like:1", expectErr: true},
+		{name: "Invalid value", query: "filter=score:gt:many", expectErr: true},
+		{name: "Missing value", query: "filter=score", expectErr: true},
+		{name: "Limit too large", query: "limit=51", expectErr: true},
+		{name: "Invalid cursor", query: "cursor=abc.def", expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tc.query)
+			p, err := testSchema.Parse(values, NewSigner(nil))
+
+			if tc.expectErr {
+				var perr *problem.Error
+				if !errors.As(err, &perr) || perr.Status != http.StatusBadRequest {
+					t.Fatalf("Expected a 400 problem, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := formatSort(p.Sort); got != tc.sort {
+				t.Errorf("Expected sort %s, got %s", tc.sort, got)
+			}
+		})
+	}
+}
+
+func TestSQL(t *testing.T) {
+	values, _ := url.ParseQuery("sort=-score,name&filter=name:like:50%25_off&limit=10")
+	p, err := testSchema.Parse(values, NewSigner(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql, args := p.SQL(2)
+	want := ` WHERE "name" ILIKE $2 ORDER BY "score" DESC, "name" ASC, "id" ASC LIMIT 11`
+	if sql != want {
+		t.Errorf("Wrong SQL:\ngot  %s\nwant %s", sql, want)
+	}
+	if len(args) != 1 || args[0] != `%50\%\_off%` {
+		t.Errorf("Wrong args: %v", args)
+	}
+
+	id := uuid.New()
+	p.Direction = Prev
+	p.After = []any{int64(5), "bob", id}
+	sql, args = p.SQL(1)
+	want = ` WHERE "name" ILIKE $1 AND (("score" > $2) OR ("score" = $2 AND "name" < $3) OR ("score" = $2 AND "name" = $3 AND "id" < $4))` +
+		` ORDER BY "score" ASC, "name" DESC, "id" DESC LIMIT 11`
+	if sql != want {
+		t.Errorf("Wrong keyset SQL:\ngot  %s\nwant %s", sql, want)
+	}
+	if len(args) != 4 || args[3] != id {
+		t.Errorf("Wrong keyset args: %v", args)
+	}
+
+	// Qualified columns are quoted per part
+	p.Sort = []SortField{{Name: "created_at"}}
+	p.After = nil
+	p.Filters = nil
+	if sql, _ := p.SQL(1); !strings.Contains(sql, `"t"."created_at"`) {
+		t.Errorf("Expected qualified column, got %s", sql)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var items []item
+	for i := range 7 {
+		// Pairs share a timestamp so the tie breaker decides their order
+		items = append(items, item{
+			ID:        uuid.New(),
+			Name:      string(rune('a' + i)),
+			Score:     int64(i % 3),
+			CreatedAt: start.Add(time.Duration(i/2) * time.Hour),
+		})
+	}
+
+	signer := NewSigner([]byte("secret"))
+	list := func(query string) Page[item] {
+		values, _ := url.ParseQuery(query)
+		p, err := testSchema.Parse(values, signer)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", query, err)
+		}
+		return NewPage(Apply(items, p, itemValue), p, itemValue, signer)
+	}
+
+	// Walk forward through every page
+	var seen []item
+	var pages []Page[item]
+	query := "limit=3&sort=-created_at"
+	for {
+		page := list(query)
+		pages = append(pages, page)
+		seen = append(seen, page.Items...)
+		if page.Next == "" {
+			break
+		}
+		query = "limit=3&sort=-created_at&cursor=" + url.QueryEscape(page.Next)
+	}
+
+	if len(pages) != 3 || len(seen) != len(items) {
+		t.Fatalf("Expected 3 pages with %d items, got %d pages with %d items", len(items), len(pages), len(seen))
+	}
+	if pages[0].Prev != "" {
+		t.Errorf("First page should not have a prev cursor")
+	}
+	sorted := slices.IsSortedFunc(seen, func(a, b item) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return compare(a.ID, b.ID)
+	})
+	if !sorted {
+		t.Errorf("Items are not in sort order")
+	}
+
+	// Walking back from the last page returns the middle page
+	back := list("limit=3&sort=-created_at&cursor=" + url.QueryEscape(pages[2].Prev))
+	if !slices.Equal(back.Items, pages[1].Items) {
+		t.Errorf("Prev page differs from the page walked forward")
+	}
+	if back.Next == "" || back.Prev == "" {
+		t.Errorf("Middle page should have next and prev cursors")
+	}
+
+	// A cursor is bound to its sort and filters
+	values, _ := url.ParseQuery("limit=3&sort=name&cursor=" + url.QueryEscape(pages[0].Next))
+	if _, err := testSchema.Parse(values, signer); err == nil {
+		t.Errorf("Expected an error for a cursor used with another sort")
+	}
+
+	// Filters are applied before paging
+	filtered := list("filter=score:gte:2&filter=name:like:F")
+	if len(filtered.Items) != 1 || filtered.Items[0].Name != "f" {
+		t.Errorf("Expected only item f, got %+v", filtered.Items)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	u, _ := url.Parse("/v1/users?limit=2&cursor=old")
+	page := Page[item]{Next: "n.1", Prev: "p.2"}
+
+	h := http.Header{}
+	page.SetLinkHeader(h, u)
+	want := `</v1/users?cursor=n.1&limit=2>; rel="next", </v1/users?cursor=p.2&limit=2>; rel="prev"`
+	if got := h.Get("Link"); got != want {
+		t.Errorf("Wrong Link header:\ngot  %s\nwant %s", got, want)
+	}
+
+	h = http.Header{}
+	Page[item]{}.SetLinkHeader(h, u)
+	if _, ok := h["Link"]; ok {
+		t.Errorf("Expected no Link header without cursors")
+	}
+}
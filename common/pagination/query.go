@@ -0,0 +1,319 @@
+// Package pagination implements keyset pagination with signed opaque cursors
+// and a whitelisted sort and filter query language that compiles to SQL
+package pagination
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultLimit is the page size used when a schema does not set one
+	DefaultLimit = 20
+	// MaxLimit is the largest page size allowed when a schema does not set one
+	MaxLimit = 100
+)
+
+// Query parameter names
+const (
+	ParamLimit  = "limit"
+	ParamCursor = "cursor"
+	ParamSort   = "sort"
+	ParamFilter = "filter"
+)
+
+// FieldType is the type of a listable field, used to parse filter and cursor values
+type FieldType int
+
+const (
+	String FieldType = iota
+	Int
+	Bool
+	Time
+	UUID
+)
+
+// Operator is a filter comparison operator
+type Operator string
+
+const (
+	Eq   Operator = "eq"
+	Ne   Operator = "ne"
+	Lt   Operator = "lt"
+	Lte  Operator = "lte"
+	Gt   Operator = "gt"
+	Gte  Operator = "gte"
+	Like Operator = "like"
+	In   Operator = "in"
+)
+
+// Field describes a field that clients may sort or filter on
+type Field struct {
+	// Column is the SQL column the field maps to. It is quoted as an
+	// identifier, never interpolated from client input.
+	Column string
+	// Type is used to parse filter and cursor values
+	Type FieldType
+	// Sortable allows the field in the sort parameter
+	Sortable bool
+	// Filterable allows the field in the filter parameter
+	Filterable bool
+}
+
+// Schema whitelists the fields of a listing and its sort defaults. Keyset
+// pagination needs a total order, so the schema names a unique, non null
+// TieBreaker field that is always appended to the sort.
+type Schema struct {
+	Fields       map[string]Field
+	DefaultSort  []SortField
+	TieBreaker   string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// SortField is one sort key. The sort parameter lists fields separated by
+// commas, with a leading "-" for descending order, e.g. "-created_at,email".
+type SortField struct {
+	Name string
+	Desc bool
+}
+
+// Filter is a parsed filter. The filter parameter may be repeated and has the
+// form "field:op:value", e.g. "email:like:example.com". The in operator takes
+// values separated by "|".
+type Filter struct {
+	Name   string
+	Op     Operator
+	Values []any
+}
+
+// Request binds the pagination query parameters of typed handlers, embed it
+// in the handler request and pass Values to Schema.Parse
+type Request struct {
+	Limit  *int     `json:"-" query:"limit"`
+	Cursor string   `json:"-" query:"cursor"`
+	Sort   string   `json:"-" query:"sort"`
+	Filter []string `json:"-" query:"filter"`
+}
+
+// Values returns the parameters of r as query values
+func (r Request) Values() url.Values {
+	values := url.Values{}
+	if r.Limit != nil {
+		values.Set(ParamLimit, strconv.Itoa(*r.Limit))
+	}
+	if r.Cursor != "" {
+		values.Set(ParamCursor, r.Cursor)
+	}
+	if r.Sort != "" {
+		values.Set(ParamSort, r.Sort)
+	}
+	for _, f := range r.Filter {
+		values.Add(ParamFilter, f)
+	}
+	return values
+}
+
+// Params is a parsed and validated listing request
+type Params struct {
+	schema *Schema
+
+	Limit   int
+	Sort    []SortField
+	Filters []Filter
+	// Direction and After are set when a cursor was given. After holds the
+	// typed sort key values of the boundary row, in Sort order.
+	Direction Direction
+	After     []any
+
+	fingerprint string
+}
+
+// Parse parses the limit, sort, filter and cursor parameters of values,
+// rejecting fields and operators that the schema does not allow. Errors are
+// *problem.Error values with status 400.
+func (s *Schema) Parse(values url.Values, signer *Signer) (Params, error) {
+	p := Params{schema: s, Limit: s.defaultLimit(), Direction: Next}
+
+	if raw := values.Get(ParamLimit); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > s.maxLimit() {
+			return p, problem.BadRequest(fmt.Sprintf("limit must be between 1 and %d", s.maxLimit()))
+		}
+		p.Limit = limit
+	}
+
+	sort, err := s.parseSort(values.Get(ParamSort))
+	if err != nil {
+		return p, err
+	}
+	p.Sort = sort
+
+	raw := slices.Clone(values[ParamFilter])
+	for _, f := range raw {
+		filter, err := s.parseFilter(f)
+		if err != nil {
+			return p, err
+		}
+		p.Filters = append(p.Filters, filter)
+	}
+
+	// The cursor is bound to the sort and filters it was created for
+	slices.Sort(raw)
+	sum := sha256.Sum256([]byte(formatSort(p.Sort) + "\n" + strings.Join(raw, "\n")))
+	p.fingerprint = hex.EncodeToString(sum[:8])
+
+	if token := values.Get(ParamCursor); token != "" {
+		c, err := signer.Decode(token)
+		if err != nil {
+			return p, problem.BadRequest("Invalid cursor").Wrap(err)
+		}
+		if c.Query != p.fingerprint || len(c.Values) != len(p.Sort) {
+			return p, problem.BadRequest("Cursor does not match the sort and filter parameters")
+		}
+		for i, sf := range p.Sort {
+			v, err := parseValue(s.Fields[sf.Name].Type, c.Values[i])
+			if err != nil {
+				return p, problem.BadRequest("Invalid cursor").Wrap(err)
+			}
+			p.After = append(p.After, v)
+		}
+		p.Direction = c.Direction
+	}
+
+	return p, nil
+}
+
+func (s *Schema) parseSort(raw string) ([]SortField, error) {
+	var sort []SortField
+	if raw == "" {
+		sort = slices.Clone(s.DefaultSort)
+	} else {
+		for _, part := range strings.Split(raw, ",") {
+			sf := SortField{Name: strings.TrimSpace(part)}
+			if name, found := strings.CutPrefix(sf.Name, "-"); found {
+				sf = SortField{Name: name, Desc: true}
+			}
+			if f, ok := s.Fields[sf.Name]; !ok || !f.Sortable {
+				return nil, problem.BadRequest(fmt.Sprintf("Cannot sort by %q", sf.Name))
+			}
+			if slices.ContainsFunc(sort, func(o SortField) bool { return o.Name == sf.Name }) {
+				return nil, problem.BadRequest(fmt.Sprintf("Duplicate sort field %q", sf.Name))
+			}
+			sort = append(sort, sf)
+		}
+	}
+
+	if !slices.ContainsFunc(sort, func(o SortField) bool { return o.Name == s.TieBreaker }) {
+		sort = append(sort, SortField{Name: s.TieBreaker})
+	}
+	return sort, nil
+}
+
+func (s *Schema) parseFilter(raw string) (Filter, error) {
+	name, rest, _ := strings.Cut(raw, ":")
+	op, value, found := strings.Cut(rest, ":")
+	if !found {
+		return Filter{}, problem.BadRequest(fmt.Sprintf("Invalid filter %q, expected field:op:value", raw))
+	}
+
+	field, ok := s.Fields[name]
+	if !ok || !field.Filterable {
+		return Filter{}, problem.BadRequest(fmt.Sprintf("Cannot filter by %q", name))
+	}
+
+	f := Filter{Name: name, Op: Operator(op)}
+	switch f.Op {
+	case Eq, Ne, Lt, Lte, Gt, Gte:
+	case Like:
+		if field.Type != String {
+			return f, problem.BadRequest(fmt.Sprintf("Operator like is not supported for %q", name))
+		}
+		f.Values = []any{value}
+		return f, nil
+	case In:
+		for _, part := range strings.Split(value, "|") {
+			v, err := parseValue(field.Type, part)
+			if err != nil {
+				return f, problem.BadRequest(fmt.Sprintf("Invalid value for %q", name)).Wrap(err)
+			}
+			f.Values = append(f.Values, v)
+		}
+		return f, nil
+	default:
+		return f, problem.BadRequest(fmt.Sprintf("Unknown filter operator %q", op))
+	}
+
+	v, err := parseValue(field.Type, value)
+	if err != nil {
+		return f, problem.BadRequest(fmt.Sprintf("Invalid value for %q", name)).Wrap(err)
+	}
+	f.Values = []any{v}
+	return f, nil
+}
+
+func (s *Schema) defaultLimit() int {
+	if s.DefaultLimit > 0 {
+		return s.DefaultLimit
+	}
+	return DefaultLimit
+}
+
+func (s *Schema) maxLimit() int {
+	if s.MaxLimit > 0 {
+		return s.MaxLimit
+	}
+	return MaxLimit
+}
+
+func formatSort(sort []SortField) string {
+	parts := make([]string, len(sort))
+	for i, sf := range sort {
+		parts[i] = sf.Name
+		if sf.Desc {
+			parts[i] = "-" + sf.Name
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseValue parses a filter or cursor value of the given type
+func parseValue(t FieldType, raw string) (any, error) {
+	switch t {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Time:
+		return time.Parse(time.RFC3339Nano, raw)
+	case UUID:
+		return uuid.Parse(raw)
+	case String:
+		return raw, nil
+	default:
+		return nil, errors.New("unknown field type")
+	}
+}
+
+// formatValue formats a value so that parseValue can read it back
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
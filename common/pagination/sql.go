@@ -0,0 +1,124 @@
+package pagination
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SQL compiles p to a " WHERE ... ORDER BY ... LIMIT n" clause to append to a
+// SELECT, together with its arguments. Placeholders are numbered from
+// startArg, so the clause can follow a query that already has arguments, and
+// column names come from the schema, never from client input.
+//
+// The limit is one more than p.Limit so that NewPage can tell whether another
+// page follows. Keyset conditions assume the sort columns are not null.
+func (p Params) SQL(startArg int) (string, []any) {
+	b := sqlBuilder{next: startArg}
+
+	var where []string
+	for _, f := range p.Filters {
+		where = append(where, b.filter(p.column(f.Name), f))
+	}
+	if len(p.After) > 0 {
+		where = append(where, b.keyset(p))
+	}
+
+	var sql strings.Builder
+	if len(where) > 0 {
+		sql.WriteString(" WHERE ")
+		sql.WriteString(strings.Join(where, " AND "))
+	}
+
+	sql.WriteString(" ORDER BY ")
+	for i, sf := range p.Sort {
+		if i > 0 {
+			sql.WriteString(", ")
+		}
+		sql.WriteString(p.column(sf.Name))
+		if p.descending(sf) {
+			sql.WriteString(" DESC")
+		} else {
+			sql.WriteString(" ASC")
+		}
+	}
+
+	fmt.Fprintf(&sql, " LIMIT %d", p.Limit+1)
+	return sql.String(), b.args
+}
+
+// descending reports the effective order of sf. Previous pages are read in
+// reverse order and flipped back by NewPage.
+func (p Params) descending(sf SortField) bool {
+	return sf.Desc != (p.Direction == Prev)
+}
+
+func (p Params) column(name string) string {
+	return pgx.Identifier(strings.Split(p.schema.Fields[name].Column, ".")).Sanitize()
+}
+
+type sqlBuilder struct {
+	next int
+	args []any
+}
+
+func (b *sqlBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	b.next++
+	return fmt.Sprintf("$%d", b.next-1)
+}
+
+func (b *sqlBuilder) filter(column string, f Filter) string {
+	switch f.Op {
+	case Like:
+		return column + " ILIKE " + b.arg("%"+escapeLike(f.Values[0].(string))+"%")
+	case In:
+		placeholders := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			placeholders[i] = b.arg(v)
+		}
+		return column + " IN (" + strings.Join(placeholders, ", ") + ")"
+	default:
+		return column + " " + sqlOperators[f.Op] + " " + b.arg(f.Values[0])
+	}
+}
+
+// keyset builds the condition selecting rows strictly after the cursor in the
+// effective sort order. Sort directions may differ per field, so it expands to
+// (a > $1) OR (a = $1 AND b < $2) ... instead of a row comparison.
+func (b *sqlBuilder) keyset(p Params) string {
+	placeholders := make([]string, len(p.Sort))
+	for i, v := range p.After {
+		placeholders[i] = b.arg(v)
+	}
+
+	var or []string
+	for i, sf := range p.Sort {
+		var and []string
+		for j := range i {
+			and = append(and, p.column(p.Sort[j].Name)+" = "+placeholders[j])
+		}
+		op := ">"
+		if p.descending(sf) {
+			op = "<"
+		}
+		and = append(and, p.column(sf.Name)+" "+op+" "+placeholders[i])
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	return "(" + strings.Join(or, " OR ") + ")"
+}
+
+var sqlOperators = map[Operator]string{
+	Eq:  "=",
+	Ne:  "<>",
+	Lt:  "<",
+	Lte: "<=",
+	Gt:  ">",
+	Gte: ">=",
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,159 @@
+package problem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error is a domain error carrying the HTTP status and problem type it maps to.
+// Detail is safe to show to clients, the wrapped error is only logged.
+type Error struct {
+	Status int
+	Type   string
+	Detail string
+	Errors []FieldError
+	Err    error
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Detail, e.Err)
+	}
+	return e.Detail
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns a copy of e wrapping err as its cause
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// Problem converts e to a problem details object
+func (e *Error) Problem() *Problem {
+	p := New(e.Status, e.Detail)
+	if e.Type != "" {
+		p.Type = TypeBaseURI + e.Type
+	}
+	p.Errors = e.Errors
+	return p
+}
+
+// newError creates an Error of the given status and type slug
+func newError(status int, typ, detail string) *Error {
+	return &Error{Status: status, Type: typ, Detail: detail}
+}
+
+// BadRequest is returned when the request is malformed
+func BadRequest(detail string) *Error {
+	return newError(http.StatusBadRequest, "bad-request", detail)
+}
+
+// Validation is returned when request fields fail validation
+func Validation(detail string, errs []FieldError) *Error {
+	e := newError(http.StatusBadRequest, "validation", detail)
+	e.Errors = errs
+	return e
+}
+
+// Unauthorized is returned when the caller is not authenticated
+func Unauthorized(detail string) *Error {
+	return newError(http.StatusUnauthorized, "unauthorized", detail)
+}
+
+// Forbidden is returned when the caller may not perform the operation
+func Forbidden(detail string) *Error {
+	return newError(http.StatusForbidden, "forbidden", detail)
+}
+
+// NotFound is returned when the resource does not exist
+func NotFound(detail string) *Error {
+	return newError(http.StatusNotFound, "not-found", detail)
+}
+
+// Conflict is returned when the request conflicts with the current state
+func Conflict(detail string) *Error {
+	return newError(http.StatusConflict, "conflict", detail)
+}
+
+// NotAcceptable is returned when no representation matches the Accept header
+func NotAcceptable(detail string) *Error {
+	return newError(http.StatusNotAcceptable, "not-acceptable", detail)
+}
+
+// PreconditionFailed is returned when a conditional request does not match the
+// current version of the resource
+func PreconditionFailed(detail string) *Error {
+	return newError(http.StatusPreconditionFailed, "precondition-failed", detail)
+}
+
+// UnprocessableEntity is returned when a well-formed request cannot be processed
+func UnprocessableEntity(detail string) *Error {
+	return newError(http.StatusUnprocessableEntity, "unprocessable-entity", detail)
+}
+
+// PayloadTooLarge is returned when the request body exceeds the size limit
+func PayloadTooLarge(detail string) *Error {
+	return newError(http.StatusRequestEntityTooLarge, "payload-too-large", detail)
+}
+
+// UnsupportedMediaType is returned when the request body has the wrong content type
+func UnsupportedMediaType(detail string) *Error {
+	return newError(http.StatusUnsupportedMediaType, "unsupported-media-type", detail)
+}
+
+// TooManyRequests is returned when the client exceeded its rate limit
+func TooManyRequests(detail string) *Error {
+	return newError(http.StatusTooManyRequests, "too-many-requests", detail)
+}
+
+// Unavailable is returned when a dependency of the service is not available
+func Unavailable(detail string) *Error {
+	return newError(http.StatusServiceUnavailable, "unavailable", detail)
+}
+
+// BadGateway is returned when a dependency answered with an invalid response
+func BadGateway(detail string) *Error {
+	return newError(http.StatusBadGateway, "bad-gateway", detail)
+}
+
+// Timeout is returned when a dependency did not answer in time
+func Timeout(detail string) *Error {
+	return newError(http.StatusGatewayTimeout, "timeout", detail)
+}
+
+// StatusClientClosedRequest is the non-standard status of requests canceled by
+// the client, as logged by nginx
+const StatusClientClosedRequest = 499
+
+// ClientClosed is returned when the client went away before the response was
+// ready. It is not a server error, so it is not logged as one.
+func ClientClosed(detail string) *Error {
+	return newError(StatusClientClosedRequest, "client-closed-request", detail)
+}
+
+// Internal is returned for unexpected failures, err is logged but not exposed
+func Internal(detail string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Type: "internal", Detail: detail, Err: err}
+}
+
+// FromError maps err to a problem. Errors that are not an *Error become a
+// generic 500, or a 504 when a context deadline was exceeded.
+func FromError(err error) *Problem {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Problem()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout("The request timed out").Problem()
+	}
+	return Internal("An unexpected error occurred", nil).Problem()
+}
@@ -0,0 +1,97 @@
+// Package problem renders errors as RFC 7807 problem details
+// (application/problem+json) and defines the typed errors handlers return.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// TypeBaseURI is prepended to the problem type slug to build the type URI.
+// Problems without a specific type use "about:blank" as recommended by RFC 7807.
+var TypeBaseURI = "/problems/"
+
+// Problem is an RFC 7807 problem details object
+type Problem struct {
+	Type      string `json:"type" example:"/problems/not-found"`
+	Title     string `json:"title" example:"Not Found"`
+	Status    int    `json:"status" example:"404"`
+	Detail    string `json:"detail,omitempty" example:"User not found"`
+	Instance  string `json:"instance,omitempty" example:"/v1/users/550e8400-e29b-41d4-a716-446655440000"`
+	RequestID string `json:"request_id,omitempty" example:"host/abcdef-000001"`
+	// Errors lists the invalid fields of a validation problem
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single request field is invalid
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Rule    string `json:"rule" example:"email"`
+	Param   string `json:"param,omitempty" example:""`
+	Message string `json:"message" example:"email must be a valid email address"`
+}
+
+// New creates a problem for the given status with the standard status text as
+// title and "about:blank" as type
+func New(status int, detail string) *Problem {
+	title := http.StatusText(status)
+	if status == StatusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+	return &Problem{
+		Type:   "about:blank",
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Write writes p as an application/problem+json response. The instance and
+// request ID are filled from the request when not already set.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if r != nil {
+		if p.Instance == "" {
+			p.Instance = r.URL.Path
+		}
+		if p.RequestID == "" {
+			p.RequestID = middleware.GetReqID(r.Context())
+		}
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Error().Err(err).Msg("Failed to encode problem response")
+	}
+}
+
+// WriteError converts err to a problem and writes it. Server errors are logged
+// with the underlying cause, which is never exposed to the client.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	p := FromError(err)
+	if p.Status >= http.StatusInternalServerError {
+		event := log.Error().Err(err).Int("status", p.Status)
+		if r != nil {
+			event = event.Str("method", r.Method).Str("path", r.URL.Path).Str("request_id", middleware.GetReqID(r.Context()))
+		}
+		event.Msg("Request failed")
+	}
+	Write(w, r, p)
+}
+
+// NotFoundHandler responds with a 404 problem for unmatched routes
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusNotFound, "No route matches "+r.URL.Path))
+}
+
+// MethodNotAllowedHandler responds with a 405 problem for unsupported methods
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed on "+r.URL.Path))
+}
@@ -0,0 +1,103 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedType   string
+		expectedDetail string
+	}{
+		{
+			name:           "Typed error",
+			err:            NotFound("User not found"),
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "/problems/not-found",
+			expectedDetail: "User not found",
+		},
+		{
+			name:           "Wrapped typed error",
+			err:            fmt.Errorf("loading user: %w", Conflict("Email already registered")),
+			expectedStatus: http.StatusConflict,
+			expectedType:   "/problems/conflict",
+			expectedDetail: "Email already registered",
+		},
+		{
+			name:           "Internal error hides cause",
+			err:            Internal("Failed to create user", errors.New("connection refused")),
+			expectedStatus: http.StatusInternalServerError,
+			expectedType:   "/problems/internal",
+			expectedDetail: "Failed to create user",
+		},
+		{
+			name:           "Deadline exceeded",
+			err:            fmt.Errorf("querying: %w", context.DeadlineExceeded),
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedType:   "/problems/timeout",
+			expectedDetail: "The request timed out",
+		},
+		{
+			name:           "Unknown error",
+			err:            errors.New("boom"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedType:   "/problems/internal",
+			expectedDetail: "An unexpected error occurred",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := FromError(tc.err)
+			if p.Status != tc.expectedStatus {
+				t.Errorf("status = %d, want %d", p.Status, tc.expectedStatus)
+			}
+			if p.Type != tc.expectedType {
+				t.Errorf("type = %s, want %s", p.Type, tc.expectedType)
+			}
+			if p.Detail != tc.expectedDetail {
+				t.Errorf("detail = %s, want %s", p.Detail, tc.expectedDetail)
+			}
+			if p.Title != http.StatusText(tc.expectedStatus) {
+				t.Errorf("title = %s, want %s", p.Title, http.StatusText(tc.expectedStatus))
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/users/123", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "req-1"))
+	rr := httptest.NewRecorder()
+
+	WriteError(rr, req, BadRequest("Invalid user ID"))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status code = %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("content type = %s, want %s", ct, ContentType)
+	}
+
+	var p Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatalf("Failed to decode problem: %v", err)
+	}
+	if p.Instance != "/v1/users/123" {
+		t.Errorf("instance = %s, want /v1/users/123", p.Instance)
+	}
+	if p.RequestID != "req-1" {
+		t.Errorf("request_id = %s, want req-1", p.RequestID)
+	}
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// DefaultBucket is the name of the key-value bucket used by NewKVStore
+const DefaultBucket = "RATE_LIMITS"
+
+// maxKVAttempts bounds the compare-and-set retries of a single request
+const maxKVAttempts = 5
+
+// KVStore shares limits across instances through a JetStream key-value bucket
+type KVStore struct {
+	kv jetstream.KeyValue
+}
+
+var _ Store = (*KVStore)(nil)
+
+// NewKVStore creates or updates the bucket and returns a store using it.
+// Entries are removed by the bucket after ttl, which must be at least the
+// longest window of the limits used with the store.
+func NewKVStore(ctx context.Context, js jetstream.JetStream, bucket string, ttl time.Duration) (*KVStore, error) {
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      bucket,
+		Description: "Rate limit buckets",
+		TTL:         ttl,
+		History:     1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating rate limit bucket: %w", err)
+	}
+	return &KVStore{kv: kv}, nil
+}
+
+// Allow records a request for key if limit allows it, retrying when another
+// instance updated the key concurrently
+func (s *KVStore) Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	// Hashing yields valid KV keys for any client identity
+	sum := sha256.Sum256([]byte(key))
+	kvKey := hex.EncodeToString(sum[:16])
+
+	for range maxKVAttempts {
+		result, err := s.allow(ctx, kvKey, limit, now)
+		if !errors.Is(err, ErrConflict) {
+			return result, err
+		}
+	}
+	return Result{}, ErrConflict
+}
+
+func (s *KVStore) allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	var tat time.Time
+	var revision uint64
+
+	entry, err := s.kv.Get(ctx, key)
+	switch {
+	case errors.Is(err, jetstream.ErrKeyNotFound):
+	case err != nil:
+		return Result{}, fmt.Errorf("getting rate limit: %w", err)
+	default:
+		nanos, err := strconv.ParseInt(string(entry.Value()), 10, 64)
+		if err == nil {
+			tat = time.Unix(0, nanos)
+		}
+		revision = entry.Revision()
+	}
+
+	newTat, result := gcra(limit, tat, now)
+	if !result.Allowed {
+		return result, nil
+	}
+
+	value := []byte(strconv.FormatInt(newTat.UnixNano(), 10))
+	if revision == 0 {
+		_, err = s.kv.Create(ctx, key, value)
+	} else {
+		_, err = s.kv.Update(ctx, key, value, revision)
+	}
+	if err != nil {
+		var apiErr *jetstream.APIError
+		if errors.Is(err, jetstream.ErrKeyExists) || (errors.As(err, &apiErr) && apiErr.ErrorCode == jetstream.JSErrCodeStreamWrongLastSequence) {
+			return Result{}, ErrConflict
+		}
+		return Result{}, fmt.Errorf("updating rate limit: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,140 @@
+// Package ratelimit implements token bucket rate limiting with the generic
+// cell rate algorithm, which stores a single timestamp per key and can
+// therefore be shared across instances with a compare-and-set store
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Window, with bursts of up to Requests
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Enabled reports whether l limits anything
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Window > 0
+}
+
+// String formats l as accepted by ParseLimit
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Window)
+}
+
+// interval is the time it takes for one request to be refilled
+func (l Limit) interval() time.Duration {
+	return l.Window / time.Duration(l.Requests)
+}
+
+// ParseLimit parses a limit written as "requests/window", e.g. "100/1m"
+func ParseLimit(s string) (Limit, error) {
+	requests, window, found := strings.Cut(strings.TrimSpace(s), "/")
+	if !found {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, expected requests/window", s)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: window must be a positive duration", s)
+	}
+	return Limit{Requests: n, Window: d}, nil
+}
+
+// Result is the outcome of a request against a limit
+type Result struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining is the number of requests that could be made right now
+	Remaining int
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when
+	// this one was allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps the state of rate limited keys
+type Store interface {
+	// Allow records a request for key if limit allows it at now
+	Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// ErrConflict is returned by compare-and-set updates that lost a race
+var ErrConflict = errors.New("rate limit state changed concurrently")
+
+// gcra applies a request at now to the theoretical arrival time tat stored
+// for a key, returning the new tat to store when the request is allowed
+func gcra(limit Limit, tat time.Time, now time.Time) (time.Time, Result) {
+	interval := limit.interval()
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-limit.Window)
+
+	if now.Before(allowAt) {
+		return tat, Result{
+			Allowed:    false,
+			Limit:      limit,
+			Remaining:  0,
+			ResetAfter: tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}
+	}
+
+	return newTat, Result{
+		Allowed:    true,
+		Limit:      limit,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTat.Sub(now),
+	}
+}
+
+// MemoryStore keeps limits for a single instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tats: make(map[string]time.Time)}
+}
+
+// Allow records a request for key if limit allows it
+func (s *MemoryStore) Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Keys whose bucket is full again carry no state
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, tat := range s.tats {
+			if !tat.After(now) {
+				delete(s.tats, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	tat, result := gcra(limit, s.tats[key], now)
+	if result.Allowed {
+		s.tats[key] = tat
+	}
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging/natstest"
+	"github.com/LexiconIndonesia/go-http-service-template/common/ratelimit"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ratelimit.Limit
+		wantErr bool
+	}{
+		{input: "100/1m", want: ratelimit.Limit{Requests: 100, Window: time.Minute}},
+		{input: " 5/1s ", want: ratelimit.Limit{Requests: 5, Window: time.Second}},
+		{input: "100", wantErr: true},
+		{input: "0/1m", wantErr: true},
+		{input: "ten/1m", wantErr: true},
+		{input: "10/minute", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ratelimit.ParseLimit(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseLimit(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseLimit(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+// testStore checks the behaviour shared by all stores
+func testStore(t *testing.T, store ratelimit.Store) {
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 3, Window: 3 * time.Second}
+	now := time.Now()
+
+	// The full burst is allowed
+	for i := range limit.Requests {
+		result, err := store.Allow(ctx, "client", limit, now)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if !result.Allowed || result.Remaining != limit.Requests-1-i {
+			t.Errorf("Request %d: got allowed %v remaining %d", i, result.Allowed, result.Remaining)
+		}
+	}
+
+	// The next request has to wait for one interval
+	result, err := store.Allow(ctx, "client", limit, now)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed || result.RetryAfter != time.Second || result.ResetAfter != 3*time.Second {
+		t.Errorf("Expected rejection with retry after 1s and reset after 3s, got %+v", result)
+	}
+
+	// Other keys have their own budget
+	if result, _ := store.Allow(ctx, "other", limit, now); !result.Allowed {
+		t.Errorf("Expected another key to be allowed")
+	}
+
+	// One request is refilled after an interval
+	later := now.Add(time.Second)
+	if result, _ := store.Allow(ctx, "client", limit, later); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Expected one request after an interval, got %+v", result)
+	}
+	if result, _ := store.Allow(ctx, "client", limit, later); result.Allowed {
+		t.Errorf("Expected a single refilled request")
+	}
+
+	// The bucket is full again after the window
+	if result, _ := store.Allow(ctx, "client", limit, later.Add(limit.Window)); !result.Allowed || result.Remaining != limit.Requests-1 {
+		t.Errorf("Expected a full bucket after the window, got %+v", result)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, ratelimit.NewMemoryStore())
+}
+
+func TestKVStore(t *testing.T) {
+	client := natstest.NewClient(t)
+
+	store, err := ratelimit.NewKVStore(context.Background(), client.GetJetStream(), ratelimit.DefaultBucket, time.Minute)
+	if err != nil {
+		t.Fatalf("NewKVStore() error = %v", err)
+	}
+	testStore(t, store)
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Bind sets the fields of the struct pointed to by dst from the chi path
+// parameters, request headers and query string, using the `path:"name"`,
+// `header:"Name"` and `query:"name"` tags. Missing parameters leave the field
+// untouched, use validate tags to require them. Supported field types are
+// strings, booleans, numbers, types implementing encoding.TextUnmarshaler,
+// pointers to those, and slices of those for headers and query parameters.
+// Repeated headers bound to a single value are joined with commas.
+func Bind(r *http.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return bindStruct(r, v.Elem())
+}
+
+func bindStruct(r *http.Request, v reflect.Value) error {
+	t := v.Type()
+	query := r.URL.Query()
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindStruct(r, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name := field.Tag.Get("path"); name != "" {
+			raw := chi.URLParam(r, name)
+			if raw == "" {
+				continue
+			}
+			if err := setValue(fv, raw); err != nil {
+				return problem.BadRequest(fmt.Sprintf("Invalid path parameter %s", name)).Wrap(err)
+			}
+			continue
+		}
+
+		if name := field.Tag.Get("header"); name != "" {
+			values := r.Header.Values(name)
+			if len(values) == 0 {
+				continue
+			}
+			if fv.Kind() != reflect.Slice {
+				values = []string{strings.Join(values, ", ")}
+			}
+			if err := setValues(fv, values); err != nil {
+				return problem.BadRequest(fmt.Sprintf("Invalid header %s", name)).Wrap(err)
+			}
+			continue
+		}
+
+		if name := field.Tag.Get("query"); name != "" {
+			values, ok := query[name]
+			if !ok || len(values) == 0 {
+				continue
+			}
+			if err := setValues(fv, values); err != nil {
+				return problem.BadRequest(fmt.Sprintf("Invalid query parameter %s", name)).Wrap(err)
+			}
+		}
+	}
+	return nil
+}
+
+// setValues sets a slice field from all values, or any other field from the first one
+func setValues(v reflect.Value, values []string) error {
+	if v.Kind() != reflect.Slice || v.Type().Implements(textUnmarshalerType) || reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return setValue(v, values[0])
+	}
+
+	slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+	for i, raw := range values {
+		if err := setValue(slice.Index(i), raw); err != nil {
+			return err
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+// setValue parses raw into v according to its type
+func setValue(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setValue(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported parameter type %s", v.Type())
+	}
+	return nil
+}
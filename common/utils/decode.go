@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/validation"
+)
+
+// DefaultMaxBodyBytes is the default limit of a JSON request body
+const DefaultMaxBodyBytes int64 = 1 << 20 // 1MB
+
+type decodeOptions struct {
+	maxBodyBytes int64
+}
+
+// DecodeOption configures DecodeAndValidate
+type DecodeOption func(*decodeOptions)
+
+// WithMaxBodyBytes overrides the maximum request body size
+func WithMaxBodyBytes(n int64) DecodeOption {
+	return func(o *decodeOptions) {
+		o.maxBodyBytes = n
+	}
+}
+
+type maxBodyBytesKey struct{}
+
+// ContextWithMaxBodyBytes sets the request body limit used by Decode for
+// requests with ctx, as configured per route group
+func ContextWithMaxBodyBytes(ctx context.Context, n int64) context.Context {
+	return context.WithValue(ctx, maxBodyBytesKey{}, n)
+}
+
+// MaxBodyBytes returns the request body limit set on ctx, or
+// DefaultMaxBodyBytes
+func MaxBodyBytes(ctx context.Context) int64 {
+	if n, ok := ctx.Value(maxBodyBytesKey{}).(int64); ok {
+		return n
+	}
+	return DefaultMaxBodyBytes
+}
+
+// DecodeAndValidate decodes the JSON request body into T and validates it with
+// the shared validator. The body must have a JSON content type, fit in the size
+// limit, contain a single JSON value and only known fields. Failures are
+// returned as *problem.Error (400, 413 or 415) ready for problem.WriteError.
+func DecodeAndValidate[T any](w http.ResponseWriter, r *http.Request, opts ...DecodeOption) (T, error) {
+	var v T
+
+	if err := Decode(w, r, &v, opts...); err != nil {
+		return v, err
+	}
+
+	if err := validation.Struct(v); err != nil {
+		return v, validation.Error(r, err)
+	}
+
+	return v, nil
+}
+
+// Decode decodes the JSON request body into dst with the same checks as
+// DecodeAndValidate, without validating the result
+func Decode(w http.ResponseWriter, r *http.Request, dst interface{}, opts ...DecodeOption) error {
+	o := decodeOptions{maxBodyBytes: MaxBodyBytes(r.Context())}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		return problem.UnsupportedMediaType("Content-Type must be application/json")
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return problem.BadRequest("Request body must not be empty")
+	}
+
+	body := http.MaxBytesReader(w, r.Body, o.maxBodyBytes)
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err, o.maxBodyBytes)
+	}
+
+	// A second value, even whitespace separated, means the body was not a single JSON value
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return decodeError(err, o.maxBodyBytes)
+		}
+		return problem.BadRequest("Request body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// decodeError maps a json decoding error to a problem error
+func decodeError(err error, maxBodyBytes int64) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return problem.PayloadTooLarge(fmt.Sprintf("Request body must not be larger than %d bytes", maxBodyBytes))
+	case errors.As(err, &syntaxErr):
+		return problem.BadRequest(fmt.Sprintf("Request body contains malformed JSON at position %d", syntaxErr.Offset)).Wrap(err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return problem.BadRequest("Request body contains malformed JSON").Wrap(err)
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return problem.BadRequest(fmt.Sprintf("Request body field %q must be of type %s", typeErr.Field, typeErr.Type)).Wrap(err)
+		}
+		return problem.BadRequest(fmt.Sprintf("Request body must be of type %s", typeErr.Type)).Wrap(err)
+	case errors.Is(err, io.EOF):
+		return problem.BadRequest("Request body must not be empty")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return problem.BadRequest("Request body contains unknown field " + field).Wrap(err)
+	default:
+		return problem.BadRequest("Invalid request body").Wrap(err)
+	}
+}
+
+// isJSONContentType reports whether contentType is application/json or a
+// +json structured syntax suffix type
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+)
+
+type decodeTestRequest struct {
+	Name  string `json:"name" validate:"required"`
+	Count int    `json:"count"`
+}
+
+func TestDecodeAndValidate(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		opts           []DecodeOption
+		expectedStatus int
+	}{
+		{name: "Valid request", contentType: "application/json", body: `{"name":"test","count":1}`},
+		{name: "Charset parameter", contentType: "application/json; charset=utf-8", body: `{"name":"test"}`},
+		{name: "Structured syntax suffix", contentType: "application/merge-patch+json", body: `{"name":"test"}`},
+		{name: "Missing content type", contentType: "", body: `{"name":"test"}`, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Wrong content type", contentType: "text/plain", body: `{"name":"test"}`, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Empty body", contentType: "application/json", body: ``, expectedStatus: http.StatusBadRequest},
+		{name: "Malformed JSON", contentType: "application/json", body: `{"name":`, expectedStatus: http.StatusBadRequest},
+		{name: "Syntax error", contentType: "application/json", body: `{"name" "test"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Wrong type", contentType: "application/json", body: `{"name":"test","count":"one"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown field", contentType: "application/json", body: `{"name":"test","extra":true}`, expectedStatus: http.StatusBadRequest},
+		{name: "Trailing data", contentType: "application/json", body: `{"name":"test"} {"name":"again"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Validation failure", contentType: "application/json", body: `{"count":1}`, expectedStatus: http.StatusBadRequest},
+		{name: "Body too large", contentType: "application/json", body: `{"name":"` + strings.Repeat("a", 64) + `"}`, opts: []DecodeOption{WithMaxBodyBytes(32)}, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rr := httptest.NewRecorder()
+
+			got, err := DecodeAndValidate[decodeTestRequest](rr, req, tc.opts...)
+
+			if tc.expectedStatus == 0 {
+				if err != nil {
+					t.Fatalf("DecodeAndValidate() error = %v", err)
+				}
+				if got.Name != "test" {
+					t.Errorf("Expected name test, got %s", got.Name)
+				}
+				return
+			}
+
+			var perr *problem.Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("Expected a problem error, got %v", err)
+			}
+			if perr.Status != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d (%s)", tc.expectedStatus, perr.Status, perr.Detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+)
+
+// NewETag returns a quoted entity tag hashed from parts, such as the bytes of
+// a representation, or a resource ID and its update time. Weak tags are
+// prefixed with W/ and only claim semantic equivalence.
+func NewETag(weak bool, parts ...any) string {
+	h := sha256.New()
+	for _, part := range parts {
+		switch part := part.(type) {
+		case []byte:
+			h.Write(part)
+		case time.Time:
+			h.Write([]byte(part.UTC().Format(time.RFC3339Nano)))
+		default:
+			fmt.Fprint(h, part)
+		}
+		h.Write([]byte{0})
+	}
+
+	tag := `"` + base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// etagCodings are the content codings that may suffix strong entity tags of
+// compressed responses, see ETagWithCoding
+var etagCodings = []string{"br", "zstd", "gzip"}
+
+// ETagWithCoding returns the entity tag of the representation of etag encoded
+// with coding, such as "abc-gzip" for "abc". The bytes of a compressed body
+// differ, so a strong tag must differ as well. Weak tags only claim semantic
+// equivalence and are returned as is.
+func ETagWithCoding(etag, coding string) string {
+	weak, value := splitETag(etag)
+	if weak || coding == "" || !strings.HasSuffix(value, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(value, `"`) + "-" + coding + `"`
+}
+
+// ETagMatch reports whether header, an If-Match or If-None-Match value,
+// matches etag. "*" matches any etag. Strong comparison, required for
+// If-Match, never matches weak tags. Weak comparison, used for
+// If-None-Match, ignores the W/ prefix. Tags of compressed representations,
+// see ETagWithCoding, match the tag of the resource they were derived from.
+func ETagMatch(header, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	etagWeak, etagValue := splitETag(etag)
+	if strong && etagWeak {
+		return false
+	}
+
+	for _, candidate := range parseETags(header) {
+		weak, value := splitETag(candidate)
+		if strong && weak {
+			continue
+		}
+		if value == etagValue {
+			return true
+		}
+		for _, coding := range etagCodings {
+			if value == ETagWithCoding(etagValue, coding) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckIfMatch returns a 412 problem when ifMatch is set and does not match
+// the current etag of a resource. An empty ifMatch makes the request
+// unconditional. Strong etags are compared strongly. Weak etags, used by
+// resources with several representations such as JSON and MessagePack, are
+// compared weakly since they identify the version rather than the bytes.
+func CheckIfMatch(ifMatch, etag string) error {
+	weak, _ := splitETag(etag)
+	if ifMatch == "" || ETagMatch(ifMatch, etag, !weak) {
+		return nil
+	}
+	return problem.PreconditionFailed("The resource was modified since it was retrieved")
+}
+
+// parseETags splits a comma separated list of entity tags. Commas may appear
+// inside quoted tags, so the list is scanned rather than split.
+func parseETags(header string) []string {
+	var tags []string
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			return tags
+		}
+
+		start := 0
+		if strings.HasPrefix(header, "W/") {
+			start = 2
+		}
+		if len(header) <= start || header[start] != '"' {
+			// Malformed, skip to the next element
+			_, header, _ = strings.Cut(header, ",")
+			continue
+		}
+
+		end := strings.IndexByte(header[start+1:], '"')
+		if end < 0 {
+			return tags
+		}
+		end += start + 2
+		tags = append(tags, header[:end])
+		header = header[end:]
+	}
+}
+
+func splitETag(tag string) (bool, string) {
+	if value, weak := strings.CutPrefix(tag, "W/"); weak {
+		return true, value
+	}
+	return false, tag
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+)
+
+func TestNewETag(t *testing.T) {
+	now := time.Now()
+
+	strong := NewETag(false, "id", now)
+	if strong != NewETag(false, "id", now.In(time.FixedZone("WIB", 7*3600))) {
+		t.Errorf("Expected the same tag for the same instant in another zone")
+	}
+	if strong == NewETag(false, "id", now.Add(time.Microsecond)) {
+		t.Errorf("Expected a different tag for a different version")
+	}
+	if strong == NewETag(false, "i", "d", now) {
+		t.Errorf("Expected parts to be delimited")
+	}
+	if weak := NewETag(true, "id", now); weak != "W/"+strong {
+		t.Errorf("Expected weak tag W/%s, got %s", strong, weak)
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		etag   string
+		strong bool
+		match  bool
+	}{
+		{name: "Empty header", header: "", etag: `"a"`, match: false},
+		{name: "Same tag", header: `"a"`, etag: `"a"`, strong: true, match: true},
+		{name: "Other tag", header: `"b"`, etag: `"a"`, strong: true, match: false},
+		{name: "List", header: `"b", "a"`, etag: `"a"`, strong: true, match: true},
+		{name: "Comma inside tag", header: `"a,b"`, etag: `"b"`, match: false},
+		{name: "Wildcard", header: "*", etag: `"a"`, strong: true, match: true},
+		{name: "Weak header weak comparison", header: `W/"a"`, etag: `"a"`, match: true},
+		{name: "Weak header strong comparison", header: `W/"a"`, etag: `"a"`, strong: true, match: false},
+		{name: "Weak etag strong comparison", header: `"a"`, etag: `W/"a"`, strong: true, match: false},
+		{name: "Malformed element skipped", header: `a, "b"`, etag: `"b"`, match: true},
+		{name: "No current etag", header: "*", etag: "", match: false},
+		{name: "Compressed tag strong comparison", header: `"a-gzip"`, etag: `"a"`, strong: true, match: true},
+		{name: "Compressed tag weak comparison", header: `"a-br"`, etag: `"a"`, match: true},
+		{name: "Unknown coding", header: `"a-lzma"`, etag: `"a"`, match: false},
+		{name: "Compressed tag of another resource", header: `"b-gzip"`, etag: `"a"`, strong: true, match: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ETagMatch(tc.header, tc.etag, tc.strong); got != tc.match {
+				t.Errorf("ETagMatch(%q, %q, %v) = %v, want %v", tc.header, tc.etag, tc.strong, got, tc.match)
+			}
+		})
+	}
+}
+
+func TestETagWithCoding(t *testing.T) {
+	tests := []struct {
+		etag     string
+		expected string
+	}{
+		{etag: `"a"`, expected: `"a-gzip"`},
+		{etag: `W/"a"`, expected: `W/"a"`},
+		{etag: "", expected: ""},
+	}
+
+	for _, tc := range tests {
+		if got := ETagWithCoding(tc.etag, "gzip"); got != tc.expected {
+			t.Errorf("ETagWithCoding(%q) = %q, want %q", tc.etag, got, tc.expected)
+		}
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	if err := CheckIfMatch("", `"a"`); err != nil {
+		t.Errorf("Expected no error without If-Match, got %v", err)
+	}
+	if err := CheckIfMatch(`"a"`, `"a"`); err != nil {
+		t.Errorf("Expected no error for a matching tag, got %v", err)
+	}
+
+	var perr *problem.Error
+	if err := CheckIfMatch(`"b"`, `"a"`); !errors.As(err, &perr) || perr.Status != http.StatusPreconditionFailed {
+		t.Errorf("Expected a 412 problem, got %v", err)
+	}
+	if err := CheckIfMatch(`W/"a"`, `"a"`); err == nil {
+		t.Error("Expected a weak tag not to match a strong etag")
+	}
+	if err := CheckIfMatch(`W/"a"`, `W/"a"`); err != nil {
+		t.Errorf("Expected a weak tag to match a weak etag, got %v", err)
+	}
+	if err := CheckIfMatch(`W/"b"`, `W/"a"`); err == nil {
+		t.Error("Expected another weak tag not to match")
+	}
+}
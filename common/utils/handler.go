@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/validation"
+)
+
+// HandlerFunc is a typed handler. It receives the bound and validated request
+// and returns the response data, or an error that is rendered as a problem.
+type HandlerFunc[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// NoContent is the response type of handlers that return no body
+type NoContent struct{}
+
+// Handle adapts a typed handler to an http.HandlerFunc.
+//
+// The request is decoded from the JSON body for POST, PUT and PATCH (see
+// Decode). Decoding is skipped when Req has no body fields and the request has
+// no body. The request is then bound from path parameters, headers and query
+// parameters using the `path:"name"`, `header:"Name"` and `query:"name"`
+// struct tags (see Bind), and finally validated with the shared validator.
+//
+// On success the response is written with the given status wrapped in the
+// standard Response envelope, as JSON or MessagePack depending on the Accept
+// header, or as CSV when Resp implements CSVMarshaler (see Render). Requests
+// accepting none of these fail with 406 before the handler runs. Errors are
+// written with problem.WriteError, so handlers return *problem.Error values to
+// choose the status.
+func Handle[Req, Resp any](status int, h HandlerFunc[Req, Resp], opts ...DecodeOption) http.HandlerFunc {
+	bodyFields := hasBodyFields(reflect.TypeFor[Req]())
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+
+		// Negotiate first so that unacceptable requests have no side effects
+		var zero Resp
+		_, noContent := any(zero).(NoContent)
+		noContent = noContent || status == http.StatusNoContent
+		mediaType := MediaTypeJSON
+		if !noContent {
+			offers := Offers(zero)
+			var ok bool
+			if mediaType, ok = Negotiate(r, offers...); !ok {
+				problem.WriteError(w, r, notAcceptable(offers))
+				return
+			}
+		}
+
+		if hasBody(r.Method) && (bodyFields || r.ContentLength != 0) {
+			if err := Decode(w, r, &req, opts...); err != nil {
+				problem.WriteError(w, r, err)
+				return
+			}
+		}
+
+		if err := Bind(r, &req); err != nil {
+			problem.WriteError(w, r, err)
+			return
+		}
+
+		if err := validation.Struct(req); err != nil {
+			problem.WriteError(w, r, validation.Error(r, err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), exchangeContextKey{}, exchange{w: w, r: r})
+		resp, err := h(ctx, req)
+		if err != nil {
+			problem.WriteError(w, r, err)
+			return
+		}
+
+		if noContent {
+			w.WriteHeader(status)
+			return
+		}
+		renderAs(w, mediaType, status, resp)
+	}
+}
+
+type exchangeContextKey struct{}
+
+// exchange is the request and response of a call made by Handle
+type exchange struct {
+	w http.ResponseWriter
+	r *http.Request
+}
+
+// ResponseHeader returns the header map of the response written by Handle,
+// letting typed handlers set headers such as Link. Outside of Handle it
+// returns an empty header that is discarded.
+func ResponseHeader(ctx context.Context) http.Header {
+	if ex, ok := ctx.Value(exchangeContextKey{}).(exchange); ok {
+		return ex.w.Header()
+	}
+	return http.Header{}
+}
+
+// RequestURL returns the URL of the request handled by Handle, or an empty URL
+// outside of Handle
+func RequestURL(ctx context.Context) *url.URL {
+	if ex, ok := ctx.Value(exchangeContextKey{}).(exchange); ok {
+		return ex.r.URL
+	}
+	return &url.URL{}
+}
+
+// hasBody reports whether requests with method carry a JSON body to decode
+func hasBody(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasBodyFields reports whether t is decoded from the JSON body, that is
+// whether it is not a struct or has a field that is not skipped by a json:"-"
+// tag or bound only from the path, headers or query
+func hasBodyFields(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if hasBodyFields(field.Type) {
+				return true
+			}
+			continue
+		}
+
+		name, ok := field.Tag.Lookup("json")
+		if name == "-" {
+			continue
+		}
+		if !ok && (field.Tag.Get("path") != "" || field.Tag.Get("header") != "" || field.Tag.Get("query") != "") {
+			continue
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type handleTestRequest struct {
+	ID    uuid.UUID `json:"-" path:"id"`
+	Limit int       `json:"-" query:"limit" validate:"omitempty,max=100"`
+	Tags  []string  `json:"-" query:"tag"`
+	Trace string    `json:"-" header:"X-Trace"`
+	Name  string    `json:"name" validate:"required"`
+}
+
+type handleTestResponse struct {
+	ID    string   `json:"id"`
+	Limit int      `json:"limit"`
+	Tags  []string `json:"tags"`
+	Trace string   `json:"trace"`
+	Name  string   `json:"name"`
+}
+
+func TestHandle(t *testing.T) {
+	handler := func(ctx context.Context, req handleTestRequest) (handleTestResponse, error) {
+		if req.Name == "missing" {
+			return handleTestResponse{}, problem.NotFound("Item not found")
+		}
+		return handleTestResponse{ID: req.ID.String(), Limit: req.Limit, Tags: req.Tags, Trace: req.Trace, Name: req.Name}, nil
+	}
+
+	r := chi.NewRouter()
+	r.Put("/items/{id}", Handle(http.StatusOK, handler))
+
+	id := uuid.New().String()
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Bound request", path: "/items/" + id + "?limit=10&tag=a&tag=b", body: `{"name":"item"}`, expectedStatus: http.StatusOK},
+		{name: "Invalid path parameter", path: "/items/not-a-uuid", body: `{"name":"item"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid query parameter", path: "/items/" + id + "?limit=ten", body: `{"name":"item"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Validation failure", path: "/items/" + id + "?limit=1000", body: `{"name":"item"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Typed error", path: "/items/" + id, body: `{"name":"missing"}`, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("X-Trace", "abc")
+			req.Header.Add("X-Trace", "def")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v: %s", rr.Code, tc.expectedStatus, rr.Body.String())
+			}
+
+			if tc.expectedStatus != http.StatusOK {
+				if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+					t.Errorf("Expected content type %s, got %s", problem.ContentType, ct)
+				}
+				return
+			}
+
+			var response struct {
+				Data handleTestResponse `json:"data"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Data.ID != id || response.Data.Limit != 10 || response.Data.Name != "item" {
+				t.Errorf("Unexpected response %+v", response.Data)
+			}
+			if response.Data.Trace != "abc, def" {
+				t.Errorf("Expected joined header abc, def, got %q", response.Data.Trace)
+			}
+			if len(response.Data.Tags) != 2 || response.Data.Tags[0] != "a" || response.Data.Tags[1] != "b" {
+				t.Errorf("Expected tags [a b], got %v", response.Data.Tags)
+			}
+		})
+	}
+}
+
+type csvTestResponse struct {
+	Names []string `json:"names"`
+}
+
+func (c csvTestResponse) MarshalCSV() ([][]string, error) {
+	records := [][]string{{"name"}}
+	for _, name := range c.Names {
+		records = append(records, []string{name})
+	}
+	return records, nil
+}
+
+func TestHandleNegotiation(t *testing.T) {
+	var calls int
+	handler := func(ctx context.Context, req struct{}) (csvTestResponse, error) {
+		calls++
+		return csvTestResponse{Names: []string{"a", "b,c"}}, nil
+	}
+	h := Handle(http.StatusOK, handler)
+
+	tests := []struct {
+		name                string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{name: "Default JSON", accept: "", expectedStatus: http.StatusOK, expectedContentType: MediaTypeJSON},
+		{name: "MessagePack", accept: MediaTypeMsgPack, expectedStatus: http.StatusOK, expectedContentType: MediaTypeMsgPack},
+		{name: "CSV", accept: "text/csv", expectedStatus: http.StatusOK, expectedContentType: "text/csv; charset=utf-8"},
+		{name: "Not acceptable", accept: "application/xml", expectedStatus: http.StatusNotAcceptable, expectedContentType: problem.ContentType},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			calls = 0
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatus)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != tc.expectedContentType {
+				t.Errorf("Expected content type %s, got %s", tc.expectedContentType, ct)
+			}
+
+			if tc.expectedStatus == http.StatusNotAcceptable {
+				if calls != 0 {
+					t.Errorf("Handler should not run for unacceptable requests")
+				}
+				return
+			}
+
+			switch tc.accept {
+			case MediaTypeMsgPack:
+				var response struct {
+					Status int             `json:"status"`
+					Data   csvTestResponse `json:"data"`
+				}
+				dec := msgpack.NewDecoder(rr.Body)
+				dec.SetCustomStructTag("json")
+				if err := dec.Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if response.Status != http.StatusOK || len(response.Data.Names) != 2 {
+					t.Errorf("Unexpected response %+v", response)
+				}
+			case "text/csv":
+				if body := rr.Body.String(); body != "name\na\n\"b,c\"\n" {
+					t.Errorf("Unexpected CSV body %q", body)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleWithoutBody(t *testing.T) {
+	type actionRequest struct {
+		ID    uuid.UUID `json:"-" path:"id"`
+		Force bool      `json:"-" query:"force"`
+	}
+	handler := func(ctx context.Context, req actionRequest) (handleTestResponse, error) {
+		return handleTestResponse{ID: req.ID.String()}, nil
+	}
+
+	r := chi.NewRouter()
+	r.Post("/items/{id}/archive", Handle(http.StatusOK, handler))
+	r.Post("/items", Handle(http.StatusCreated, func(ctx context.Context, req handleTestRequest) (handleTestResponse, error) {
+		return handleTestResponse{Name: req.Name}, nil
+	}))
+
+	id := uuid.New().String()
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "No body fields and no body", path: "/items/" + id + "/archive?force=true", expectedStatus: http.StatusOK},
+		{name: "No body fields with a body", path: "/items/" + id + "/archive", body: `not json`, expectedStatus: http.StatusBadRequest},
+		{name: "Body fields and no body", path: "/items", expectedStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v: %s", rr.Code, tc.expectedStatus, rr.Body.String())
+			}
+		})
+	}
+}
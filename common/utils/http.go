@@ -5,12 +5,12 @@ import (
 	"net/http"
 )
 
-// Response is the standard API response structure
+// Response is the standard API response structure for successful requests,
+// errors are written as problems by the problem package
 type Response struct {
 	Status  int         `json:"status"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
 }
 
 // WriteJSON writes a JSON response with the given status code and data
@@ -42,18 +42,3 @@ func WriteMessage(w http.ResponseWriter, statusCode int, message string) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
-
-// WriteError writes a JSON response with the given status code and error message
-func WriteError(w http.ResponseWriter, statusCode int, errorMessage string) {
-	response := Response{
-		Status: statusCode,
-		Error:  errorMessage,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
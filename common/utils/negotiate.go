@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptRange is one element of an Accept or Accept-Encoding header
+type acceptRange struct {
+	value string
+	q     float64
+}
+
+// parseAccept parses a comma separated list of values with optional q
+// parameters, ignoring other parameters
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil && parsed >= 0 && parsed <= 1 {
+					q = parsed
+				}
+			}
+		}
+		ranges = append(ranges, acceptRange{value: value, q: q})
+	}
+	return ranges
+}
+
+// Negotiate returns the media type among offers that best matches the Accept
+// header of r. More specific ranges take precedence over wildcards, and ties
+// go to the earliest offer. Without an Accept header the first offer is
+// returned. ok is false when no offer is acceptable.
+func Negotiate(r *http.Request, offers ...string) (string, bool) {
+	header := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(header) == "" {
+		if len(offers) == 0 {
+			return "", false
+		}
+		return offers[0], true
+	}
+
+	ranges := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		offerType, offerSub, _ := strings.Cut(strings.ToLower(offer), "/")
+
+		// The most specific matching range decides the quality of the offer
+		q, specificity := 0.0, -1
+		for _, ar := range ranges {
+			rangeType, rangeSub, _ := strings.Cut(ar.value, "/")
+			var s int
+			switch {
+			case rangeType == offerType && rangeSub == offerSub:
+				s = 2
+			case rangeType == offerType && rangeSub == "*":
+				s = 1
+			case rangeType == "*" && rangeSub == "*":
+				s = 0
+			default:
+				continue
+			}
+			if s > specificity {
+				q, specificity = ar.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// NegotiateEncoding returns the content coding among offers that best matches
+// the Accept-Encoding header of r, ties going to the earliest offer. It
+// returns "identity" when the response should not be encoded.
+func NegotiateEncoding(r *http.Request, offers ...string) string {
+	ranges := parseAccept(strings.Join(r.Header.Values("Accept-Encoding"), ","))
+
+	best, bestQ := "identity", 0.0
+	for _, offer := range offers {
+		q, exact := 0.0, false
+		for _, ar := range ranges {
+			switch {
+			case ar.value == offer:
+				q, exact = ar.q, true
+			case ar.value == "*" && !exact:
+				q = ar.q
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{MediaTypeJSON, MediaTypeMsgPack, MediaTypeCSV}
+
+	tests := []struct {
+		name     string
+		accept   string
+		expected string
+		ok       bool
+	}{
+		{name: "No header", accept: "", expected: MediaTypeJSON, ok: true},
+		{name: "Wildcard", accept: "*/*", expected: MediaTypeJSON, ok: true},
+		{name: "Exact", accept: "text/csv", expected: MediaTypeCSV, ok: true},
+		{name: "Quality", accept: "application/json;q=0.5, application/msgpack", expected: MediaTypeMsgPack, ok: true},
+		{name: "Specific range wins over wildcard", accept: "*/*;q=0.9, application/json;q=0.1", expected: MediaTypeMsgPack, ok: true},
+		{name: "Type wildcard", accept: "text/*", expected: MediaTypeCSV, ok: true},
+		{name: "Excluded", accept: "application/json;q=0, */*;q=0.1", expected: MediaTypeMsgPack, ok: true},
+		{name: "Browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: MediaTypeJSON, ok: true},
+		{name: "Not acceptable", accept: "application/xml", ok: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			got, ok := Negotiate(r, offers...)
+			if ok != tc.ok || got != tc.expected {
+				t.Errorf("Negotiate(%q) = %q, %v, want %q, %v", tc.accept, got, ok, tc.expected, tc.ok)
+			}
+		})
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	offers := []string{"br", "zstd", "gzip"}
+
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{acceptEncoding: "", expected: "identity"},
+		{acceptEncoding: "gzip, deflate", expected: "gzip"},
+		{acceptEncoding: "gzip, deflate, br, zstd", expected: "br"},
+		{acceptEncoding: "gzip;q=1.0, br;q=0.5", expected: "gzip"},
+		{acceptEncoding: "*", expected: "br"},
+		{acceptEncoding: "*, br;q=0", expected: "zstd"},
+		{acceptEncoding: "identity", expected: "identity"},
+	}
+
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		if got := NegotiateEncoding(r, offers...); got != tc.expected {
+			t.Errorf("NegotiateEncoding(%q) = %q, want %q", tc.acceptEncoding, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"strings"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Media types responses can be rendered as
+const (
+	MediaTypeJSON     = "application/json"
+	MediaTypeMsgPack  = "application/msgpack"
+	MediaTypeXMsgPack = "application/x-msgpack"
+	MediaTypeCSV      = "text/csv"
+)
+
+// CSVMarshaler is implemented by list responses that can also be rendered as
+// CSV. The first record is the header row.
+type CSVMarshaler interface {
+	MarshalCSV() ([][]string, error)
+}
+
+// Offers returns the media types data can be rendered as, JSON first
+func Offers(data interface{}) []string {
+	offers := []string{MediaTypeJSON, MediaTypeMsgPack, MediaTypeXMsgPack}
+	if _, ok := data.(CSVMarshaler); ok {
+		offers = append(offers, MediaTypeCSV)
+	}
+	return offers
+}
+
+// Render writes data with the media type negotiated from the Accept header of
+// r, see Offers. Clients accepting none of them get a 406 problem.
+func Render(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}) {
+	offers := Offers(data)
+	mediaType, ok := Negotiate(r, offers...)
+	if !ok {
+		problem.WriteError(w, r, notAcceptable(offers))
+		return
+	}
+	renderAs(w, mediaType, statusCode, data)
+}
+
+// renderAs writes data as mediaType, which must be one of Offers(data)
+func renderAs(w http.ResponseWriter, mediaType string, statusCode int, data interface{}) {
+	w.Header().Add("Vary", "Accept")
+
+	switch mediaType {
+	case MediaTypeMsgPack, MediaTypeXMsgPack:
+		writeMsgPack(w, mediaType, statusCode, data)
+	case MediaTypeCSV:
+		WriteCSV(w, statusCode, data.(CSVMarshaler))
+	default:
+		WriteJSON(w, statusCode, data)
+	}
+}
+
+// WriteMsgPack writes a MessagePack response with the given status code and
+// data wrapped in Response. Fields are named after their json tags.
+func WriteMsgPack(w http.ResponseWriter, statusCode int, data interface{}) {
+	writeMsgPack(w, MediaTypeMsgPack, statusCode, data)
+}
+
+func writeMsgPack(w http.ResponseWriter, contentType string, statusCode int, data interface{}) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetOmitEmpty(true)
+
+	if err := enc.Encode(Response{Status: statusCode, Data: data}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode MessagePack response")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// WriteCSV writes the records of data as a CSV response with the given status
+// code. CSV has no envelope, pagination is only available in headers.
+func WriteCSV(w http.ResponseWriter, statusCode int, data CSVMarshaler) {
+	records, err := data.MarshalCSV()
+	if err == nil {
+		var buf bytes.Buffer
+		cw := csv.NewWriter(&buf)
+		if err = cw.WriteAll(records); err == nil {
+			w.Header().Set("Content-Type", MediaTypeCSV+"; charset=utf-8")
+			w.WriteHeader(statusCode)
+			_, _ = w.Write(buf.Bytes())
+			return
+		}
+	}
+
+	log.Error().Err(err).Msg("Failed to encode CSV response")
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func notAcceptable(offers []string) *problem.Error {
+	return problem.NotAcceptable("Supported media types are " + strings.Join(offers, ", "))
+}
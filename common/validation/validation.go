@@ -0,0 +1,148 @@
+// Package validation provides the shared request validator and translates its
+// errors into field-level problem details in English or Bahasa Indonesia.
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	idTranslations "github.com/go-playground/validator/v10/translations/id"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
+)
+
+const (
+	// English is the default language of validation messages
+	English = "en"
+	// Indonesian is Bahasa Indonesia
+	Indonesian = "id"
+)
+
+var (
+	once       sync.Once
+	validate   *validator.Validate
+	translator *ut.UniversalTranslator
+
+	// supported lists the message languages in order of preference
+	supported = []language.Tag{language.English, language.Indonesian}
+	matcher   = language.NewMatcher(supported)
+)
+
+// setup creates the shared validator and registers the translations
+func setup() {
+	validate = validator.New(validator.WithRequiredStructEnabled())
+
+	// Report JSON field names, or path and query parameter names, so that
+	// clients can map errors to their request
+	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		if name, _, _ := strings.Cut(fld.Tag.Get("json"), ","); name != "" && name != "-" {
+			return name
+		}
+		for _, tag := range []string{"path", "query"} {
+			if name := fld.Tag.Get(tag); name != "" {
+				return name
+			}
+		}
+		return fld.Name
+	})
+
+	enLocale := en.New()
+	translator = ut.New(enLocale, enLocale, id.New())
+
+	enTrans, _ := translator.GetTranslator(English)
+	if err := enTranslations.RegisterDefaultTranslations(validate, enTrans); err != nil {
+		log.Error().Err(err).Msg("Failed to register English validation messages")
+	}
+	idTrans, _ := translator.GetTranslator(Indonesian)
+	if err := idTranslations.RegisterDefaultTranslations(validate, idTrans); err != nil {
+		log.Error().Err(err).Msg("Failed to register Indonesian validation messages")
+	}
+}
+
+// Validator returns the shared validator, for registering custom validations
+func Validator() *validator.Validate {
+	once.Do(setup)
+	return validate
+}
+
+// Struct validates s using its validate tags
+func Struct(s interface{}) error {
+	return Validator().Struct(s)
+}
+
+// Language returns the supported message language that best matches the
+// Accept-Language header of r, English by default
+func Language(r *http.Request) string {
+	if r == nil {
+		return English
+	}
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil || len(tags) == 0 {
+		return English
+	}
+	_, index, _ := matcher.Match(tags...)
+	base, _ := supported[index].Base()
+	return base.String()
+}
+
+// FieldErrors translates validation errors into field errors with messages in
+// lang. It returns nil when err is not a validator.ValidationErrors.
+func FieldErrors(err error, lang string) []problem.FieldError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+
+	Validator()
+	trans, found := translator.GetTranslator(lang)
+	if !found {
+		trans, _ = translator.GetTranslator(English)
+	}
+
+	fieldErrors := make([]problem.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		message := fe.Translate(trans)
+		// Rules without a translation fall back to the raw error, which
+		// contains Go struct names
+		if message == fe.Error() {
+			message = fe.Field() + " failed the " + fe.Tag() + " rule"
+		}
+		fieldErrors = append(fieldErrors, problem.FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: message,
+		})
+	}
+	return fieldErrors
+}
+
+// Error converts a validation error into a problem error with field details
+// in the language requested by r. Other errors become a plain bad request.
+func Error(r *http.Request, err error) *problem.Error {
+	fieldErrors := FieldErrors(err, Language(r))
+	if fieldErrors == nil {
+		return problem.BadRequest("Invalid request").Wrap(err)
+	}
+	return problem.Validation("Request validation failed", fieldErrors)
+}
+
+// fieldPath returns the JSON path of the field without the top level struct
+// name, e.g. "address.city" instead of "UserCreationRequest.address.city"
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if _, rest, found := strings.Cut(ns, "."); found {
+		return rest
+	}
+	return fe.Field()
+}
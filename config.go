@@ -2,8 +2,22 @@ package main
 
 import (
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/certs"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+	"github.com/LexiconIndonesia/go-http-service-template/common/ratelimit"
+	"github.com/LexiconIndonesia/go-http-service-template/middlewares"
+
+	"github.com/rs/zerolog/log"
 )
 
 func getEnv(key, defaultValue string) string {
@@ -36,6 +50,60 @@ func loadEnvUint(key string, result *uint) {
 	*result = uint(n)
 }
 
+func loadEnvBool(key string, result *bool) {
+	s, ok := os.LookupEnv(key)
+
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return
+	}
+	*result = b
+}
+
+func loadEnvDuration(key string, result *time.Duration) {
+	s, ok := os.LookupEnv(key)
+
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return
+	}
+	*result = d
+}
+
+func loadEnvStringSlice(key string, result *[]string) {
+	s, ok := os.LookupEnv(key)
+
+	if !ok {
+		return
+	}
+	values := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	*result = values
+}
+
+// loadEnvGroups calls set for each name=value pair of a comma separated
+// list, logging the invalid ones
+func loadEnvGroups(key string, set func(name, value string) error) {
+	var pairs []string
+	loadEnvStringSlice(key, &pairs)
+	for _, pair := range pairs {
+		name, value, _ := strings.Cut(pair, "=")
+		if err := set(strings.TrimSpace(name), strings.TrimSpace(value)); err != nil {
+			log.Warn().Err(err).Str("group", name).Msg("Ignoring " + key + " entry")
+		}
+	}
+}
+
 /* Configuration */
 
 /* PgSQL Configuration */
@@ -46,6 +114,8 @@ type pgSqlConfig struct {
 	SslMode  string `json:"ssl_mode"`
 	User     string `json:"user"`
 	Password string `json:"password"`
+	// ReplicaURLs are connection strings of optional read replicas
+	ReplicaURLs []string `json:"replica_urls"`
 }
 
 func (p pgSqlConfig) ConnStr() string {
@@ -70,6 +140,7 @@ func (p *pgSqlConfig) loadFromEnv() {
 	loadEnvString("POSTGRES_SSLMODE", &p.SslMode)
 	loadEnvString("POSTGRES_USERNAME", &p.User)
 	loadEnvString("POSTGRES_PASSWORD", &p.Password)
+	loadEnvStringSlice("POSTGRES_REPLICA_URLS", &p.ReplicaURLs)
 }
 
 /* Listen Configuration */
@@ -77,22 +148,245 @@ func (p *pgSqlConfig) loadFromEnv() {
 type listenConfig struct {
 	Host string `json:"host"`
 	Port uint   `json:"port"`
+	// H2C serves HTTP/2 without TLS, for internal traffic behind a proxy
+	H2C bool      `json:"h2c"`
+	TLS tlsConfig `json:"tls"`
 }
 
 func (l listenConfig) Addr() string {
 	return fmt.Sprintf("%s:%d", l.Host, l.Port)
 }
 
+// Scheme returns the URL scheme the server is reachable with
+func (l listenConfig) Scheme() string {
+	if l.TLS.Enabled() {
+		return "https"
+	}
+	return "http"
+}
+
 func defaultListenConfig() listenConfig {
 	return listenConfig{
 		Host: "127.0.0.1",
 		Port: 8080,
+		TLS: tlsConfig{
+			ReloadInterval: certs.DefaultReloadInterval,
+		},
 	}
 }
 
 func (l *listenConfig) loadFromEnv() {
 	loadEnvString("LISTEN_HOST", &l.Host)
 	loadEnvUint("LISTEN_PORT", &l.Port)
+	loadEnvBool("LISTEN_H2C", &l.H2C)
+	l.TLS.loadFromEnv()
+}
+
+/* TLS Configuration */
+
+type tlsConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// ClientCAFile enables mutual TLS, verifying client certificates against
+	// the CAs it contains
+	ClientCAFile string `json:"client_ca_file"`
+	// ClientAuth is none, optional or require, require by default when
+	// ClientCAFile is set
+	ClientAuth string `json:"client_auth"`
+	// ReloadInterval is how often the files are checked for changes
+	ReloadInterval time.Duration `json:"reload_interval"`
+}
+
+// Enabled reports whether the server listens with TLS
+func (t tlsConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+func (t *tlsConfig) loadFromEnv() {
+	loadEnvString("TLS_CERT_FILE", &t.CertFile)
+	loadEnvString("TLS_KEY_FILE", &t.KeyFile)
+	loadEnvString("TLS_CLIENT_CA_FILE", &t.ClientCAFile)
+	loadEnvString("TLS_CLIENT_AUTH", &t.ClientAuth)
+	loadEnvDuration("TLS_RELOAD_INTERVAL", &t.ReloadInterval)
+}
+
+/* HTTP Server Configuration */
+
+type httpConfig struct {
+	ReadTimeout       time.Duration `json:"read_timeout"`
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout"`
+	WriteTimeout      time.Duration `json:"write_timeout"`
+	IdleTimeout       time.Duration `json:"idle_timeout"`
+	// RequestTimeout cancels the request context, it should be shorter than
+	// WriteTimeout so that timeouts can still be answered
+	RequestTimeout time.Duration `json:"request_timeout"`
+	MaxHeaderBytes uint          `json:"max_header_bytes"`
+	MaxBodyBytes   uint          `json:"max_body_bytes"`
+	// Groups overrides the limits of route groups by name, such as a longer
+	// write timeout for streaming endpoints
+	Groups map[string]routeGroupConfig `json:"groups"`
+	CORS   corsConfig                  `json:"cors"`
+}
+
+// routeGroupConfig holds the limits a route group overrides, nil fields use
+// the server wide value and zero disables the limit
+type routeGroupConfig struct {
+	RequestTimeout *time.Duration `json:"request_timeout,omitempty"`
+	WriteTimeout   *time.Duration `json:"write_timeout,omitempty"`
+	MaxBodyBytes   *uint          `json:"max_body_bytes,omitempty"`
+}
+
+// limits returns the limits of a route group
+func (h httpConfig) limits(group string) middlewares.LimitsOptions {
+	opts := middlewares.LimitsOptions{
+		RequestTimeout: h.RequestTimeout,
+		WriteTimeout:   h.WriteTimeout,
+		MaxBodyBytes:   int64(h.MaxBodyBytes),
+	}
+
+	g := h.Groups[group]
+	if g.RequestTimeout != nil {
+		opts.RequestTimeout = *g.RequestTimeout
+	}
+	if g.WriteTimeout != nil {
+		opts.WriteTimeout = *g.WriteTimeout
+	}
+	if g.MaxBodyBytes != nil {
+		opts.MaxBodyBytes = int64(*g.MaxBodyBytes)
+	}
+	return opts
+}
+
+func (h *httpConfig) loadFromEnv() {
+	loadEnvDuration("HTTP_READ_TIMEOUT", &h.ReadTimeout)
+	loadEnvDuration("HTTP_READ_HEADER_TIMEOUT", &h.ReadHeaderTimeout)
+	loadEnvDuration("HTTP_WRITE_TIMEOUT", &h.WriteTimeout)
+	loadEnvDuration("HTTP_IDLE_TIMEOUT", &h.IdleTimeout)
+	loadEnvDuration("HTTP_REQUEST_TIMEOUT", &h.RequestTimeout)
+	loadEnvUint("HTTP_MAX_HEADER_BYTES", &h.MaxHeaderBytes)
+	loadEnvUint("HTTP_MAX_BODY_BYTES", &h.MaxBodyBytes)
+
+	// Group overrides have the form messaging=0,users=30s
+	loadEnvGroups("HTTP_GROUP_REQUEST_TIMEOUTS", func(name, value string) error {
+		d, err := time.ParseDuration(value)
+		if err == nil {
+			g := h.Groups[name]
+			g.RequestTimeout = &d
+			h.Groups[name] = g
+		}
+		return err
+	})
+	loadEnvGroups("HTTP_GROUP_WRITE_TIMEOUTS", func(name, value string) error {
+		d, err := time.ParseDuration(value)
+		if err == nil {
+			g := h.Groups[name]
+			g.WriteTimeout = &d
+			h.Groups[name] = g
+		}
+		return err
+	})
+	loadEnvGroups("HTTP_GROUP_MAX_BODY_BYTES", func(name, value string) error {
+		n, err := strconv.ParseUint(value, 10, 0)
+		if err == nil {
+			size := uint(n)
+			g := h.Groups[name]
+			g.MaxBodyBytes = &size
+			h.Groups[name] = g
+		}
+		return err
+	})
+
+	h.CORS.loadFromEnv()
+
+	if h.WriteTimeout > 0 && (h.RequestTimeout == 0 || h.RequestTimeout >= h.WriteTimeout) {
+		log.Warn().Dur("request_timeout", h.RequestTimeout).Dur("write_timeout", h.WriteTimeout).Msg("HTTP_REQUEST_TIMEOUT should be shorter than HTTP_WRITE_TIMEOUT")
+	}
+}
+
+func defaultHttpConfig() httpConfig {
+	return httpConfig{
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      15 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		RequestTimeout:    10 * time.Second,
+		MaxHeaderBytes:    1 << 20, // 1MB
+		MaxBodyBytes:      1 << 20, // 1MB
+		Groups:            map[string]routeGroupConfig{},
+		CORS:              defaultCorsConfig(),
+	}
+}
+
+/* CORS Configuration */
+
+type corsConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           uint     `json:"max_age"`
+}
+
+func (c *corsConfig) loadFromEnv() {
+	loadEnvStringSlice("CORS_ALLOWED_ORIGINS", &c.AllowedOrigins)
+	loadEnvStringSlice("CORS_ALLOWED_METHODS", &c.AllowedMethods)
+	loadEnvStringSlice("CORS_ALLOWED_HEADERS", &c.AllowedHeaders)
+	loadEnvStringSlice("CORS_EXPOSED_HEADERS", &c.ExposedHeaders)
+	loadEnvBool("CORS_ALLOW_CREDENTIALS", &c.AllowCredentials)
+	loadEnvUint("CORS_MAX_AGE", &c.MaxAge)
+
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		log.Warn().Msg("CORS_ALLOW_CREDENTIALS has no effect with the * origin, list the allowed origins instead")
+	}
+}
+
+func defaultCorsConfig() corsConfig {
+	// for more ideas, see: https://developer.github.com/v3/#cross-origin-resource-sharing
+	return corsConfig{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-KEY", "X-ACCESS-TIME", "X-REQUEST-SIGNATURE", "X-API-USER", "X-REQUEST-IDENTITY", "If-Match", "If-None-Match", "Idempotency-Key"},
+		ExposedHeaders:   []string{"Link", "ETag", "Idempotent-Replayed", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+		AllowCredentials: false,
+		MaxAge:           300, // Maximum value not ignored by any of major browsers
+	}
+}
+
+/* Admin Configuration */
+
+type adminConfig struct {
+	Enabled bool   `json:"enabled"`
+	Host    string `json:"host"`
+	Port    uint   `json:"port"`
+	// Token is required as a bearer token by the admin endpoints when set
+	Token string `json:"token"`
+}
+
+func (a adminConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", a.Host, a.Port)
+}
+
+func (a *adminConfig) loadFromEnv() {
+	loadEnvBool("ADMIN_ENABLED", &a.Enabled)
+	loadEnvString("ADMIN_HOST", &a.Host)
+	loadEnvUint("ADMIN_PORT", &a.Port)
+	loadEnvString("ADMIN_TOKEN", &a.Token)
+
+	if a.Enabled && a.Token == "" {
+		if ip := net.ParseIP(a.Host); a.Host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			log.Warn().Str("host", a.Host).Msg("Admin listener is reachable from other hosts without ADMIN_TOKEN")
+		}
+	}
+}
+
+func defaultAdminConfig() adminConfig {
+	// Bound to localhost so profiles and configuration are not exposed
+	return adminConfig{
+		Enabled: true,
+		Host:    "127.0.0.1",
+		Port:    6060,
+	}
 }
 
 type hostConfig struct {
@@ -113,30 +407,61 @@ type natsConfig struct {
 	URL      string
 	Username string
 	Password string
+	// RequestSubjects are the subjects POST /v1/messaging/request may send
+	// requests on, NATS wildcards allowed
+	RequestSubjects []string
+	// RequestTimeout is the default and maximum wait for a reply
+	RequestTimeout time.Duration
+	// Service serves the operations of the modules as a NATS micro service
+	// called ServiceName, on subjects starting with ServicePrefix
+	Service       bool
+	ServiceName   string
+	ServicePrefix string
 }
 
 func (c *natsConfig) loadFromEnv() {
 	c.URL = getEnv("NATS_URL", "nats://localhost:4222")
 	c.Username = getEnv("NATS_USERNAME", "")
 	c.Password = getEnv("NATS_PASSWORD", "")
+	loadEnvStringSlice("NATS_REQUEST_SUBJECTS", &c.RequestSubjects)
+	loadEnvDuration("NATS_REQUEST_TIMEOUT", &c.RequestTimeout)
+	loadEnvBool("NATS_SERVICE", &c.Service)
+	loadEnvString("NATS_SERVICE_NAME", &c.ServiceName)
+	loadEnvString("NATS_SERVICE_PREFIX", &c.ServicePrefix)
+}
+
+// requestPolicy returns the policy of the request-reply bridge
+func (c natsConfig) requestPolicy() messaging.RequestPolicy {
+	return messaging.RequestPolicy{
+		Subjects: c.RequestSubjects,
+		Timeout:  c.RequestTimeout,
+	}
 }
 
 func defaultNatsConfig() natsConfig {
 	return natsConfig{
-		URL:      "nats://localhost:4222",
-		Username: "",
-		Password: "",
+		URL:             "nats://localhost:4222",
+		Username:        "",
+		Password:        "",
+		RequestSubjects: []string{},
+		RequestTimeout:  5 * time.Second,
+		Service:         false,
+		ServiceName:     "go-http-service-template",
+		ServicePrefix:   "api",
 	}
 }
 
 type securityConfig struct {
 	BackendApiKey string
 	ServerSalt    string
+	// CursorSecret signs pagination cursors, falling back to ServerSalt
+	CursorSecret string
 }
 
 func (s *securityConfig) loadFromEnv() {
 	s.BackendApiKey = getEnv("BACKEND_API_KEY", "")
 	s.ServerSalt = getEnv("SERVER_SALT", "")
+	s.CursorSecret = getEnv("CURSOR_SECRET", s.ServerSalt)
 }
 
 func defaultSecurityConfig() securityConfig {
@@ -146,46 +471,274 @@ func defaultSecurityConfig() securityConfig {
 	}
 }
 
+/* OpenAPI Validation Configuration */
+type openAPIConfig struct {
+	// ValidateRequests rejects requests that do not match the spec in docs/
+	ValidateRequests bool
+	// ValidateResponses fails responses that do not match the spec, meant
+	// for development as responses are buffered
+	ValidateResponses bool
+}
+
+func (o *openAPIConfig) loadFromEnv() {
+	loadEnvBool("OPENAPI_VALIDATE_REQUESTS", &o.ValidateRequests)
+	loadEnvBool("OPENAPI_VALIDATE_RESPONSES", &o.ValidateResponses)
+}
+
+// enabled reports whether the OpenAPI middleware is needed
+func (o openAPIConfig) enabled() bool {
+	return o.ValidateRequests || o.ValidateResponses
+}
+
+func defaultOpenAPIConfig() openAPIConfig {
+	return openAPIConfig{
+		ValidateRequests:  false,
+		ValidateResponses: false,
+	}
+}
+
+/* Rate Limit Configuration */
+type rateLimitConfig struct {
+	// Store is "memory", or "nats" to share limits across instances
+	Store string
+	// Default applies to route groups without their own limit, a zero limit
+	// disables rate limiting
+	Default ratelimit.Limit
+	// Groups overrides the limit of route groups by name, such as users
+	Groups map[string]ratelimit.Limit
+}
+
+func (c *rateLimitConfig) loadFromEnv() {
+	loadEnvString("RATE_LIMIT_STORE", &c.Store)
+
+	if s, ok := os.LookupEnv("RATE_LIMIT_DEFAULT"); ok {
+		if limit, err := parseRateLimit(s); err == nil {
+			c.Default = limit
+		} else {
+			log.Warn().Err(err).Msg("Ignoring RATE_LIMIT_DEFAULT")
+		}
+	}
+
+	// RATE_LIMIT_GROUPS has the form users=100/1m,messaging=off
+	loadEnvGroups("RATE_LIMIT_GROUPS", func(name, value string) error {
+		limit, err := parseRateLimit(value)
+		if err == nil {
+			c.Groups[name] = limit
+		}
+		return err
+	})
+}
+
+// limitFor returns the limit of a route group
+func (c rateLimitConfig) limitFor(group string) ratelimit.Limit {
+	if limit, ok := c.Groups[group]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+// parseRateLimit parses a limit such as 100/1m, or off to disable it
+func parseRateLimit(s string) (ratelimit.Limit, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "off") {
+		return ratelimit.Limit{}, nil
+	}
+	return ratelimit.ParseLimit(s)
+}
+
+func defaultRateLimitConfig() rateLimitConfig {
+	return rateLimitConfig{
+		Store:   "memory",
+		Default: ratelimit.Limit{Requests: 300, Window: time.Minute},
+		Groups:  map[string]ratelimit.Limit{},
+	}
+}
+
 // AppConfig represents application-specific configuration
 type appConfig struct {
 	Environment string // "production", "development", etc.
+	// LogLevel is the initial zerolog level, it can be changed at runtime
+	// from the admin listener
+	LogLevel string
+	// ShutdownTimeout bounds the graceful shutdown of all components
+	ShutdownTimeout time.Duration
+	// Profiles lists the features enabled by default in each environment,
+	// as patterns such as messaging.*
+	Profiles map[string][]string
+	// Features explicitly enables or disables features in any environment
+	Features map[string]bool
+	// DisabledRouteStatus answers the routes of disabled features, 404 or 403
+	DisabledRouteStatus uint
 }
 
 func (a *appConfig) loadFromEnv() {
-	a.Environment = getEnv("APP_ENV", "development")
+	a.Environment = strings.ToLower(getEnv("APP_ENV", "development"))
+	loadEnvString("LOG_LEVEL", &a.LogLevel)
+	loadEnvDuration("SHUTDOWN_TIMEOUT", &a.ShutdownTimeout)
+
+	// APP_PROFILE_STAGING replaces the profile of the staging environment
+	var profile []string
+	loadEnvStringSlice("APP_PROFILE_"+strings.ToUpper(a.Environment), &profile)
+	if profile != nil {
+		a.Profiles[a.Environment] = profile
+	}
+
+	// APP_FEATURES has the form messaging.publish=true,messaging.subscribe=false
+	loadEnvGroups("APP_FEATURES", func(name, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		if err == nil {
+			a.Features[name] = enabled
+		}
+		return err
+	})
+
+	loadEnvUint("APP_DISABLED_ROUTE_STATUS", &a.DisabledRouteStatus)
+	if a.DisabledRouteStatus != http.StatusNotFound && a.DisabledRouteStatus != http.StatusForbidden {
+		log.Warn().Uint("status", a.DisabledRouteStatus).Msg("APP_DISABLED_ROUTE_STATUS must be 404 or 403, using 404")
+		a.DisabledRouteStatus = http.StatusNotFound
+	}
+}
+
+// gates returns the feature gates of the environment
+func (a appConfig) gates() *module.Gates {
+	return module.NewGates(module.GatesOptions{
+		Environment:    a.Environment,
+		Profiles:       a.Profiles,
+		Flags:          a.Features,
+		DisabledStatus: int(a.DisabledRouteStatus),
+	})
 }
 
 func defaultAppConfig() appConfig {
 	return appConfig{
-		Environment: "development",
+		Environment:     "development",
+		LogLevel:        "debug",
+		ShutdownTimeout: time.Minute,
+		// Development tools such as publishing any message stay out of
+		// other environments unless enabled explicitly
+		Profiles: map[string][]string{
+			"development": {"*"},
+			"staging":     {},
+			"production":  {},
+		},
+		Features:            map[string]bool{},
+		DisabledRouteStatus: http.StatusNotFound,
+	}
+}
+
+// modulesConfig selects the feature modules to build
+type modulesConfig struct {
+	// Disabled lists the names of modules that are not built nor mounted
+	Disabled []string
+}
+
+func (m *modulesConfig) loadFromEnv() {
+	loadEnvStringSlice("MODULES_DISABLED", &m.Disabled)
+}
+
+// enabled reports whether the module called name is enabled
+func (m modulesConfig) enabled(name string) bool {
+	return !slices.Contains(m.Disabled, name)
+}
+
+func defaultModulesConfig() modulesConfig {
+	return modulesConfig{
+		Disabled: []string{},
 	}
 }
 
 type config struct {
-	Host     hostConfig
-	Listen   listenConfig
-	PgSql    pgSqlConfig
-	Security securityConfig
-	Nats     natsConfig
-	App      appConfig
+	Host      hostConfig
+	Listen    listenConfig
+	Admin     adminConfig
+	Http      httpConfig
+	PgSql     pgSqlConfig
+	Security  securityConfig
+	Nats      natsConfig
+	App       appConfig
+	Modules   modulesConfig
+	OpenAPI   openAPIConfig
+	RateLimit rateLimitConfig
 }
 
 func (c *config) loadFromEnv() {
 	c.Host.loadFromEnv()
 	c.Listen.loadFromEnv()
+	c.Admin.loadFromEnv()
+	c.Http.loadFromEnv()
 	c.PgSql.loadFromEnv()
 	c.Security.loadFromEnv()
 	c.Nats.loadFromEnv()
 	c.App.loadFromEnv()
+	c.Modules.loadFromEnv()
+	c.OpenAPI.loadFromEnv()
+	c.RateLimit.loadFromEnv()
 }
 
 func defaultConfig() config {
 	return config{
-		Host:     defaultHostConfig(),
-		Listen:   defaultListenConfig(),
-		PgSql:    defaultPgSql(),
-		Security: defaultSecurityConfig(),
-		Nats:     defaultNatsConfig(),
-		App:      defaultAppConfig(),
+		Host:      defaultHostConfig(),
+		Listen:    defaultListenConfig(),
+		Admin:     defaultAdminConfig(),
+		Http:      defaultHttpConfig(),
+		PgSql:     defaultPgSql(),
+		Security:  defaultSecurityConfig(),
+		Nats:      defaultNatsConfig(),
+		App:       defaultAppConfig(),
+		Modules:   defaultModulesConfig(),
+		OpenAPI:   defaultOpenAPIConfig(),
+		RateLimit: defaultRateLimitConfig(),
+	}
+}
+
+// redactedValue replaces secrets in the redacted configuration
+const redactedValue = "REDACTED"
+
+// redacted returns a copy of c that is safe to expose, with secrets replaced
+func (c config) redacted() config {
+	redact := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return redactedValue
+	}
+
+	c.PgSql.Password = redact(c.PgSql.Password)
+	replicas := make([]string, len(c.PgSql.ReplicaURLs))
+	for i, connStr := range c.PgSql.ReplicaURLs {
+		replicas[i] = redactURL(connStr)
+	}
+	c.PgSql.ReplicaURLs = replicas
+
+	c.Security.BackendApiKey = redact(c.Security.BackendApiKey)
+	c.Security.ServerSalt = redact(c.Security.ServerSalt)
+	c.Security.CursorSecret = redact(c.Security.CursorSecret)
+	c.Nats.Password = redact(c.Nats.Password)
+	// NATS_URL may list several servers separated by commas, a user without
+	// password is a token
+	servers := strings.Split(c.Nats.URL, ",")
+	for i, server := range servers {
+		server = strings.TrimSpace(server)
+		if u, err := url.Parse(server); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); !ok {
+				u.User = url.User(redactedValue)
+				server = u.String()
+			}
+		}
+		servers[i] = redactURL(server)
+	}
+	c.Nats.URL = strings.Join(servers, ",")
+	c.Admin.Token = redact(c.Admin.Token)
+	return c
+}
+
+// redactURL hides the password of a URL but keeps its host, other connection
+// strings such as key/value pairs are hidden entirely
+func redactURL(connStr string) string {
+	if connStr == "" {
+		return ""
+	}
+	if u, err := url.Parse(connStr); err == nil && u.Scheme != "" {
+		return u.Redacted()
 	}
+	return redactedValue
 }
@@ -24,19 +24,39 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/": {
-            "get": {
-                "description": "Simple health check endpoint to verify the service is running",
+        "/messaging/publish": {
+            "post": {
+                "description": "Publish a message to the specified subject",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "system"
+                    "messaging"
+                ],
+                "summary": "Publish a message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key making retries of the request safe",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Message publishing request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/messaging.MessageRequest"
+                        }
+                    }
                 ],
-                "summary": "Health check",
                 "responses": {
-                    "200": {
-                        "description": "Service is healthy",
+                    "202": {
+                        "description": "Message published successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -45,20 +65,89 @@ const docTemplate = `{
                                 {
                                     "type": "object",
                                     "properties": {
-                                        "message": {
-                                            "type": "string"
+                                        "data": {
+                                            "$ref": "#/definitions/messaging.MessageResponse"
                                         }
                                     }
                                 }
                             ]
                         }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/problem.Problem"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "errors": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/problem.FieldError"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Feature disabled in this environment",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "A request with the same Idempotency-Key is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported content type",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key reused for a different request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Messaging service is not available",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "504": {
+                        "description": "Timeout waiting for message confirmation",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     }
                 }
             }
         },
-        "/messaging/publish": {
+        "/messaging/request": {
             "post": {
-                "description": "Publish a message to the specified subject",
+                "description": "Send a request on an allow-listed subject (NATS_REQUEST_SUBJECTS) and wait for the reply, at most NATS_REQUEST_TIMEOUT.\nThe headers are sent as NATS headers along with the request ID, reserved Nats-* headers are rejected. Error replies with the Nats-Service-Error-Code header are returned as problems with that status.",
                 "consumes": [
                     "application/json"
                 ],
@@ -68,21 +157,21 @@ const docTemplate = `{
                 "tags": [
                     "messaging"
                 ],
-                "summary": "Publish a message",
+                "summary": "Send a NATS request",
                 "parameters": [
                     {
-                        "description": "Message publishing request",
+                        "description": "NATS request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/module.MessageRequest"
+                            "$ref": "#/definitions/messaging.NatsRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Message published successfully",
+                    "200": {
+                        "description": "Reply received",
                         "schema": {
                             "allOf": [
                                 {
@@ -92,7 +181,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/module.MessageResponse"
+                                            "$ref": "#/definitions/messaging.NatsReply"
                                         }
                                     }
                                 }
@@ -100,39 +189,66 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid request body, subject or headers",
                         "schema": {
                             "allOf": [
                                 {
-                                    "$ref": "#/definitions/utils.Response"
+                                    "$ref": "#/definitions/problem.Problem"
                                 },
                                 {
                                     "type": "object",
                                     "properties": {
-                                        "error": {
-                                            "type": "string"
+                                        "errors": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/problem.FieldError"
+                                            }
                                         }
                                     }
                                 }
                             ]
                         }
                     },
+                    "403": {
+                        "description": "Subject is not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported content type",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "allOf": [
-                                {
-                                    "$ref": "#/definitions/utils.Response"
-                                },
-                                {
-                                    "type": "object",
-                                    "properties": {
-                                        "error": {
-                                            "type": "string"
-                                        }
-                                    }
-                                }
-                            ]
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Reply is not valid JSON",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Messaging service is not available",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "504": {
+                        "description": "No responders or timeout waiting for the reply",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     }
                 }
@@ -178,6 +294,32 @@ const docTemplate = `{
                     },
                     "400": {
                         "description": "Invalid subject",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Feature disabled in this environment",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/module": {
+            "get": {
+                "description": "Simple health check endpoint to verify the service is running",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "Service is healthy",
                         "schema": {
                             "allOf": [
                                 {
@@ -186,7 +328,7 @@ const docTemplate = `{
                                 {
                                     "type": "object",
                                     "properties": {
-                                        "error": {
+                                        "message": {
                                             "type": "string"
                                         }
                                     }
@@ -198,26 +340,129 @@ const docTemplate = `{
             }
         },
         "/users": {
+            "get": {
+                "description": "List users with keyset pagination. Follow the cursors in the response meta or the Link header to get the next and previous pages.\nSortable and filterable fields are id, email, name, created_at and updated_at. Filters have the form field:op:value with op one of eq, ne, lt, lte, gt, gte, like and in (values separated by |).",
+                "produces": [
+                    "application/json",
+                    "application/msgpack",
+                    "text/csv"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List users",
+                "parameters": [
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "-created_at",
+                        "description": "Comma separated fields, prefix with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Filters such as email:like:example.com",
+                        "name": "filter",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Page of users",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/user.UserListResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "Links to the next and previous pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid pagination, sort or filter parameter",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "No supported media type is acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Database is not available",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
             "post": {
                 "description": "Create a new user with the provided information",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/msgpack"
                 ],
                 "tags": [
                     "users"
                 ],
                 "summary": "Create a new user",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key making retries of the request safe",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
                     {
                         "description": "User creation request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/module.UserCreationRequest"
+                            "$ref": "#/definitions/user.UserCreationRequest"
                         }
                     }
                 ],
@@ -233,7 +478,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/module.UserResponse"
+                                            "$ref": "#/definitions/user.UserResponse"
                                         }
                                     }
                                 }
@@ -242,6 +487,94 @@ const docTemplate = `{
                     },
                     "400": {
                         "description": "Invalid request body",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/problem.Problem"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "errors": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/problem.FieldError"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "409": {
+                        "description": "Email already registered, or a request with the same Idempotency-Key is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported content type",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key reused for a different request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Database is not available",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}": {
+            "get": {
+                "description": "Get a user by ID",
+                "produces": [
+                    "application/json",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached version, returns 304 when it is current",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User found",
                         "schema": {
                             "allOf": [
                                 {
@@ -250,16 +583,148 @@ const docTemplate = `{
                                 {
                                     "type": "object",
                                     "properties": {
-                                        "error": {
-                                            "type": "string"
+                                        "data": {
+                                            "$ref": "#/definitions/user.UserResponse"
                                         }
                                     }
                                 }
                             ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the user"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Cached version is current"
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Database is not available",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a user by ID. Send the ETag of the user in If-Match to make sure it was not modified since it was retrieved.",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Delete a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being deleted",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "User deleted"
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "User was modified since it was retrieved",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Database is not available",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update the given fields of a user. Send the ETag of the user in If-Match to make sure it was not modified since it was retrieved.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "uuid",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being updated",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "User update request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.UserUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User updated",
                         "schema": {
                             "allOf": [
                                 {
@@ -268,23 +733,99 @@ const docTemplate = `{
                                 {
                                     "type": "object",
                                     "properties": {
-                                        "error": {
-                                            "type": "string"
+                                        "data": {
+                                            "$ref": "#/definitions/user.UserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New version of the user"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/problem.Problem"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "errors": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/problem.FieldError"
+                                            }
                                         }
                                     }
                                 }
                             ]
                         }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Email already registered",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "User was modified since it was retrieved",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported content type",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Database is not available",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
-        "module.MessageRequest": {
-            "type": "object"
+        "messaging.MessageRequest": {
+            "type": "object",
+            "required": [
+                "data",
+                "subject"
+            ],
+            "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "notifications.user.created"
+                }
+            }
         },
-        "module.MessageResponse": {
+        "messaging.MessageResponse": {
             "type": "object",
             "properties": {
                 "sequence": {
@@ -301,7 +842,126 @@ const docTemplate = `{
                 }
             }
         },
-        "module.UserCreationRequest": {
+        "messaging.NatsReply": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "headers": {
+                    "description": "Headers are the NATS headers of the reply, multiple values joined by\ncommas",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "users.lookup"
+                }
+            }
+        },
+        "messaging.NatsRequest": {
+            "type": "object",
+            "required": [
+                "subject"
+            ],
+            "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "headers": {
+                    "description": "Headers are sent as NATS message headers. Reserved Nats-* headers are\nrejected.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "users.lookup"
+                },
+                "timeout_ms": {
+                    "description": "TimeoutMS shortens the wait for the reply, which is capped by the\nconfigured timeout",
+                    "type": "integer",
+                    "minimum": 1,
+                    "example": 2000
+                }
+            }
+        },
+        "models.CursorMetaResponse": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "prev_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "problem.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "email"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "email must be a valid email address"
+                },
+                "param": {
+                    "type": "string",
+                    "example": ""
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "email"
+                }
+            }
+        },
+        "problem.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "User not found"
+                },
+                "errors": {
+                    "description": "Errors lists the invalid fields of a validation problem",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/problem.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/v1/users/550e8400-e29b-41d4-a716-446655440000"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "host/abcdef-000001"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/not-found"
+                }
+            }
+        },
+        "user.UserCreationRequest": {
             "type": "object",
             "required": [
                 "email",
@@ -329,7 +989,21 @@ const docTemplate = `{
                 }
             }
         },
-        "module.UserResponse": {
+        "user.UserListResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user.UserResponse"
+                    }
+                },
+                "meta": {
+                    "$ref": "#/definitions/models.CursorMetaResponse"
+                }
+            }
+        },
+        "user.UserResponse": {
             "type": "object",
             "properties": {
                 "created_at": {
@@ -358,13 +1032,29 @@ const docTemplate = `{
                 }
             }
         },
+        "user.UserUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "first_name": {
+                    "type": "string",
+                    "minLength": 1,
+                    "example": "John"
+                },
+                "last_name": {
+                    "type": "string",
+                    "minLength": 1,
+                    "example": "Doe"
+                }
+            }
+        },
         "utils.Response": {
             "type": "object",
             "properties": {
                 "data": {},
-                "error": {
-                    "type": "string"
-                },
                 "message": {
                     "type": "string"
                 },
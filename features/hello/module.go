@@ -0,0 +1,15 @@
+package hello
+
+import (
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+)
+
+func init() {
+	module.Register(module.Definition{
+		Name: "hello",
+		Path: "/module",
+		New: func(c *module.Container) (module.Module, error) {
+			return NewHello(c.DB, c.Nats), nil
+		},
+	})
+}
@@ -39,7 +39,7 @@ func (m *Hello) Router() http.Handler {
 // @Tags system
 // @Produce json
 // @Success 200 {object} utils.Response{message=string} "Service is healthy"
-// @Router / [get]
+// @Router /module [get]
 func (m *Hello) testRoute(w http.ResponseWriter, r *http.Request) {
 	utils.WriteMessage(w, 200, "Hello with dependency injection")
 }
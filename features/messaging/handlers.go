@@ -3,13 +3,19 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
 	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/rs/zerolog/log"
 )
@@ -17,6 +23,11 @@ import (
 // Messaging handles messaging-related requests
 type Messaging struct {
 	NatsClient *messaging.NatsClient
+	// Requests restricts the subjects of RequestMessage, which allows none by
+	// default
+	Requests messaging.RequestPolicy
+	// Gates enable the routes per environment, nil enables all of them
+	Gates *module.Gates
 }
 
 // NewMessaging creates a new messaging handler
@@ -29,7 +40,7 @@ func NewMessaging(natsClient *messaging.NatsClient) *Messaging {
 // MessageRequest represents a request to publish a message
 type MessageRequest struct {
 	Subject string          `json:"subject" validate:"required" example:"notifications.user.created"`
-	Data    json.RawMessage `json:"data" validate:"required" example:"{\"message\":\"Hello world\"}"`
+	Data    json.RawMessage `json:"data" validate:"required" swaggertype:"object"`
 }
 
 // MessageResponse represents the response from publishing a message
@@ -45,35 +56,30 @@ type MessageResponse struct {
 // @Tags messaging
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Key making retries of the request safe"
 // @Param request body MessageRequest true "Message publishing request"
 // @Success 202 {object} utils.Response{data=MessageResponse} "Message published successfully"
-// @Failure 400 {object} utils.Response{error=string} "Invalid request body"
-// @Failure 500 {object} utils.Response{error=string} "Internal server error"
+// @Failure 400 {object} problem.Problem{errors=[]problem.FieldError} "Invalid request body"
+// @Failure 404 {object} problem.Problem "Feature disabled in this environment"
+// @Failure 409 {object} problem.Problem "A request with the same Idempotency-Key is in progress"
+// @Failure 413 {object} problem.Problem "Request body too large"
+// @Failure 415 {object} problem.Problem "Unsupported content type"
+// @Failure 422 {object} problem.Problem "Idempotency-Key reused for a different request"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 503 {object} problem.Problem "Messaging service is not available"
+// @Failure 504 {object} problem.Problem "Timeout waiting for message confirmation"
 // @Router /messaging/publish [post]
 func (h *Messaging) PublishMessage(w http.ResponseWriter, r *http.Request) {
-	// Parse request body
-	var req MessageRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Error().Err(err).Msg("Failed to decode request body")
-		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	// Validate request
-	if req.Subject == "" {
-		utils.WriteError(w, http.StatusBadRequest, "Subject is required")
-		return
-	}
-
-	if len(req.Data) == 0 {
-		utils.WriteError(w, http.StatusBadRequest, "Data is required")
+	// Parse and validate request body
+	req, err := utils.DecodeAndValidate[MessageRequest](w, r)
+	if err != nil {
+		problem.WriteError(w, r, err)
 		return
 	}
 
 	// Check if NATS client is available
 	if h.NatsClient == nil {
-		log.Error().Msg("NATS client is not available")
-		utils.WriteError(w, http.StatusInternalServerError, "Messaging service is not available")
+		problem.WriteError(w, r, problem.Unavailable("Messaging service is not available"))
 		return
 	}
 
@@ -84,40 +90,140 @@ func (h *Messaging) PublishMessage(w http.ResponseWriter, r *http.Request) {
 	// Check if we need to create a stream for this subject
 	// This would normally be done during service setup, but for demo we'll do it here
 	streamName := "MESSAGES"
-	_, err := ensureStream(ctx, h.NatsClient, streamName, []string{req.Subject, fmt.Sprintf("%s.*", req.Subject)})
+	_, err = ensureStream(ctx, h.NatsClient, streamName, []string{req.Subject, fmt.Sprintf("%s.*", req.Subject)})
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to ensure stream exists")
-		utils.WriteError(w, http.StatusInternalServerError, "Failed to ensure messaging infrastructure")
+		problem.WriteError(w, r, problem.Internal("Failed to ensure messaging infrastructure", err))
 		return
 	}
 
 	// Publish message to JetStream
 	ack, err := h.NatsClient.PublishAsync(req.Subject, req.Data)
 	if err != nil {
-		log.Error().Err(err).Str("subject", req.Subject).Msg("Failed to publish message")
-		utils.WriteError(w, http.StatusInternalServerError, "Failed to publish message")
+		problem.WriteError(w, r, problem.Internal("Failed to publish message", err))
 		return
 	}
 
 	// Wait for acknowledgement with a timeout
 	select {
-	case <-ack.Ok():
+	case pubAck := <-ack.Ok():
 		// Message was successfully stored
 		response := MessageResponse{
-			Stream:   streamName,
-			Sequence: 0, // We can't get the sequence number easily from the ack, so set to 0
+			Stream:   pubAck.Stream,
+			Sequence: pubAck.Sequence,
 			Subject:  req.Subject,
 		}
 		utils.WriteJSON(w, http.StatusAccepted, response)
 	case err := <-ack.Err():
 		// There was an error
-		log.Error().Err(err).Str("subject", req.Subject).Msg("Failed to get acknowledgement for message")
-		utils.WriteError(w, http.StatusInternalServerError, "Failed to confirm message delivery")
+		problem.WriteError(w, r, problem.Internal("Failed to confirm message delivery", err))
 	case <-ctx.Done():
 		// Timeout
 		log.Warn().Str("subject", req.Subject).Msg("Timeout waiting for message acknowledgement")
-		utils.WriteError(w, http.StatusRequestTimeout, "Timeout waiting for message confirmation")
+		problem.WriteError(w, r, problem.Timeout("Timeout waiting for message confirmation"))
+	}
+}
+
+// NatsRequest represents a request to send over NATS
+type NatsRequest struct {
+	Subject string          `json:"subject" validate:"required" example:"users.lookup"`
+	Data    json.RawMessage `json:"data" swaggertype:"object"`
+	// Headers are sent as NATS message headers. Reserved Nats-* headers are
+	// rejected.
+	Headers map[string]string `json:"headers,omitempty"`
+	// TimeoutMS shortens the wait for the reply, which is capped by the
+	// configured timeout
+	TimeoutMS int `json:"timeout_ms,omitempty" validate:"omitempty,min=1" example:"2000"`
+}
+
+// NatsReply represents the reply to a NATS request
+type NatsReply struct {
+	Subject string          `json:"subject" example:"users.lookup"`
+	Data    json.RawMessage `json:"data,omitempty" swaggertype:"object"`
+	// Headers are the NATS headers of the reply, multiple values joined by
+	// commas
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// RequestMessage sends a request on a NATS subject and returns the reply
+// @Summary Send a NATS request
+// @Description Send a request on an allow-listed subject (NATS_REQUEST_SUBJECTS) and wait for the reply, at most NATS_REQUEST_TIMEOUT.
+// @Description The headers are sent as NATS headers along with the request ID, reserved Nats-* headers are rejected. Error replies with the Nats-Service-Error-Code header are returned as problems with that status.
+// @Tags messaging
+// @Accept json
+// @Produce json
+// @Param request body NatsRequest true "NATS request"
+// @Success 200 {object} utils.Response{data=NatsReply} "Reply received"
+// @Failure 400 {object} problem.Problem{errors=[]problem.FieldError} "Invalid request body, subject or headers"
+// @Failure 403 {object} problem.Problem "Subject is not allowed"
+// @Failure 413 {object} problem.Problem "Request body too large"
+// @Failure 415 {object} problem.Problem "Unsupported content type"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 502 {object} problem.Problem "Reply is not valid JSON"
+// @Failure 503 {object} problem.Problem "Messaging service is not available"
+// @Failure 504 {object} problem.Problem "No responders or timeout waiting for the reply"
+// @Router /messaging/request [post]
+func (h *Messaging) RequestMessage(ctx context.Context, req NatsRequest) (NatsReply, error) {
+	if !messaging.LiteralSubject(req.Subject) {
+		return NatsReply{}, problem.BadRequest("Subject must not contain wildcards, whitespace or empty tokens")
+	}
+	if !h.Requests.Allowed(req.Subject) {
+		return NatsReply{}, problem.Forbidden(fmt.Sprintf("Requests on subject %s are not allowed", req.Subject))
 	}
+	for key := range req.Headers {
+		if reservedHeader(key) {
+			return NatsReply{}, problem.BadRequest(fmt.Sprintf("Header %s is reserved for NATS", key))
+		}
+	}
+	if h.NatsClient == nil || h.NatsClient.GetConn() == nil || !h.NatsClient.GetConn().IsConnected() {
+		return NatsReply{}, problem.Unavailable("Messaging service is not available")
+	}
+
+	timeout := h.Requests.ReplyTimeout(time.Duration(req.TimeoutMS) * time.Millisecond)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	msg := nats.NewMsg(req.Subject)
+	msg.Data = req.Data
+	for key, value := range req.Headers {
+		msg.Header.Set(key, value)
+	}
+	if id := middleware.GetReqID(ctx); id != "" {
+		msg.Header.Set(middleware.RequestIDHeader, id)
+	}
+
+	reply, err := h.NatsClient.RequestMsg(ctx, msg)
+	switch {
+	case errors.Is(err, nats.ErrNoResponders):
+		return NatsReply{}, problem.Timeout(fmt.Sprintf("No responders for subject %s", req.Subject)).Wrap(err)
+	case errors.Is(err, nats.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return NatsReply{}, problem.Timeout(fmt.Sprintf("Timeout waiting for a reply on subject %s", req.Subject)).Wrap(err)
+	case errors.Is(err, context.Canceled):
+		return NatsReply{}, problem.ClientClosed("The request was canceled").Wrap(err)
+	case err != nil:
+		return NatsReply{}, problem.Internal("Failed to send request", err)
+	}
+
+	if perr := messaging.ReplyError(reply); perr != nil {
+		return NatsReply{}, perr
+	}
+	if len(reply.Data) > 0 && !json.Valid(reply.Data) {
+		return NatsReply{}, problem.BadGateway(fmt.Sprintf("Reply on subject %s is not valid JSON", req.Subject))
+	}
+
+	resp := NatsReply{Subject: req.Subject, Data: reply.Data}
+	if len(reply.Header) > 0 {
+		resp.Headers = make(map[string]string, len(reply.Header))
+		for key, values := range reply.Header {
+			resp.Headers[key] = strings.Join(values, ", ")
+		}
+	}
+	return resp, nil
+}
+
+// reservedHeader reports whether key is a header NATS and JetStream interpret,
+// such as Nats-Msg-Id or Nats-Expected-Stream, which callers may not set
+func reservedHeader(key string) bool {
+	return len(key) >= len("Nats-") && strings.EqualFold(key[:len("Nats-")], "Nats-")
 }
 
 // SubscribeWebSocket subscribes to messages on a subject using WebSocket
@@ -127,13 +233,14 @@ func (h *Messaging) PublishMessage(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param subject path string true "Subject to subscribe to" example:"notifications.user.created"
 // @Success 200 {object} utils.Response{message=string} "Subscription information"
-// @Failure 400 {object} utils.Response{error=string} "Invalid subject"
+// @Failure 400 {object} problem.Problem "Invalid subject"
+// @Failure 404 {object} problem.Problem "Feature disabled in this environment"
 // @Router /messaging/subscribe/{subject} [get]
 func (h *Messaging) SubscribeWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Get subject from URL
 	subject := chi.URLParam(r, "subject")
 	if subject == "" {
-		utils.WriteError(w, http.StatusBadRequest, "Subject is required")
+		problem.WriteError(w, r, problem.BadRequest("Subject is required"))
 		return
 	}
 
@@ -2,15 +2,21 @@ package messaging
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging/natstest"
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
 	"github.com/go-chi/chi/v5"
+	"github.com/nats-io/nats.go"
 )
 
 func TestPublishMessage(t *testing.T) {
@@ -49,8 +55,12 @@ func TestPublishMessage(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			// Create a client - we'll never actually use it for publishing in tests
+			// Error cases never reach the broker, so a disconnected client is enough.
+			// The valid request publishes to an embedded NATS server.
 			natsClient := &messaging.NatsClient{}
+			if !tc.expectError {
+				natsClient = natstest.NewClient(t)
+			}
 
 			// Create handler with the client
 			handler := NewMessaging(natsClient)
@@ -65,41 +75,40 @@ func TestPublishMessage(t *testing.T) {
 			// Create a response recorder
 			rr := httptest.NewRecorder()
 
-			// We only test request validation for most cases
-			// The actual publishing would require integration testing with a real NATS server
-			if tc.name == "Valid request" {
-				// For valid request, just validate the parsed request is correct
-				var req MessageRequest
-				if err := json.Unmarshal([]byte(tc.requestBody), &req); err != nil {
-					t.Fatalf("Test data is invalid: %v", err)
-				}
+			handler.PublishMessage(rr, req)
 
-				if req.Subject == "" {
-					t.Errorf("Test data has empty subject")
-				}
-				if len(req.Data) == 0 {
-					t.Errorf("Test data has empty data")
-				}
-			} else {
-				// For error cases, test the handler directly
-				handler.PublishMessage(rr, req)
+			// Check status code
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Handler returned wrong status code: got %v want %v",
+					rr.Code, tc.expectedStatus)
+			}
 
-				// Check status code
-				if rr.Code != tc.expectedStatus {
-					t.Errorf("Handler returned wrong status code: got %v want %v",
-						rr.Code, tc.expectedStatus)
-				}
+			var response map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
 
-				// Verify error in response
-				var response map[string]interface{}
-				if err := json.Unmarshal(rr.Body.Bytes(), &response); err == nil {
-					if tc.expectError {
-						_, hasError := response["error"]
-						if !hasError {
-							t.Errorf("Expected error in response, got none")
-						}
-					}
+			if tc.expectError {
+				// Verify the error is rendered as a problem
+				if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+					t.Errorf("Expected content type %s, got %s", problem.ContentType, ct)
+				}
+				if _, hasDetail := response["detail"]; !hasDetail {
+					t.Errorf("Expected problem detail in response, got none")
 				}
+				return
+			}
+
+			// Verify the message was stored in the stream
+			data, ok := response["data"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("Expected data to be an object, got %T", response["data"])
+			}
+			if data["stream"] != "MESSAGES" {
+				t.Errorf("Expected stream MESSAGES, got %v", data["stream"])
+			}
+			if data["subject"] != "test.subject" {
+				t.Errorf("Expected subject test.subject, got %v", data["subject"])
 			}
 		})
 	}
@@ -161,9 +170,9 @@ func TestSubscribeWebSocket(t *testing.T) {
 				var response map[string]interface{}
 				if err := json.Unmarshal(rr.Body.Bytes(), &response); err == nil {
 					if tc.expectError {
-						_, hasError := response["error"]
-						if !hasError {
-							t.Errorf("Expected error in response, got none")
+						_, hasDetail := response["detail"]
+						if !hasDetail {
+							t.Errorf("Expected problem detail in response, got none")
 						}
 					}
 				}
@@ -210,38 +219,112 @@ func TestSubscribeWebSocket(t *testing.T) {
 }
 
 func TestMessagingRouter(t *testing.T) {
-	// Create handler
-	handler := NewMessaging(&messaging.NatsClient{})
+	tests := []struct {
+		name           string
+		gates          *module.Gates
+		expectedStatus int
+	}{
+		{"development", module.NewGates(module.GatesOptions{Environment: "development", Profiles: map[string][]string{"development": {"*"}}}), http.StatusBadRequest},
+		{"production", module.NewGates(module.GatesOptions{Environment: "production"}), http.StatusNotFound},
+		{"production with flag", module.NewGates(module.GatesOptions{Environment: "production", Flags: map[string]bool{"messaging.publish": true}}), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewMessaging(&messaging.NatsClient{})
+			handler.Gates = tt.gates
+
+			// An empty body reaches the handler only when the route is enabled
+			req := httptest.NewRequest(http.MethodPost, "/publish", strings.NewReader(""))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+		})
+	}
+}
 
-	// Get the router
-	router := handler.Router()
+func TestRequestMessage(t *testing.T) {
+	natsClient := natstest.NewClient(t)
 
-	// Check if router exists
-	if router == nil {
-		t.Fatal("Router should not be nil")
+	type echoRequest struct {
+		Name string `json:"name" validate:"required"`
+	}
+	_, err := messaging.Respond(natsClient, "test.echo", "", func(ctx context.Context, req echoRequest) (echoRequest, error) {
+		messaging.ReplyHeader(ctx).Set("Tenant", messaging.RequestHeader(ctx).Get("Tenant"))
+		return req, nil
+	})
+	if err != nil {
+		t.Fatalf("Respond() error = %v", err)
+	}
+	if _, err := natsClient.Subscribe("test.text", func(msg *nats.Msg) { _ = msg.Respond([]byte("plain text")) }); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if _, err := natsClient.Subscribe("test.slow", func(msg *nats.Msg) {
+		time.Sleep(200 * time.Millisecond)
+		_ = msg.Respond([]byte("{}"))
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
 	}
 
-	// Save current environment
-	oldEnv, exists := os.LookupEnv("APP_ENV")
+	tests := []struct {
+		name           string
+		client         *messaging.NatsClient
+		requestBody    string
+		expectedStatus int
+	}{
+		{"reply", natsClient, `{"subject":"test.echo","data":{"name":"Jane"},"headers":{"Tenant":"acme"}}`, http.StatusOK},
+		{"error reply", natsClient, `{"subject":"test.echo","data":{}}`, http.StatusBadRequest},
+		{"subject not allowed", natsClient, `{"subject":"payments.refund","data":{}}`, http.StatusForbidden},
+		{"no responders", natsClient, `{"subject":"test.nobody","data":{}}`, http.StatusGatewayTimeout},
+		{"timeout", natsClient, `{"subject":"test.slow","data":{},"timeout_ms":50}`, http.StatusGatewayTimeout},
+		{"reply not json", natsClient, `{"subject":"test.text","data":{}}`, http.StatusBadGateway},
+		{"missing subject", natsClient, `{"data":{}}`, http.StatusBadRequest},
+		{"wildcard subject", natsClient, `{"subject":"test.*","data":{}}`, http.StatusBadRequest},
+		{"full wildcard subject", natsClient, `{"subject":"test.>","data":{}}`, http.StatusBadRequest},
+		{"empty token", natsClient, `{"subject":"test..echo","data":{}}`, http.StatusBadRequest},
+		{"whitespace in subject", natsClient, `{"subject":"test.echo now","data":{}}`, http.StatusBadRequest},
+		{"reserved header", natsClient, `{"subject":"test.echo","data":{"name":"Jane"},"headers":{"Nats-Msg-Id":"1"}}`, http.StatusBadRequest},
+		{"reserved header in lowercase", natsClient, `{"subject":"test.echo","data":{"name":"Jane"},"headers":{"nats-expected-stream":"users"}}`, http.StatusBadRequest},
+		{"not connected", &messaging.NatsClient{}, `{"subject":"test.echo","data":{}}`, http.StatusServiceUnavailable},
+	}
 
-	// Set environment variable for development mode to enable routes
-	os.Setenv("APP_ENV", "development")
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := NewMessaging(tc.client)
+			handler.Requests = messaging.RequestPolicy{Subjects: []string{"test.>"}, Timeout: 2 * time.Second}
 
-	// Restore environment when done
-	defer func() {
-		if exists {
-			os.Setenv("APP_ENV", oldEnv)
-		} else {
-			os.Unsetenv("APP_ENV")
-		}
-	}()
+			req := httptest.NewRequest(http.MethodPost, "/request", strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rr, req)
 
-	// Get the router again now that we're in development mode
-	router = handler.Router()
+			if status := rr.Code; status != tc.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v (%s)", status, tc.expectedStatus, rr.Body.String())
+			}
+			if tc.expectedStatus != http.StatusOK {
+				if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+					t.Errorf("Expected content type %s, got %s", problem.ContentType, ct)
+				}
+				return
+			}
 
-	// Check if router exists
-	if router == nil {
-		t.Fatal("Router should not be nil in development mode")
+			var response struct {
+				Data NatsReply `json:"data"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if string(response.Data.Data) != `{"name":"Jane"}` {
+				t.Errorf("Expected the echoed data, got %s", response.Data.Data)
+			}
+			if tenant := response.Data.Headers["Tenant"]; tenant != "acme" {
+				t.Errorf("Expected reply header Tenant acme, got %q", tenant)
+			}
+		})
 	}
 }
 
@@ -249,3 +332,26 @@ func TestMessagingRouter(t *testing.T) {
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+func TestRequestMessageCanceled(t *testing.T) {
+	natsClient := natstest.NewClient(t)
+	if _, err := natsClient.Subscribe("test.slow", func(msg *nats.Msg) {
+		time.Sleep(200 * time.Millisecond)
+		_ = msg.Respond([]byte("{}"))
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	handler := NewMessaging(natsClient)
+	handler.Requests = messaging.RequestPolicy{Subjects: []string{"test.>"}, Timeout: 2 * time.Second}
+
+	// The client goes away while the reply is awaited
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := handler.RequestMessage(ctx, NatsRequest{Subject: "test.slow"})
+
+	var perr *problem.Error
+	if !errors.As(err, &perr) || perr.Status != problem.StatusClientClosedRequest {
+		t.Errorf("RequestMessage() error = %v, want status %v", err, problem.StatusClientClosedRequest)
+	}
+}
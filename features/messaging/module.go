@@ -0,0 +1,30 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+)
+
+func init() {
+	module.Register(module.Definition{
+		Name:         "messaging",
+		Path:         "/messaging",
+		Dependencies: []module.Dependency{module.DependencyNats},
+		New: func(c *module.Container) (module.Module, error) {
+			m := NewMessaging(c.Nats)
+			m.Gates = c.Gates
+			m.Requests = c.NatsRequests
+			return m, nil
+		},
+	})
+}
+
+// HealthCheck reports whether the NATS connection is up
+func (m *Messaging) HealthCheck(ctx context.Context) error {
+	if m.NatsClient == nil || m.NatsClient.GetConn() == nil || !m.NatsClient.GetConn().IsConnected() {
+		return errors.New("not connected to NATS")
+	}
+	return nil
+}
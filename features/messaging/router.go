@@ -1,26 +1,23 @@
 package messaging
 
 import (
-	"os"
-	"strings"
+	"net/http"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/rs/zerolog/log"
 )
 
 // Router returns the router for messaging endpoints
-func (m *Messaging) Router() chi.Router {
+func (m *Messaging) Router() http.Handler {
 	r := chi.NewRouter()
 
-	// Only enable messaging routes in development environment
-	env := os.Getenv("APP_ENV")
-	if strings.ToLower(env) == "development" {
-		r.Post("/publish", m.PublishMessage)
-		r.Get("/subscribe/{subject}", m.SubscribeWebSocket)
-		log.Info().Msg("Messaging endpoints enabled in development mode")
-	} else {
-		log.Info().Msg("Messaging endpoints disabled in production mode")
-	}
+	// Request-reply bridge, restricted to the allowed subjects
+	r.Post("/request", utils.Handle(http.StatusOK, m.RequestMessage))
+
+	// Development tools, enabled per environment by the feature gates
+	m.Gates.Route(r, http.MethodPost, "/publish", "messaging.publish", m.PublishMessage)
+	m.Gates.Route(r, http.MethodGet, "/subscribe/{subject}", "messaging.subscribe", m.SubscribeWebSocket)
 
 	return r
 }
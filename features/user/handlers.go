@@ -1,27 +1,35 @@
 package user
 
 import (
-	"encoding/json"
-	"net/http"
+	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/LexiconIndonesia/go-http-service-template/common/db"
 	"github.com/LexiconIndonesia/go-http-service-template/common/models"
+	"github.com/LexiconIndonesia/go-http-service-template/common/pagination"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
 	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
 
 	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
 )
 
 // User handles user-related requests
 type User struct {
-	DB *db.DB
+	DB      db.Store
+	Cursors *pagination.Signer
 }
 
-// NewUser creates a new user handler
-func NewUser(db *db.DB) *User {
+// NewUser creates a new user handler. A nil cursor signer uses a random key.
+func NewUser(store db.Store, cursors *pagination.Signer) *User {
+	if cursors == nil {
+		cursors = pagination.NewSigner(nil)
+	}
 	return &User{
-		DB: db,
+		DB:      store,
+		Cursors: cursors,
 	}
 }
 
@@ -48,54 +56,302 @@ type UserCreationRequest struct {
 // @Description Create a new user with the provided information
 // @Tags users
 // @Accept json
-// @Produce json
+// @Produce json,application/msgpack
+// @Param Idempotency-Key header string false "Key making retries of the request safe"
 // @Param request body UserCreationRequest true "User creation request"
 // @Success 201 {object} utils.Response{data=UserResponse} "User created successfully"
-// @Failure 400 {object} utils.Response{error=string} "Invalid request body"
-// @Failure 500 {object} utils.Response{error=string} "Internal server error"
+// @Failure 400 {object} problem.Problem{errors=[]problem.FieldError} "Invalid request body"
+// @Failure 409 {object} problem.Problem "Email already registered, or a request with the same Idempotency-Key is in progress"
+// @Failure 422 {object} problem.Problem "Idempotency-Key reused for a different request"
+// @Failure 413 {object} problem.Problem "Request body too large"
+// @Failure 415 {object} problem.Problem "Unsupported content type"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 503 {object} problem.Problem "Database is not available"
 // @Router /users [post]
-func (u *User) CreateUser(w http.ResponseWriter, r *http.Request) {
-	// Parse request body
-	var userReq UserCreationRequest
-	if err := json.NewDecoder(r.Body).Decode(&userReq); err != nil {
-		log.Error().Err(err).Msg("Failed to decode request body")
-		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	// Convert to user model
-	user := models.User{
-		ID:        uuid.New().String(),
-		Email:     userReq.Email,
-		FirstName: userReq.FirstName,
-		LastName:  userReq.LastName,
-		Password:  userReq.Password,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	// Validate user
-	validator := models.NewUserValidator()
-	if err := validator.Validate(user); err != nil {
-		log.Error().Err(err).Msg("Invalid user data")
-		utils.WriteError(w, http.StatusBadRequest, "Invalid user data: "+err.Error())
-		return
-	}
-
-	// TODO: Save user to database
-	// In a real implementation, you would save the user to the database here
-	// using the dependency-injected DB
-
-	// Create response object (omitting password)
-	userResponse := UserResponse{
-		ID:        user.ID,
+func (u *User) CreateUser(ctx context.Context, req UserCreationRequest) (UserResponse, error) {
+	if u.DB == nil {
+		return UserResponse{}, problem.Unavailable("Database is not available")
+	}
+
+	// TODO: Hash and persist the password once the schema has a column for it
+	created, err := u.DB.CreateUser(ctx, repository.CreateUserParams{
+		Name:  joinName(req.FirstName, req.LastName),
+		Email: req.Email,
+	})
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return UserResponse{}, problem.Conflict("Email already registered")
+		}
+		return UserResponse{}, problem.Internal("Failed to create user", err)
+	}
+
+	// Return the created user (omitting password)
+	utils.ResponseHeader(ctx).Set("ETag", userETag(created))
+	return toUserResponse(created), nil
+}
+
+// GetUserRequest identifies the user to get
+type GetUserRequest struct {
+	ID uuid.UUID `json:"-" path:"id"`
+}
+
+// GetUser returns the user with the given ID
+// @Summary Get a user
+// @Description Get a user by ID
+// @Tags users
+// @Produce json,application/msgpack
+// @Param id path string true "User ID" format(uuid)
+// @Param If-None-Match header string false "ETag of a cached version, returns 304 when it is current"
+// @Success 200 {object} utils.Response{data=UserResponse} "User found"
+// @Header 200 {string} ETag "Version of the user"
+// @Success 304 "Cached version is current"
+// @Failure 400 {object} problem.Problem "Invalid user ID"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 503 {object} problem.Problem "Database is not available"
+// @Router /users/{id} [get]
+func (u *User) GetUser(ctx context.Context, req GetUserRequest) (UserResponse, error) {
+	if u.DB == nil {
+		return UserResponse{}, problem.Unavailable("Database is not available")
+	}
+
+	found, err := u.DB.GetUser(ctx, req.ID)
+	if err != nil {
+		if db.IsNotFound(err) {
+			return UserResponse{}, problem.NotFound("User not found")
+		}
+		return UserResponse{}, problem.Internal("Failed to get user", err)
+	}
+
+	utils.ResponseHeader(ctx).Set("ETag", userETag(found))
+	return toUserResponse(found), nil
+}
+
+// UserUpdateRequest represents the request to update a user. Omitted fields
+// are left unchanged.
+type UserUpdateRequest struct {
+	ID        uuid.UUID `json:"-" path:"id"`
+	IfMatch   string    `json:"-" header:"If-Match"`
+	Email     *string   `json:"email,omitempty" example:"user@example.com" validate:"omitnil,email"`
+	FirstName *string   `json:"first_name,omitempty" example:"John" validate:"omitnil,min=1"`
+	LastName  *string   `json:"last_name,omitempty" example:"Doe" validate:"omitnil,min=1"`
+}
+
+// UpdateUser updates a user
+// @Summary Update a user
+// @Description Update the given fields of a user. Send the ETag of the user in If-Match to make sure it was not modified since it was retrieved.
+// @Tags users
+// @Accept json
+// @Produce json,application/msgpack
+// @Param id path string true "User ID" format(uuid)
+// @Param If-Match header string false "ETag of the version being updated"
+// @Param request body UserUpdateRequest true "User update request"
+// @Success 200 {object} utils.Response{data=UserResponse} "User updated"
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} problem.Problem{errors=[]problem.FieldError} "Invalid request"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 409 {object} problem.Problem "Email already registered"
+// @Failure 412 {object} problem.Problem "User was modified since it was retrieved"
+// @Failure 415 {object} problem.Problem "Unsupported content type"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 503 {object} problem.Problem "Database is not available"
+// @Router /users/{id} [patch]
+func (u *User) UpdateUser(ctx context.Context, req UserUpdateRequest) (UserResponse, error) {
+	if u.DB == nil {
+		return UserResponse{}, problem.Unavailable("Database is not available")
+	}
+
+	var updated repository.User
+	err := u.DB.WithTx(ctx, db.DefaultTxOptions(), func(q repository.Querier) error {
+		current, err := q.GetUserForUpdate(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+		if err := utils.CheckIfMatch(req.IfMatch, userETag(current)); err != nil {
+			return err
+		}
+
+		params := repository.UpdateUserParams{ID: current.ID, Name: current.Name, Email: current.Email}
+		firstName, lastName := splitName(current.Name)
+		if req.FirstName != nil {
+			firstName = *req.FirstName
+		}
+		if req.LastName != nil {
+			lastName = *req.LastName
+		}
+		params.Name = joinName(firstName, lastName)
+		if req.Email != nil {
+			params.Email = *req.Email
+		}
+
+		updated, err = q.UpdateUser(ctx, params)
+		return err
+	})
+	if err != nil {
+		return UserResponse{}, userError(err, "Failed to update user")
+	}
+
+	utils.ResponseHeader(ctx).Set("ETag", userETag(updated))
+	return toUserResponse(updated), nil
+}
+
+// DeleteUserRequest identifies the user to delete
+type DeleteUserRequest struct {
+	ID      uuid.UUID `json:"-" path:"id"`
+	IfMatch string    `json:"-" header:"If-Match"`
+}
+
+// DeleteUser deletes a user
+// @Summary Delete a user
+// @Description Delete a user by ID. Send the ETag of the user in If-Match to make sure it was not modified since it was retrieved.
+// @Tags users
+// @Param id path string true "User ID" format(uuid)
+// @Param If-Match header string false "ETag of the version being deleted"
+// @Success 204 "User deleted"
+// @Failure 400 {object} problem.Problem "Invalid user ID"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 412 {object} problem.Problem "User was modified since it was retrieved"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 503 {object} problem.Problem "Database is not available"
+// @Router /users/{id} [delete]
+func (u *User) DeleteUser(ctx context.Context, req DeleteUserRequest) (utils.NoContent, error) {
+	if u.DB == nil {
+		return utils.NoContent{}, problem.Unavailable("Database is not available")
+	}
+
+	err := u.DB.WithTx(ctx, db.DefaultTxOptions(), func(q repository.Querier) error {
+		current, err := q.GetUserForUpdate(ctx, req.ID)
+		if err != nil {
+			return err
+		}
+		if err := utils.CheckIfMatch(req.IfMatch, userETag(current)); err != nil {
+			return err
+		}
+		return q.DeleteUser(ctx, current.ID)
+	})
+	if err != nil {
+		return utils.NoContent{}, userError(err, "Failed to delete user")
+	}
+	return utils.NoContent{}, nil
+}
+
+// userError maps an error from a user transaction to a problem
+func userError(err error, detail string) error {
+	var perr *problem.Error
+	switch {
+	case errors.As(err, &perr):
+		return perr
+	case db.IsNotFound(err):
+		return problem.NotFound("User not found")
+	case db.IsUniqueViolation(err):
+		return problem.Conflict("Email already registered")
+	default:
+		return problem.Internal(detail, err)
+	}
+}
+
+// userETag returns the entity tag of a user version. It is weak since the
+// user is served as JSON or MessagePack, which differ in bytes.
+func userETag(user repository.User) string {
+	return utils.NewETag(true, user.ID, user.UpdatedAt)
+}
+
+// ListUsersRequest holds the pagination, sort and filter parameters
+type ListUsersRequest struct {
+	pagination.Request
+}
+
+// UserListResponse is a page of users
+type UserListResponse struct {
+	Items []UserResponse            `json:"items"`
+	Meta  models.CursorMetaResponse `json:"meta"`
+}
+
+// MarshalCSV renders the users of the page as CSV, one row per user
+func (l UserListResponse) MarshalCSV() ([][]string, error) {
+	records := [][]string{{"id", "email", "first_name", "last_name", "created_at", "updated_at"}}
+	for _, user := range l.Items {
+		records = append(records, []string{
+			user.ID,
+			user.Email,
+			user.FirstName,
+			user.LastName,
+			user.CreatedAt.Format(time.RFC3339Nano),
+			user.UpdatedAt.Format(time.RFC3339Nano),
+		})
+	}
+	return records, nil
+}
+
+// ListUsers returns a page of users
+// @Summary List users
+// @Description List users with keyset pagination. Follow the cursors in the response meta or the Link header to get the next and previous pages.
+// @Description Sortable and filterable fields are id, email, name, created_at and updated_at. Filters have the form field:op:value with op one of eq, ne, lt, lte, gt, gte, like and in (values separated by |).
+// @Tags users
+// @Produce json,application/msgpack,text/csv
+// @Param limit query int false "Page size" minimum(1) maximum(100) default(20)
+// @Param cursor query string false "Cursor from a previous page"
+// @Param sort query string false "Comma separated fields, prefix with - for descending order" default(-created_at)
+// @Param filter query []string false "Filters such as email:like:example.com" collectionFormat(multi)
+// @Success 200 {object} utils.Response{data=UserListResponse} "Page of users"
+// @Header 200 {string} Link "Links to the next and previous pages"
+// @Failure 400 {object} problem.Problem "Invalid pagination, sort or filter parameter"
+// @Failure 406 {object} problem.Problem "No supported media type is acceptable"
+// @Failure 500 {object} problem.Problem "Internal server error"
+// @Failure 503 {object} problem.Problem "Database is not available"
+// @Router /users [get]
+func (u *User) ListUsers(ctx context.Context, req ListUsersRequest) (UserListResponse, error) {
+	if u.DB == nil {
+		return UserListResponse{}, problem.Unavailable("Database is not available")
+	}
+
+	params, err := db.UserListSchema.Parse(req.Values(), u.Cursors)
+	if err != nil {
+		return UserListResponse{}, err
+	}
+
+	rows, err := u.DB.ListUsers(db.ReadFromReplica(ctx), params)
+	if err != nil {
+		return UserListResponse{}, problem.Internal("Failed to list users", err)
+	}
+
+	page := pagination.NewPage(rows, params, db.UserValue, u.Cursors)
+	page.SetLinkHeader(utils.ResponseHeader(ctx), utils.RequestURL(ctx))
+
+	items := make([]UserResponse, len(page.Items))
+	for i, user := range page.Items {
+		items[i] = toUserResponse(user)
+	}
+	return UserListResponse{
+		Items: items,
+		Meta: models.CursorMetaResponse{
+			Limit:      params.Limit,
+			NextCursor: page.Next,
+			PrevCursor: page.Prev,
+		},
+	}, nil
+}
+
+// toUserResponse converts a stored user to its API representation
+func toUserResponse(user repository.User) UserResponse {
+	firstName, lastName := splitName(user.Name)
+	return UserResponse{
+		ID:        user.ID.String(),
 		Email:     user.Email,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
+		FirstName: firstName,
+		LastName:  lastName,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}
+}
+
+// joinName builds the stored name from first and last name
+func joinName(firstName, lastName string) string {
+	return strings.TrimSpace(firstName + " " + lastName)
+}
 
-	// Return success
-	utils.WriteJSON(w, http.StatusCreated, userResponse)
+// splitName splits a stored name into first and last name at the first space
+func splitName(name string) (string, string) {
+	firstName, lastName, _ := strings.Cut(name, " ")
+	return firstName, lastName
 }
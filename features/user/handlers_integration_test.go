@@ -0,0 +1,74 @@
+//go:build integration
+
+package user
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db/dbtest"
+)
+
+func TestUserEndpointsIntegration(t *testing.T) {
+	router := NewUser(dbtest.New(t), nil).Router()
+	body := `{"email":"test@example.com","first_name":"John","last_name":"Doe","password":"Password123!"}`
+
+	// Create the user
+	req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Create returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+
+	var created struct {
+		Data UserResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Creating it again conflicts on the unique email index
+	req = httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Duplicate create returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+
+	// Fetch the stored user
+	req = httptest.NewRequest("GET", "/"+created.Data.ID, nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Get returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var fetched struct {
+		Data UserResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &fetched); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if fetched.Data.Email != "test@example.com" || fetched.Data.FirstName != "John" || fetched.Data.LastName != "Doe" {
+		t.Errorf("Unexpected user returned: %+v", fetched.Data)
+	}
+
+	// Update with the fetched version, then again with the stale one
+	etag := rr.Header().Get("ETag")
+	for _, expected := range []int{http.StatusOK, http.StatusPreconditionFailed} {
+		req = httptest.NewRequest("PATCH", "/"+created.Data.ID, bytes.NewBufferString(`{"last_name":"Roe"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", etag)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != expected {
+			t.Fatalf("Update returned wrong status code: got %v want %v", rr.Code, expected)
+		}
+	}
+}
@@ -2,14 +2,20 @@ package user
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/LexiconIndonesia/go-http-service-template/common/db"
 	"github.com/LexiconIndonesia/go-http-service-template/common/models"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/middlewares"
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
+	"github.com/google/uuid"
 )
 
 func TestCreateUser(t *testing.T) {
@@ -66,14 +72,11 @@ func TestCreateUser(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			// Create a mock DB
-			mockDB := &db.DB{} // Using an empty DB since we're not actually saving the user
-
-			// Create handler with mock DB
-			handler := NewUser(mockDB)
+			// Create handler with an in-memory DB
+			handler := NewUser(db.NewFake(), nil)
 
 			// Create a request
-			req, err := http.NewRequest("POST", "/users", bytes.NewBufferString(tc.requestBody))
+			req, err := http.NewRequest("POST", "/", bytes.NewBufferString(tc.requestBody))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -83,7 +86,7 @@ func TestCreateUser(t *testing.T) {
 			rr := httptest.NewRecorder()
 
 			// Call the handler
-			handler.CreateUser(rr, req)
+			handler.Router().ServeHTTP(rr, req)
 
 			// Check status code
 			if rr.Code != tc.expectedStatus {
@@ -99,12 +102,18 @@ func TestCreateUser(t *testing.T) {
 
 			// Check for error or data based on expectation
 			if tc.expectError {
-				errorMsg, hasError := response["error"]
-				if !hasError {
-					t.Errorf("Expected error in response, got none")
+				if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+					t.Errorf("Expected content type %s, got %s", problem.ContentType, ct)
+				}
+				detail, hasDetail := response["detail"]
+				if !hasDetail {
+					t.Errorf("Expected problem detail in response, got none")
+				}
+				if detail == "" {
+					t.Errorf("Expected non-empty problem detail")
 				}
-				if errorMsg == "" {
-					t.Errorf("Expected non-empty error message")
+				if status, _ := response["status"].(float64); int(status) != tc.expectedStatus {
+					t.Errorf("Expected problem status %d, got %v", tc.expectedStatus, response["status"])
 				}
 			} else {
 				userData, hasData := response["data"]
@@ -156,12 +165,307 @@ func (m *MockUserValidator) Validate(user models.User) error {
 	return nil
 }
 
-func TestUserRouter(t *testing.T) {
-	// Create a mock DB
-	mockDB := &db.DB{}
+func TestCreateUserValidationDetails(t *testing.T) {
+	tests := []struct {
+		name            string
+		acceptLanguage  string
+		expectedMessage string
+	}{
+		{name: "English", acceptLanguage: "", expectedMessage: "first_name is a required field"},
+		{name: "Indonesian", acceptLanguage: "id-ID,id;q=0.9,en;q=0.8", expectedMessage: "first_name wajib diisi"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := NewUser(db.NewFake(), nil)
+			body := `{"email":"test@example.com","first_name":"","last_name":"Doe","password":"short"}`
+
+			req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tc.acceptLanguage)
+			}
+			rr := httptest.NewRecorder()
+			handler.Router().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+			}
+
+			var response problem.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			fields := map[string]problem.FieldError{}
+			for _, fe := range response.Errors {
+				fields[fe.Field] = fe
+			}
+
+			firstName, ok := fields["first_name"]
+			if !ok {
+				t.Fatalf("Expected an error for first_name, got %+v", response.Errors)
+			}
+			if firstName.Rule != "required" {
+				t.Errorf("Expected rule required, got %s", firstName.Rule)
+			}
+			if firstName.Message != tc.expectedMessage {
+				t.Errorf("Expected message %q, got %q", tc.expectedMessage, firstName.Message)
+			}
+
+			password, ok := fields["password"]
+			if !ok {
+				t.Fatalf("Expected an error for password, got %+v", response.Errors)
+			}
+			if password.Rule != "min" || password.Param != "8" {
+				t.Errorf("Expected rule min=8, got %s=%s", password.Rule, password.Param)
+			}
+		})
+	}
+}
+
+func TestCreateUserDuplicateEmail(t *testing.T) {
+	handler := NewUser(db.NewFake(), nil)
+	body := `{"email":"test@example.com","first_name":"John","last_name":"Doe","password":"Password123!"}`
+
+	// First request creates the user
+	req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("First request returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+
+	// Second request with the same email conflicts
+	req = httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	handler.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Second request returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	store := db.NewFake()
+	created, err := store.CreateUser(context.Background(), repository.CreateUserParams{
+		Name:  "John Doe",
+		Email: "test@example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed user: %v", err)
+	}
+
+	router := NewUser(store, nil).Router()
+
+	tests := []struct {
+		name           string
+		id             string
+		expectedStatus int
+	}{
+		{name: "Existing user", id: created.ID.String(), expectedStatus: http.StatusOK},
+		{name: "Unknown user", id: uuid.New().String(), expectedStatus: http.StatusNotFound},
+		{name: "Invalid ID", id: "not-a-uuid", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/"+tc.id, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatus)
+			}
+
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data UserResponse `json:"data"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Data.FirstName != "John" || response.Data.LastName != "Doe" {
+				t.Errorf("Expected name John Doe, got %s %s", response.Data.FirstName, response.Data.LastName)
+			}
+			if response.Data.Email != "test@example.com" {
+				t.Errorf("Expected email test@example.com, got %s", response.Data.Email)
+			}
+		})
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	store := db.NewFake()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := store.CreateUser(context.Background(), repository.CreateUserParams{Name: "John Doe", Email: email}); err != nil {
+			t.Fatalf("Failed to seed user: %v", err)
+		}
+	}
 
-	// Create handler with mock DB
-	handler := NewUser(mockDB)
+	router := NewUser(store, nil).Router()
+
+	type listResponse struct {
+		Data UserListResponse `json:"data"`
+	}
+	list := func(target string) (*httptest.ResponseRecorder, listResponse) {
+		req := httptest.NewRequest("GET", target, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var response listResponse
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rr, response
+	}
+
+	// First page links to the next one
+	rr, first := list("/?sort=email&limit=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if len(first.Data.Items) != 2 || first.Data.Items[0].Email != "a@example.com" {
+		t.Fatalf("Unexpected first page: %+v", first.Data.Items)
+	}
+	if first.Data.Meta.NextCursor == "" || first.Data.Meta.PrevCursor != "" {
+		t.Errorf("Unexpected cursors on first page: %+v", first.Data.Meta)
+	}
+	link := rr.Header().Get("Link")
+	if !strings.Contains(link, `rel="next"`) || strings.Contains(link, `rel="prev"`) {
+		t.Errorf("Unexpected Link header on first page: %s", link)
+	}
+
+	// Follow the next link
+	next := strings.TrimPrefix(strings.Split(link, ">")[0], "<")
+	rr, second := list(next)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if len(second.Data.Items) != 1 || second.Data.Items[0].Email != "c@example.com" {
+		t.Errorf("Unexpected second page: %+v", second.Data.Items)
+	}
+	if second.Data.Meta.NextCursor != "" || second.Data.Meta.PrevCursor == "" {
+		t.Errorf("Unexpected cursors on last page: %+v", second.Data.Meta)
+	}
+
+	// The list can be downloaded as CSV, with pagination in the Link header
+	req := httptest.NewRequest("GET", "/?sort=email&limit=2", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != "id,email,first_name,last_name,created_at,updated_at" || !strings.Contains(lines[1], "a@example.com") {
+		t.Errorf("Unexpected CSV body: %q", rr.Body.String())
+	}
+	if !strings.Contains(rr.Header().Get("Link"), `rel="next"`) {
+		t.Errorf("Expected a next link for CSV, got %q", rr.Header().Get("Link"))
+	}
+
+	// Invalid parameters are rejected
+	for _, target := range []string{"/?sort=password", "/?filter=email:regex:x", "/?limit=0", "/?cursor=forged"} {
+		if rr, _ := list(target); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s returned wrong status code: got %v want %v", target, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUserConditionalRequests(t *testing.T) {
+	store := db.NewFake()
+	created, err := store.CreateUser(context.Background(), repository.CreateUserParams{
+		Name:  "John Doe",
+		Email: "test@example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed user: %v", err)
+	}
+
+	router := middlewares.ETag()(NewUser(store, nil).Router())
+	path := "/" + created.ID.String()
+	send := func(method, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header = header
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Get returns the version of the user
+	rr := send("GET", "", http.Header{})
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %v %q", rr.Code, etag)
+	}
+
+	// A cached version that is current is not sent again
+	rr = send("GET", "", http.Header{"If-None-Match": {etag}})
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("Expected 304 without body, got %v %q", rr.Code, rr.Body.String())
+	}
+
+	// Updating with the current version succeeds and changes the version
+	rr = send("PATCH", `{"first_name":"Jane"}`, http.Header{"If-Match": {etag}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	newETag := rr.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("Expected a new ETag after update, got %q", newETag)
+	}
+	var response struct {
+		Data UserResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Data.FirstName != "Jane" || response.Data.LastName != "Doe" || response.Data.Email != "test@example.com" {
+		t.Errorf("Unexpected updated user: %+v", response.Data)
+	}
+
+	// Updates are validated
+	rr = send("PATCH", `{"email":"not-an-email"}`, http.Header{})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid email, got %v", rr.Code)
+	}
+
+	// The old version is now stale
+	rr = send("GET", "", http.Header{"If-None-Match": {etag}})
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a stale cached version, got %v", rr.Code)
+	}
+	rr = send("PATCH", `{"last_name":"Roe"}`, http.Header{"If-Match": {etag}})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale update, got %v", rr.Code)
+	}
+	rr = send("DELETE", "", http.Header{"If-Match": {etag}})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale delete, got %v", rr.Code)
+	}
+
+	// Deleting the current version succeeds
+	rr = send("DELETE", "", http.Header{"If-Match": {newETag}})
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for delete, got %v: %s", rr.Code, rr.Body.String())
+	}
+	rr = send("DELETE", "", http.Header{})
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after delete, got %v", rr.Code)
+	}
+}
+
+func TestUserRouter(t *testing.T) {
+	// Create handler with an in-memory DB
+	handler := NewUser(db.NewFake(), nil)
 
 	// Get the router
 	router := handler.Router()
@@ -173,3 +477,51 @@ func TestUserRouter(t *testing.T) {
 		t.Fatal("Router should not be nil")
 	}
 }
+
+func TestUserETagRepresentations(t *testing.T) {
+	store := db.NewFake()
+	created, err := store.CreateUser(context.Background(), repository.CreateUserParams{
+		Name:  "John Doe",
+		Email: "test@example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed user: %v", err)
+	}
+
+	router := middlewares.ETag()(NewUser(store, nil).Router())
+	path := "/" + created.ID.String()
+	send := func(method, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header = header
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	jsonResponse := send("GET", "", http.Header{"Accept": {"application/json"}})
+	msgpackResponse := send("GET", "", http.Header{"Accept": {"application/msgpack"}})
+	if jsonResponse.Code != http.StatusOK || msgpackResponse.Code != http.StatusOK {
+		t.Fatalf("Expected 200 twice, got %v and %v", jsonResponse.Code, msgpackResponse.Code)
+	}
+	if bytes.Equal(jsonResponse.Body.Bytes(), msgpackResponse.Body.Bytes()) {
+		t.Fatal("Expected JSON and MessagePack bodies to differ")
+	}
+
+	// Both representations share a weak tag, which does not claim equal bytes
+	etag := jsonResponse.Header().Get("ETag")
+	if !strings.HasPrefix(etag, "W/") || msgpackResponse.Header().Get("ETag") != etag {
+		t.Errorf("Expected the same weak ETag, got %q and %q", etag, msgpackResponse.Header().Get("ETag"))
+	}
+
+	// The tag of one representation validates the other, as weak comparison
+	// allows
+	rr := send("GET", "", http.Header{"Accept": {"application/msgpack"}, "If-None-Match": {etag}})
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for the MessagePack representation, got %v", rr.Code)
+	}
+	rr = send("PATCH", `{"first_name":"Jane"}`, http.Header{"Accept": {"application/msgpack"}, "If-Match": {etag}})
+	if rr.Code != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+}
@@ -0,0 +1,27 @@
+package user
+
+import (
+	"net/http"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+)
+
+func init() {
+	module.Register(module.Definition{
+		Name:         "users",
+		Path:         "/users",
+		Dependencies: []module.Dependency{module.DependencyDB},
+		New: func(c *module.Container) (module.Module, error) {
+			return NewUser(c.Store, c.Cursors), nil
+		},
+	})
+}
+
+// Operations returns the routes served over NATS, as users.<name> subjects
+func (u *User) Operations() []module.Operation {
+	return []module.Operation{
+		{Name: "create", Method: http.MethodPost, Pattern: "/"},
+		{Name: "get", Method: http.MethodGet, Pattern: "/{id}"},
+		{Name: "list", Method: http.MethodGet, Pattern: "/"},
+	}
+}
@@ -1,12 +1,20 @@
 package user
 
 import (
+	"net/http"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+
 	"github.com/go-chi/chi/v5"
 )
 
 // Router returns the router for user endpoints
-func (u *User) Router() chi.Router {
+func (u *User) Router() http.Handler {
 	r := chi.NewRouter()
-	r.Post("/", u.CreateUser)
+	r.Get("/", utils.Handle(http.StatusOK, u.ListUsers))
+	r.Post("/", utils.Handle(http.StatusCreated, u.CreateUser))
+	r.Get("/{id}", utils.Handle(http.StatusOK, u.GetUser))
+	r.Patch("/{id}", utils.Handle(http.StatusOK, u.UpdateUser))
+	r.Delete("/{id}", utils.Handle(http.StatusNoContent, u.DeleteUser))
 	return r
 }
@@ -8,13 +8,18 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/LexiconIndonesia/go-http-service-template/common/buildinfo"
 	"github.com/LexiconIndonesia/go-http-service-template/common/db"
+	"github.com/LexiconIndonesia/go-http-service-template/common/lifecycle"
 	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+	"github.com/LexiconIndonesia/go-http-service-template/common/pagination"
 	"github.com/LexiconIndonesia/go-http-service-template/repository"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
-	zerolog "github.com/jackc/pgx-zerolog"
+	pgxzerolog "github.com/jackc/pgx-zerolog"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/tracelog"
 	"github.com/joho/godotenv"
@@ -55,6 +60,21 @@ func main() {
 
 	cfg := defaultConfig()
 	cfg.loadFromEnv()
+	started := time.Now()
+
+	// `routes [-check]` prints the route table compared to the OpenAPI spec
+	if len(os.Args) > 1 && os.Args[1] == "routes" {
+		os.Exit(runRoutes(cfg, os.Args[2:], os.Stdout))
+	}
+
+	if level, err := zerolog.ParseLevel(cfg.App.LogLevel); err == nil {
+		zerolog.SetGlobalLevel(level)
+	} else {
+		log.Warn().Err(err).Msg("Ignoring LOG_LEVEL")
+	}
+
+	build := buildinfo.Get()
+	log.Info().Str("version", build.Version).Str("commit", build.Commit).Msg("Starting service")
 
 	// Create a base context with cancel for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -64,81 +84,152 @@ func main() {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	// Components register how they stop, in phases: stop accepting traffic
+	// and drain HTTP, stop consumers, flush publishes, close connections
+	lc := lifecycle.New()
+
 	// INITIATE DATABASES
 	dbConn, err := setupDatabase(ctx, cfg)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to setup database")
 	}
-	defer dbConn.Close()
+	lc.Register(lifecycle.Hook{
+		Name:  "database",
+		Phase: lifecycle.PhaseClose,
+		Stop: func(context.Context) error {
+			dbConn.Close()
+			return nil
+		},
+	})
 
 	// INITIATE NATS CLIENT
 	natsClient, err := setupNatsClient(cfg)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to setup NATS client")
 	}
-	defer natsClient.Close()
+	lc.Register(lifecycle.Hook{
+		Name:  "nats connection",
+		Phase: lifecycle.PhaseClose,
+		Stop:  natsClient.CloseContext,
+	})
+	lc.Register(lifecycle.Hook{
+		Name:  "nats publishes",
+		Phase: lifecycle.PhaseFlush,
+		Stop:  natsClient.FlushPublishes,
+	})
 
 	// Setup global subscriptions
 	if err := setupGlobalSubscriptions(natsClient); err != nil {
 		log.Fatal().Err(err).Msg("Failed to setup global subscriptions")
 	}
+	lc.Register(lifecycle.Hook{
+		Name:    "nats consumers",
+		Phase:   lifecycle.PhaseConsumers,
+		Stop:    natsClient.StopConsumers,
+		Timeout: 30 * time.Second,
+	})
+
+	// Dependencies shared by the feature modules
+	if cfg.Security.CursorSecret == "" {
+		log.Warn().Msg("CURSOR_SECRET not set, pagination cursors will not survive restarts")
+	}
+	container := &module.Container{
+		DB:           dbConn,
+		Store:        dbConn,
+		Nats:         natsClient,
+		NatsRequests: cfg.Nats.requestPolicy(),
+		Cursors:      pagination.NewSigner([]byte(cfg.Security.CursorSecret)),
+		Environment:  cfg.App.Environment,
+		Gates:        cfg.App.gates(),
+	}
 
 	// INITIATE SERVER
-	server, err := NewAppHttpServer(cfg)
+	server, err := NewAppHttpServer(cfg, container)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create the server")
 	}
 
-	// Inject dependencies
-	server.SetDB(dbConn)
-	server.SetNatsClient(natsClient)
-
-	// Setup routes
-	server.setupRoute()
+	// Build the modules and setup routes
+	if err := server.setupRoute(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to setup modules")
+	}
+	for _, m := range server.modules {
+		if hook, ok := m.Hook(); ok {
+			lc.Register(hook)
+		}
+	}
 
-	// Start server in a goroutine
-	go func() {
-		if err := server.start(); err != nil {
-			log.Error().Err(err).Msg("Server error")
-			cancel()
+	// Serve the module operations to other services over NATS
+	if cfg.Nats.Service {
+		svc, err := server.natsService()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to start the NATS service")
 		}
-	}()
+		lc.Register(lifecycle.Hook{
+			Name:  "nats service",
+			Phase: lifecycle.PhaseConsumers,
+			Stop: func(context.Context) error {
+				return svc.Stop()
+			},
+		})
+	}
 
-	log.Info().Str("address", cfg.Listen.Addr()).Msg("Server started successfully")
-	log.Info().Str("swagger", fmt.Sprintf("http://%s/swagger/index.html", cfg.Listen.Addr())).Msg("Swagger documentation available at")
+	lc.Register(lifecycle.Hook{
+		Name:  "http server",
+		Phase: lifecycle.PhaseIngress,
+		Start: func(context.Context) error {
+			return server.start()
+		},
+		Stop:    server.stop,
+		Timeout: 30 * time.Second,
+	})
 
-	// Wait for shutdown signal
-	<-shutdown
-	log.Info().Msg("Shutdown signal received")
+	// Profiling, runtime stats, configuration and log level on localhost
+	if cfg.Admin.Enabled {
+		adminServer := NewAdminHttpServer(cfg, started)
+		lc.Register(lifecycle.Hook{
+			Name:  "admin server",
+			Phase: lifecycle.PhaseIngress,
+			Start: func(context.Context) error {
+				return adminServer.start()
+			},
+			Stop: adminServer.stop,
+		})
+	}
 
-	// Create a timeout context for graceful shutdown
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := lc.Start(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start")
+	}
+
+	log.Info().Str("address", cfg.Listen.Addr()).Bool("tls", cfg.Listen.TLS.Enabled()).Bool("h2c", cfg.Listen.H2C).Msg("Server started successfully")
+	log.Info().Str("swagger", fmt.Sprintf("%s://%s/swagger/index.html", cfg.Listen.Scheme(), cfg.Listen.Addr())).Msg("Swagger documentation available at")
+
+	// Wait for shutdown signal or a server failure
+	select {
+	case <-shutdown:
+		log.Info().Msg("Shutdown signal received")
+	case err := <-server.Err():
+		log.Error().Err(err).Msg("Server error")
+	}
+	cancel()
+
+	// Bound the whole shutdown, each component also has its own timeout
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.App.ShutdownTimeout)
 	defer shutdownCancel()
 
-	if err := server.stop(shutdownCtx); err != nil {
-		log.Error().Err(err).Msg("Server shutdown failed")
+	if err := lc.Stop(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Shutdown incomplete")
+		return
 	}
 
 	log.Info().Msg("Server gracefully stopped")
 }
 
-// setupDatabase initializes the database connection
+// setupDatabase initializes the database connection and any read replicas
 func setupDatabase(ctx context.Context, cfg config) (*db.DB, error) {
-	config, err := pgxpool.ParseConfig(cfg.PgSql.ConnStr())
-	if err != nil {
-		return nil, fmt.Errorf("parsing database config: %w", err)
-	}
-
-	// Setup logger
-	logger := zerolog.NewLogger(log.Logger)
-	config.ConnConfig.Tracer = &tracelog.TraceLog{
-		Logger:   logger,
-		LogLevel: tracelog.LogLevelInfo,
-	}
-
-	pgsqlClient, err := pgxpool.NewWithConfig(ctx, config)
+	pgsqlClient, err := newPgxPool(ctx, cfg.PgSql.ConnStr())
 	if err != nil {
-		return nil, fmt.Errorf("connecting to database: %w", err)
+		return nil, err
 	}
 
 	// Test connection
@@ -146,17 +237,54 @@ func setupDatabase(ctx context.Context, cfg config) (*db.DB, error) {
 		return nil, fmt.Errorf("pinging database: %w", err)
 	}
 
+	// Replicas are health checked in the background, so an unreachable
+	// replica does not prevent startup
+	replicas := make([]*pgxpool.Pool, 0, len(cfg.PgSql.ReplicaURLs))
+	for i, connStr := range cfg.PgSql.ReplicaURLs {
+		replica, err := newPgxPool(ctx, connStr)
+		if err != nil {
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
 	queries := repository.New(pgsqlClient)
 
 	// Create DB struct for dependency injection
-	dbConn, err := db.New(pgsqlClient, queries)
+	dbConn, err := db.New(pgsqlClient, queries, db.WithReplicas(replicas...))
 	if err != nil {
 		return nil, fmt.Errorf("creating DB handler: %w", err)
 	}
 
+	if len(replicas) > 0 {
+		log.Info().Int("replicas", len(replicas)).Msg("Read replicas configured")
+	}
+
 	return dbConn, nil
 }
 
+// newPgxPool creates a connection pool with query logging
+func newPgxPool(ctx context.Context, connStr string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing database config: %w", err)
+	}
+
+	// Setup logger
+	logger := pgxzerolog.NewLogger(log.Logger)
+	config.ConnConfig.Tracer = &tracelog.TraceLog{
+		Logger:   logger,
+		LogLevel: tracelog.LogLevelInfo,
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+
+	return pool, nil
+}
+
 // setupNatsClient initializes the NATS client
 func setupNatsClient(cfg config) (*messaging.NatsClient, error) {
 	natsConfig := messaging.Config{
@@ -20,12 +20,12 @@ func AccessTime() func(next http.Handler) http.Handler {
 			access, err := strconv.ParseFloat(reqTime, 64)
 
 			if err != nil {
-				middlewareError(w, http.StatusBadRequest, "Bad Request", "Invalid X-ACCESS-TIME Header")
+				middlewareError(w, r, http.StatusBadRequest, "Invalid X-ACCESS-TIME Header")
 				return
 			}
 
 			if access > float64(safeTime.Unix()) {
-				middlewareError(w, http.StatusBadRequest, "Bad Request", "Invalid X-ACCESS-TIME Header")
+				middlewareError(w, r, http.StatusBadRequest, "Invalid X-ACCESS-TIME Header")
 				return
 			}
 			next.ServeHTTP(w, r)
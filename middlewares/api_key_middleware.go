@@ -13,7 +13,7 @@ func ApiKey(serverApiKeys string, salt string) func(next http.Handler) http.Hand
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 			if len(serverApiKeys) <= 0 || len(salt) <= 0 {
-				middlewareError(w, http.StatusInternalServerError, "Internal Server Error", "No API Key Found")
+				middlewareError(w, r, http.StatusInternalServerError, "No API Key Found")
 				return
 			}
 
@@ -21,12 +21,12 @@ func ApiKey(serverApiKeys string, salt string) func(next http.Handler) http.Hand
 			apiKey := r.Header.Get("X-API-KEY")
 
 			if len(hostname) <= 0 {
-				middlewareError(w, http.StatusForbidden, "Forbidden", "Missing X-REQUEST-IDENTITY")
+				middlewareError(w, r, http.StatusForbidden, "Missing X-REQUEST-IDENTITY")
 				return
 			}
 
 			if len(apiKey) <= 0 {
-				middlewareError(w, http.StatusForbidden, "Forbidden", "Missing X-API-KEY")
+				middlewareError(w, r, http.StatusForbidden, "Missing X-API-KEY")
 				return
 			}
 
@@ -38,16 +38,16 @@ func ApiKey(serverApiKeys string, salt string) func(next http.Handler) http.Hand
 			accessedKey := serverApiKeys
 
 			if accessedKey == "" {
-				middlewareError(w, http.StatusForbidden, "Forbidden", "Invalid X-API-KEY Header")
+				middlewareError(w, r, http.StatusForbidden, "Invalid X-API-KEY Header")
 				return
 			}
 
 			if accessedKey != hashedKey {
-				middlewareError(w, http.StatusBadRequest, "Bad Request", "Invalid X-API-KEY Header")
+				middlewareError(w, r, http.StatusBadRequest, "Invalid X-API-KEY Header")
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, withVerifiedKey(r, hashedKey))
 		})
 	}
 
@@ -0,0 +1,246 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultCompressMinSize is the smallest response body that is compressed
+const DefaultCompressMinSize = 1024
+
+// CompressOptions configures the Compress middleware
+type CompressOptions struct {
+	// MinSize is the smallest response body worth compressing, smaller
+	// bodies are sent as is. Defaults to DefaultCompressMinSize.
+	MinSize int
+}
+
+// compressibleTypes are the media types that are compressed, in addition to
+// all text types except event streams
+var compressibleTypes = map[string]bool{
+	"application/json":         true,
+	"application/problem+json": true,
+	"application/msgpack":      true,
+	"application/x-msgpack":    true,
+	"application/javascript":   true,
+	"application/xml":          true,
+	"image/svg+xml":            true,
+}
+
+// encoder is implemented by the gzip, zstd and brotli writers
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoders pools writers per content coding, in order of preference
+var encoders = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{"br", &sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, 5) }}},
+	{"zstd", &sync.Pool{New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}}},
+	{"gzip", &sync.Pool{New: func() any { return gzip.NewWriter(nil) }}},
+}
+
+// Compress compresses responses with brotli, zstd or gzip as negotiated with
+// the Accept-Encoding header. Only bodies of at least opts.MinSize bytes with
+// a compressible content type are compressed. WebSocket upgrades and server
+// sent event streams are passed through, as are responses that already have
+// a Content-Encoding. Strong ETags of compressed responses get the coding as
+// suffix, which utils.ETagMatch accepts in conditional requests.
+func Compress(opts CompressOptions) func(next http.Handler) http.Handler {
+	if opts.MinSize <= 0 {
+		opts.MinSize = DefaultCompressMinSize
+	}
+
+	offers := make([]string, len(encoders))
+	for i, e := range encoders {
+		offers[i] = e.name
+	}
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if r.Header.Get("Upgrade") != "" || r.Header.Get("Accept") == "text/event-stream" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := utils.NegotiateEncoding(r, offers...)
+			if encoding == "identity" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: opts.MinSize}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// response is worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	decided bool
+	buf     bytes.Buffer
+	enc     encoder
+	pool    *sync.Pool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 {
+		return
+	}
+	cw.status = status
+
+	// Informational responses are sent right away and do not end the headers
+	if status >= 100 && status < 200 {
+		cw.status = 0
+		cw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		cw.buf.Write(b)
+		if cw.buf.Len() < cw.minSize {
+			return len(b), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends buffered data. A response flushed before reaching the size
+// threshold is streamed uncompressed.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.WriteHeader(http.StatusOK)
+		}
+		_ = cw.decide(false)
+	}
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			log.Warn().Err(err).Msg("Failed to flush compressed response")
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide sends the headers, starting the encoder when compress is set and
+// the response is compressible, and writes the buffered body
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	h := cw.Header()
+
+	if compress && cw.compressible() {
+		for _, e := range encoders {
+			if e.name == cw.encoding {
+				cw.pool = e.pool
+			}
+		}
+		cw.enc = cw.pool.Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		if etag := h.Get("ETag"); etag != "" {
+			h.Set("ETag", utils.ETagWithCoding(etag, cw.encoding))
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	if mediaType == "text/event-stream" {
+		return false
+	}
+	return compressibleTypes[mediaType] || strings.HasPrefix(mediaType, "text/")
+}
+
+// close finishes the response, sending small bodies uncompressed
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 {
+			// Nothing was written, leave the response to the server
+			return
+		}
+		_ = cw.decide(false)
+	}
+
+	if cw.enc != nil {
+		if err := cw.enc.Close(); err != nil {
+			log.Warn().Err(err).Msg("Failed to finish compressed response")
+		}
+		cw.enc.Reset(nil)
+		cw.pool.Put(cw.enc)
+		cw.enc = nil
+	}
+}
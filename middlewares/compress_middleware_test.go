@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"message":"hello"}`, 200)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "/binary":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte(large))
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: " + large + "\n\n"))
+			w.(http.Flusher).Flush()
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			// Written in chunks to cross the threshold mid-response
+			for i := 0; i < len(large); i += 100 {
+				_, _ = w.Write([]byte(large[i:min(i+100, len(large))]))
+			}
+		}
+	})
+	mw := Compress(CompressOptions{})(handler)
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+
+	tests := []struct {
+		name             string
+		path             string
+		acceptEncoding   string
+		header           http.Header
+		expectedEncoding string
+	}{
+		{name: "Gzip", path: "/", acceptEncoding: "gzip", expectedEncoding: "gzip"},
+		{name: "Brotli preferred", path: "/", acceptEncoding: "gzip, deflate, br, zstd", expectedEncoding: "br"},
+		{name: "Zstd", path: "/", acceptEncoding: "zstd, gzip;q=0.5", expectedEncoding: "zstd"},
+		{name: "No Accept-Encoding", path: "/", expectedEncoding: ""},
+		{name: "Below threshold", path: "/small", acceptEncoding: "gzip", expectedEncoding: ""},
+		{name: "Incompressible type", path: "/binary", acceptEncoding: "gzip", expectedEncoding: ""},
+		{name: "Event stream", path: "/events", acceptEncoding: "gzip", expectedEncoding: ""},
+		{name: "WebSocket upgrade", path: "/", acceptEncoding: "gzip", header: http.Header{"Upgrade": {"websocket"}}, expectedEncoding: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			for name, values := range tc.header {
+				req.Header[name] = values
+			}
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			mw.ServeHTTP(rr, req)
+
+			if enc := rr.Header().Get("Content-Encoding"); enc != tc.expectedEncoding {
+				t.Fatalf("Expected Content-Encoding %q, got %q", tc.expectedEncoding, enc)
+			}
+
+			var body io.Reader = rr.Body
+			if tc.expectedEncoding != "" {
+				var err error
+				if body, err = decoders[tc.expectedEncoding](rr.Body); err != nil {
+					t.Fatalf("Failed to create decoder: %v", err)
+				}
+			}
+			decoded, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("Failed to read body: %v", err)
+			}
+
+			expected := large
+			switch tc.path {
+			case "/small":
+				expected = `{"ok":true}`
+			case "/events":
+				expected = "data: " + large + "\n\n"
+			}
+			if string(decoded) != expected {
+				t.Errorf("Body differs after decoding, got %d bytes want %d", len(decoded), len(expected))
+			}
+		})
+	}
+}
@@ -1,25 +1,11 @@
 package middlewares
 
 import (
-	"encoding/json"
 	"net/http"
-)
-
-func middlewareError(w http.ResponseWriter, s int, err string, m string) {
-	var j struct {
-		Error string `json:"error"`
-		Msg   string `json:"message"`
-	}
-
-	j.Error = err
-	j.Msg = m
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(s)
-
-	if err := json.NewEncoder(w).Encode(j); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		return
-	}
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+)
 
+func middlewareError(w http.ResponseWriter, r *http.Request, s int, m string) {
+	problem.Write(w, r, problem.New(s, m))
 }
@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+)
+
+// ETag adds entity tags to successful GET and HEAD responses and answers
+// conditional requests whose If-None-Match matches with 304 Not Modified.
+//
+// Handlers may set their own ETag header, for example one derived from the
+// resource version, otherwise a strong tag is computed from the response
+// body. Responses are buffered, so WebSocket upgrades and event streams are
+// passed through untouched.
+func ETag() func(next http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) ||
+				r.Header.Get("Upgrade") != "" ||
+				r.Header.Get("Accept") == "text/event-stream" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ew := &etagWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(ew, r)
+			ew.finish(r)
+		})
+	}
+
+}
+
+// etagWriter buffers a response until its entity tag is known
+type etagWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (ew *etagWriter) WriteHeader(status int) {
+	if !ew.wroteHeader {
+		ew.status = status
+		ew.wroteHeader = true
+	}
+}
+
+func (ew *etagWriter) Write(b []byte) (int, error) {
+	ew.WriteHeader(http.StatusOK)
+	return ew.body.Write(b)
+}
+
+func (ew *etagWriter) finish(r *http.Request) {
+	w := ew.ResponseWriter
+
+	if ew.status != http.StatusOK {
+		w.WriteHeader(ew.status)
+		_, _ = w.Write(ew.body.Bytes())
+		return
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		etag = utils.NewETag(false, ew.body.Bytes())
+		w.Header().Set("ETag", etag)
+	}
+
+	if utils.ETagMatch(r.Header.Get("If-None-Match"), etag, false) {
+		h := w.Header()
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(ew.status)
+	_, _ = w.Write(ew.body.Bytes())
+}
@@ -0,0 +1,217 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/idempotency"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the idempotency key
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from the store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength bounds the length of client keys
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyOptions configures the Idempotency middleware
+type IdempotencyOptions struct {
+	// TTL is how long responses are kept for replay, 24 hours by default
+	TTL time.Duration
+	// LockTimeout is how long an in-flight request holds its key before a
+	// retry may take it over, 2 minutes by default
+	LockTimeout time.Duration
+	// Identity scopes keys to a client, ClientIdentity by default. It must
+	// return verified identities only, otherwise a client could replay the
+	// responses of another by claiming its identity.
+	Identity func(r *http.Request) string
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first response for a client and key is stored and replayed for
+// retries with the same method, path and body. Reusing a key for another
+// request returns 422, and a retry arriving while the first request is still
+// in flight returns 409. Transient failures such as server errors are not
+// stored, so the request can be retried.
+//
+// Keys are scoped by the verified identity of the client (see ClientIdentity).
+// Without a client certificate or an API key checked by ApiKey, keys are
+// shared by the clients behind the same IP address.
+func Idempotency(store idempotency.Store, opts IdempotencyOptions) func(next http.Handler) http.Handler {
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = 2 * time.Minute
+	}
+	if opts.Identity == nil {
+		opts.Identity = ClientIdentity
+	}
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			clientKey := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || clientKey == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(clientKey) > maxIdempotencyKeyLength {
+				problem.WriteError(w, r, problem.BadRequest("Idempotency-Key is too long"))
+				return
+			}
+
+			requestHash, err := hashRequest(r)
+			if err != nil {
+				problem.WriteError(w, r, problem.BadRequest("Failed to read request body").Wrap(err))
+				return
+			}
+
+			// Hashing keeps client identities out of the store and yields
+			// keys that are valid for every store
+			sum := sha256.Sum256([]byte(opts.Identity(r) + "\x00" + clientKey))
+			key := hex.EncodeToString(sum[:])
+
+			existing, reserved, err := store.Reserve(r.Context(), key, idempotency.Record{
+				RequestHash: requestHash,
+				ExpiresAt:   time.Now().Add(opts.LockTimeout),
+			})
+			if err != nil {
+				problem.WriteError(w, r, problem.Unavailable("Idempotency store is not available").Wrap(err))
+				return
+			}
+
+			if !reserved {
+				switch {
+				case existing.RequestHash != requestHash:
+					problem.WriteError(w, r, problem.UnprocessableEntity("Idempotency-Key was already used for a different request"))
+				case existing.InFlight():
+					w.Header().Set("Retry-After", "1")
+					problem.WriteError(w, r, problem.Conflict("A request with this Idempotency-Key is in progress"))
+				default:
+					replay(w, existing)
+				}
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w}
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				// The handler panicked or failed, let the client retry
+				ctx := context.WithoutCancel(r.Context())
+				if err := store.Release(ctx, key); err != nil {
+					log.Error().Err(err).Msg("Failed to release idempotency key")
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if !storable(status) {
+				return
+			}
+
+			err = store.Complete(context.WithoutCancel(r.Context()), key, idempotency.Record{
+				RequestHash: requestHash,
+				Status:      status,
+				Header:      rec.header,
+				Body:        rec.body.Bytes(),
+				ExpiresAt:   time.Now().Add(opts.TTL),
+			})
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to store idempotent response")
+				return
+			}
+			completed = true
+		})
+	}
+
+}
+
+// storable reports whether a response with status is final for the request.
+// Server errors, timeouts and rate limiting are transient, retries must run.
+func storable(status int) bool {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return false
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return false
+	default:
+		return true
+	}
+}
+
+// hashRequest fingerprints the method, path and body of r, restoring the body
+// for the handler. Bodies over the body limit are hashed up to the limit, the
+// handler rejects them anyway.
+func hashRequest(r *http.Request) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\x00"))
+
+	if r.Body != nil {
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, io.LimitReader(r.Body, utils.MaxBodyBytes(r.Context())+1)); err != nil {
+			return "", err
+		}
+		h.Write(buf.Bytes())
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(&buf, r.Body), r.Body}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// replay writes a stored response
+func replay(w http.ResponseWriter, rec idempotency.Record) {
+	for name, values := range rec.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	_, _ = w.Write(rec.Body)
+}
+
+// recordingWriter writes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+		rw.header = rw.ResponseWriter.Header().Clone()
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
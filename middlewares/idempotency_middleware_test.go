@@ -0,0 +1,118 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/idempotency"
+)
+
+func TestIdempotency(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	blocking := make(chan struct{})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "block" {
+			close(started)
+			<-blocking
+		}
+		n := calls.Add(1)
+		if string(body) == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Call", string(rune('0'+n)))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	})
+	mw := Idempotency(idempotency.NewMemoryStore(), IdempotencyOptions{})(handler)
+
+	send := func(key, body string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		if remoteAddr != "" {
+			req.RemoteAddr = remoteAddr
+		}
+		rr := httptest.NewRecorder()
+		mw.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The first request runs and a retry replays its response
+	first := send("a", "one", "")
+	retry := send("a", "one", "")
+	if first.Code != http.StatusCreated || retry.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 twice, got %v and %v", first.Code, retry.Code)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", calls.Load())
+	}
+	if retry.Body.String() != "one" || retry.Header().Get("X-Call") != "1" || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("Unexpected replay: %v %q %v", retry.Code, retry.Body.String(), retry.Header())
+	}
+
+	// Reusing the key with another body is rejected
+	if rr := send("a", "two", ""); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a reused key, got %v", rr.Code)
+	}
+
+	// Keys are scoped to the client
+	if rr := send("a", "one", "10.0.0.1:1234"); rr.Code != http.StatusCreated || rr.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("Expected another client to run its own request, got %v", rr.Code)
+	}
+
+	// Unverified identity headers do not change the scope
+	req := httptest.NewRequest("POST", "/items", strings.NewReader("one"))
+	req.Header.Set(IdempotencyKeyHeader, "a")
+	req.Header.Set("X-API-USER", "mallory")
+	claimed := httptest.NewRecorder()
+	mw.ServeHTTP(claimed, req)
+	if claimed.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("Expected the response of the same IP to be replayed, got %v", claimed.Code)
+	}
+
+	// Requests without a key always run
+	before := calls.Load()
+	send("", "one", "")
+	send("", "one", "")
+	if calls.Load() != before+2 {
+		t.Errorf("Expected requests without a key to run")
+	}
+
+	// Server errors are not stored
+	if rr := send("b", "fail", ""); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %v", rr.Code)
+	}
+	before = calls.Load()
+	send("b", "fail", "")
+	if calls.Load() != before+1 {
+		t.Errorf("Expected a failed request to run again")
+	}
+
+	// A duplicate arriving while the first request is in flight conflicts
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		send("c", "block", "")
+	}()
+	<-started
+	rr := send("c", "block", "")
+	if rr.Code != http.StatusConflict || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 409 with Retry-After while in flight, got %v", rr.Code)
+	}
+	close(blocking)
+	<-done
+
+	// Once completed the duplicate is replayed
+	if rr := send("c", "block", ""); rr.Code != http.StatusCreated || rr.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("Expected replay after completion, got %v", rr.Code)
+	}
+}
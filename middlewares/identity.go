@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+type verifiedKeyContextKey struct{}
+
+// withVerifiedKey returns r with the hash of the API key verified by ApiKey
+func withVerifiedKey(r *http.Request, hashedKey string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), verifiedKeyContextKey{}, hashedKey))
+}
+
+// ClientIdentity identifies the caller of r for per-client state such as
+// idempotency keys and rate limits. Only verified identities are used: the
+// subject of a verified client certificate, then the API key checked by ApiKey,
+// then the remote IP as set by the RealIP middleware. Headers that are not
+// verified, such as X-API-USER or X-REQUEST-IDENTITY, are ignored so that
+// clients cannot choose their identity.
+func ClientIdentity(r *http.Request) string {
+	if subject := ClientCertSubject(r); subject != "" {
+		return "cert:" + subject
+	}
+	if key, ok := r.Context().Value(verifiedKeyContextKey{}).(string); ok && key != "" {
+		return "key:" + key
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ClientCertSubject returns the subject of the client certificate verified
+// during the mutual TLS handshake of r, or an empty string. Certificates that
+// were presented but not verified are ignored.
+func ClientCertSubject(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.String()
+}
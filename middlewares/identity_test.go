@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	testApiKey = "identity-key"
+	testSalt   = "identity-salt"
+	// testHashedKey is the hex sha256 of testSalt followed by testApiKey
+	testHashedKey = "7ef9e9de5843f7cd874b0cc9688489087c545b54cb8b7326cdf090f54346bdd7"
+)
+
+func TestClientIdentity(t *testing.T) {
+	verified := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "billing", Organization: []string{"Lexicon"}}}}},
+	}
+	unverified := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "billing"}}},
+	}
+
+	tests := []struct {
+		name     string
+		tls      *tls.ConnectionState
+		headers  map[string]string
+		apiKey   bool
+		expected string
+	}{
+		{name: "Verified client certificate", tls: verified, headers: map[string]string{"X-API-USER": "alice"}, expected: "cert:CN=billing,O=Lexicon"},
+		{name: "Unverified client certificate", tls: unverified, expected: "ip:192.0.2.1"},
+		{name: "Verified API key", headers: map[string]string{"X-API-USER": "alice"}, apiKey: true, expected: "key:" + testHashedKey},
+		{name: "Unverified API user", headers: map[string]string{"X-API-USER": "alice"}, expected: "ip:192.0.2.1"},
+		{name: "Unverified request identity", headers: map[string]string{"X-REQUEST-IDENTITY": "app"}, expected: "ip:192.0.2.1"},
+		{name: "Remote address", expected: "ip:192.0.2.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.TLS = tc.tls
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			var got string
+			identify := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIdentity(r)
+			})
+			if tc.apiKey {
+				req.Header.Set("X-REQUEST-IDENTITY", "app")
+				req.Header.Set("X-API-KEY", testApiKey)
+				ApiKey(testHashedKey, testSalt)(identify).ServeHTTP(httptest.NewRecorder(), req)
+			} else {
+				identify.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			if got != tc.expected {
+				t.Errorf("ClientIdentity() = %s, want %s", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+)
+
+// LimitsOptions configures the Limits middleware of a route group
+type LimitsOptions struct {
+	// RequestTimeout cancels the request context after the duration, zero
+	// for no timeout. It should be shorter than WriteTimeout so handlers can
+	// still answer with a 504.
+	RequestTimeout time.Duration
+	// WriteTimeout replaces the write timeout of the server for the route
+	// group, zero for no write deadline as needed by streaming endpoints
+	WriteTimeout time.Duration
+	// MaxBodyBytes limits the request body, zero for no limit
+	MaxBodyBytes int64
+}
+
+// Limits applies the timeouts and body size limit of a route group. Bodies
+// announced larger than the limit are rejected with a 413 problem before the
+// handler runs; larger bodies without a Content-Length fail while reading.
+func Limits(opts LimitsOptions) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			// The server write deadline was set when the request was read,
+			// ResponseController moves it for this request only
+			var deadline time.Time
+			if opts.WriteTimeout > 0 {
+				deadline = time.Now().Add(opts.WriteTimeout)
+			}
+			_ = http.NewResponseController(w).SetWriteDeadline(deadline)
+
+			if opts.MaxBodyBytes > 0 && r.Body != nil {
+				if r.ContentLength > opts.MaxBodyBytes {
+					problem.WriteError(w, r, problem.PayloadTooLarge(fmt.Sprintf("Request body must not be larger than %d bytes", opts.MaxBodyBytes)))
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, opts.MaxBodyBytes)
+				r = r.WithContext(utils.ContextWithMaxBodyBytes(r.Context(), opts.MaxBodyBytes))
+			}
+
+			if opts.RequestTimeout > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), opts.RequestTimeout)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+
+}
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+)
+
+func TestLimits(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Errorf("Expected the request context to have a deadline")
+		}
+		if n := utils.MaxBodyBytes(r.Context()); n != 8 {
+			t.Errorf("Expected body limit 8 in the context, got %d", n)
+		}
+		if _, err := io.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := Limits(LimitsOptions{RequestTimeout: time.Second, WriteTimeout: time.Second, MaxBodyBytes: 8})(handler)
+
+	tests := []struct {
+		name           string
+		body           string
+		chunked        bool
+		expectedStatus int
+	}{
+		{name: "Body within the limit", body: "small", expectedStatus: http.StatusOK},
+		{name: "Announced body over the limit", body: "far too large", expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "Streamed body over the limit", body: "far too large", chunked: true, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+			if tc.chunked {
+				req.ContentLength = -1
+			}
+			rr := httptest.NewRecorder()
+			mw.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatus)
+			}
+		})
+	}
+}
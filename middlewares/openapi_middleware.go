@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/openapi"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+)
+
+// OpenAPIOptions configures the OpenAPI middleware
+type OpenAPIOptions struct {
+	// Requests rejects requests that do not match the spec with a problem
+	// listing the violations
+	Requests bool
+	// Responses replaces responses that do not match the spec with a 500
+	// problem. Responses are buffered, so it is meant for development.
+	Responses bool
+}
+
+// OpenAPI validates requests, and optionally responses, against the
+// operations documented in the spec of v. Routes missing from the spec are
+// passed through, the route table check reports them.
+func OpenAPI(v *openapi.Validator, opts OpenAPIOptions) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			endpoint, ok := v.Find(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if opts.Requests {
+				if err := endpoint.ValidateRequest(r.Context(), r); err != nil {
+					problem.WriteError(w, r, err)
+					return
+				}
+			}
+
+			// WebSocket upgrades and event streams cannot be buffered
+			if !opts.Responses || r.Header.Get("Upgrade") != "" || r.Header.Get("Accept") == "text/event-stream" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(bw, r)
+
+			if err := endpoint.ValidateResponse(r.Context(), r, bw.status, w.Header(), bw.body.Bytes()); err != nil {
+				h := w.Header()
+				h.Del("Content-Length")
+				h.Del("ETag")
+				// Response validation is meant for development, so the
+				// violation is shown rather than only logged
+				problem.WriteError(w, r, problem.Internal(err.Error(), err))
+				return
+			}
+
+			w.WriteHeader(bw.status)
+			_, _ = w.Write(bw.body.Bytes())
+		})
+	}
+
+}
+
+// bufferedWriter holds a response until it is validated
+type bufferedWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	if !bw.wroteHeader {
+		bw.status = status
+		bw.wroteHeader = true
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	bw.WriteHeader(http.StatusOK)
+	return bw.body.Write(b)
+}
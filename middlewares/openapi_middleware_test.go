@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/openapi"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const testOpenAPISpec = `{
+	"swagger": "2.0",
+	"basePath": "/v1",
+	"consumes": ["application/json"],
+	"produces": ["application/json"],
+	"paths": {
+		"/users": {
+			"post": {
+				"parameters": [{"in": "body", "name": "request", "required": true, "schema": {"$ref": "#/definitions/UserRequest"}}],
+				"responses": {"201": {"description": "Created", "schema": {"$ref": "#/definitions/User"}}}
+			}
+		},
+		"/users/{id}": {
+			"get": {
+				"parameters": [{"in": "path", "name": "id", "required": true, "type": "integer"}],
+				"responses": {"200": {"description": "OK", "schema": {"$ref": "#/definitions/User"}}}
+			}
+		}
+	},
+	"definitions": {
+		"UserRequest": {
+			"type": "object",
+			"required": ["name", "email"],
+			"properties": {
+				"name": {"type": "string", "minLength": 1},
+				"email": {"type": "string"}
+			}
+		},
+		"User": {
+			"type": "object",
+			"required": ["id", "name"],
+			"properties": {
+				"id": {"type": "integer"},
+				"name": {"type": "string"}
+			}
+		}
+	}
+}`
+
+func TestOpenAPI(t *testing.T) {
+	validator, err := openapi.NewValidator([]byte(testOpenAPISpec))
+	if err != nil {
+		t.Fatalf("NewValidator() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		contentType    string
+		body           string
+		response       string
+		expectedStatus int
+		expectedField  string
+	}{
+		{"valid request", http.MethodPost, "/v1/users", "application/json", `{"name":"Jane","email":"jane@example.com"}`, `{"id":1,"name":"Jane"}`, http.StatusCreated, ""},
+		{"missing field", http.MethodPost, "/v1/users", "application/json", `{"name":"Jane"}`, `{"id":1,"name":"Jane"}`, http.StatusBadRequest, "body.email"},
+		{"wrong type", http.MethodPost, "/v1/users", "application/json", `{"name":1,"email":"jane@example.com"}`, `{"id":1,"name":"Jane"}`, http.StatusBadRequest, "body.name"},
+		{"undocumented json content type", http.MethodPost, "/v1/users", "application/merge-patch+json", `{}`, `{"id":1,"name":"Jane"}`, http.StatusUnsupportedMediaType, ""},
+		{"body left to the handler", http.MethodPost, "/v1/users", "application/xml", `<user/>`, `{"id":1,"name":"Jane"}`, http.StatusCreated, ""},
+		{"invalid path parameter", http.MethodGet, "/v1/users/abc", "", "", `{"id":1,"name":"Jane"}`, http.StatusBadRequest, "id"},
+		{"invalid response", http.MethodGet, "/v1/users/1", "", "", `{"id":"1"}`, http.StatusInternalServerError, ""},
+		{"undocumented route", http.MethodGet, "/v1/reports", "", "", `{}`, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := http.StatusOK
+			if tt.method == http.MethodPost {
+				status = http.StatusCreated
+			}
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(tt.response))
+			}
+
+			r := chi.NewRouter()
+			r.Use(OpenAPI(validator, OpenAPIOptions{Requests: true, Responses: true}))
+			r.Post("/v1/users", handler)
+			r.Get("/v1/users/{id}", handler)
+			r.Get("/v1/reports", handler)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v (%s)", status, tt.expectedStatus, rr.Body.String())
+			}
+			if tt.expectedField == "" {
+				return
+			}
+
+			var p problem.Problem
+			if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+				t.Fatalf("Failed to decode problem: %v", err)
+			}
+			for _, fe := range p.Errors {
+				if fe.Field == tt.expectedField {
+					return
+				}
+			}
+			t.Errorf("Problem does not report field %s: %+v", tt.expectedField, p.Errors)
+		})
+	}
+}
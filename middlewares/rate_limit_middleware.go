@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/ratelimit"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RateLimitOptions configures the RateLimit middleware
+type RateLimitOptions struct {
+	// Name identifies the route group, each group has its own budget
+	Name string
+	// Limit is the budget of each client in the group
+	Limit ratelimit.Limit
+	// Key identifies the client, ClientIdentity by default. It must return
+	// verified identities only, otherwise clients could reset their budget by
+	// changing a header.
+	Key func(r *http.Request) string
+}
+
+// RateLimit limits the requests of each client to opts.Limit and reports the
+// state of its budget in RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset
+// and RateLimit-Policy headers. Requests over the limit get a 429 problem with
+// Retry-After. When the store fails, requests are let through.
+func RateLimit(store ratelimit.Store, opts RateLimitOptions) func(next http.Handler) http.Handler {
+	if opts.Key == nil {
+		opts.Key = ClientIdentity
+	}
+	policy := strconv.Itoa(opts.Limit.Requests) + ";w=" + strconv.Itoa(seconds(opts.Limit.Window))
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			result, err := store.Allow(r.Context(), opts.Name+"\x00"+opts.Key(r), opts.Limit, time.Now())
+			if err != nil {
+				log.Error().Err(err).Str("group", opts.Name).Msg("Rate limit store failed, allowing request")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(result.Limit.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(result.ResetAfter)))
+			h.Set("RateLimit-Policy", policy)
+
+			if !result.Allowed {
+				h.Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+				problem.WriteError(w, r, problem.TooManyRequests("Rate limit exceeded, retry later"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+
+}
+
+// seconds rounds d up to whole seconds, as used by the rate limit headers
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,111 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	limit := ratelimit.Limit{Requests: 2, Window: time.Minute}
+	mw := RateLimit(ratelimit.NewMemoryStore(), RateLimitOptions{Name: "users", Limit: limit})(handler)
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		mw.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name              string
+		remoteAddr        string
+		expectedStatus    int
+		expectedRemaining string
+	}{
+		{name: "First request", remoteAddr: "10.0.0.1:1000", expectedStatus: http.StatusOK, expectedRemaining: "1"},
+		{name: "Second request", remoteAddr: "10.0.0.1:1001", expectedStatus: http.StatusOK, expectedRemaining: "0"},
+		{name: "Over the limit", remoteAddr: "10.0.0.1:1002", expectedStatus: http.StatusTooManyRequests, expectedRemaining: "0"},
+		{name: "Another client", remoteAddr: "10.0.0.2:1000", expectedStatus: http.StatusOK, expectedRemaining: "1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := send(tc.remoteAddr)
+
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatus)
+			}
+			if got := rr.Header().Get("RateLimit-Remaining"); got != tc.expectedRemaining {
+				t.Errorf("Expected RateLimit-Remaining %s, got %s", tc.expectedRemaining, got)
+			}
+			if got := rr.Header().Get("RateLimit-Limit"); got != "2" {
+				t.Errorf("Expected RateLimit-Limit 2, got %s", got)
+			}
+			if got := rr.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+				t.Errorf("Expected RateLimit-Policy 2;w=60, got %s", got)
+			}
+
+			if tc.expectedStatus == http.StatusTooManyRequests {
+				if got := rr.Header().Get("Retry-After"); got != "30" {
+					t.Errorf("Expected Retry-After 30, got %s", got)
+				}
+				if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+					t.Errorf("Expected content type %s, got %s", problem.ContentType, ct)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitIdentityHeaders(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	limit := ratelimit.Limit{Requests: 1, Window: time.Minute}
+	mw := RateLimit(ratelimit.NewMemoryStore(), RateLimitOptions{Name: "users", Limit: limit})(handler)
+	authenticated := ApiKey(testHashedKey, testSalt)(mw)
+
+	send := func(h http.Handler, headers map[string]string) int {
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := send(mw, nil); code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+
+	// Claiming another identity does not reset the budget of the IP
+	for _, headers := range []map[string]string{
+		{"X-API-USER": "alice"},
+		{"X-REQUEST-IDENTITY": "another-host"},
+	} {
+		if code := send(mw, headers); code != http.StatusTooManyRequests {
+			t.Errorf("Handler returned wrong status code for %v: got %v want %v", headers, code, http.StatusTooManyRequests)
+		}
+	}
+
+	// A verified API key has its own budget, whatever identity it claims
+	key := map[string]string{"X-API-KEY": testApiKey, "X-REQUEST-IDENTITY": "app"}
+	if code := send(authenticated, key); code != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+	key["X-REQUEST-IDENTITY"] = "another-host"
+	if code := send(authenticated, key); code != http.StatusTooManyRequests {
+		t.Errorf("Handler returned wrong status code: got %v want %v", code, http.StatusTooManyRequests)
+	}
+}
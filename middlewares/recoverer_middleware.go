@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+)
+
+// Recoverer recovers from panics, logs them with the stack trace and responds
+// with a 500 problem
+func Recoverer() func(next http.Handler) http.Handler {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+				// Let the server abort the response as it would without this middleware
+				if rvr == http.ErrAbortHandler {
+					panic(rvr)
+				}
+
+				log.Error().
+					Interface("panic", rvr).
+					Str("method", r.Method).
+					Str("path", r.URL.Path).
+					Str("request_id", middleware.GetReqID(r.Context())).
+					Bytes("stack", debug.Stack()).
+					Msg("Recovered from panic")
+
+				if r.Header.Get("Connection") != "Upgrade" {
+					middlewareError(w, r, http.StatusInternalServerError, "An unexpected error occurred")
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+
+}
@@ -13,7 +13,7 @@ func RequestSignature(salt string) func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 			if len(salt) <= 0 {
-				middlewareError(w, http.StatusInternalServerError, "Internal Server Error", "No Salt Found")
+				middlewareError(w, r, http.StatusInternalServerError, "No Salt Found")
 				return
 			}
 
@@ -22,16 +22,16 @@ func RequestSignature(salt string) func(next http.Handler) http.Handler {
 			signature := r.Header.Get("X-REQUEST-SIGNATURE")
 
 			if len(accessTime) <= 0 {
-				middlewareError(w, http.StatusBadRequest, "Bad Request", "Missing X-ACCESS-TIME")
+				middlewareError(w, r, http.StatusBadRequest, "Missing X-ACCESS-TIME")
 				return
 			}
 
 			if len(apiKey) <= 0 {
-				middlewareError(w, http.StatusForbidden, "Forbidden", "Missing X-API-KEY")
+				middlewareError(w, r, http.StatusForbidden, "Missing X-API-KEY")
 				return
 			}
 			if len(signature) <= 0 {
-				middlewareError(w, http.StatusForbidden, "Forbidden", "Missing X-REQUEST-SIGNATURE")
+				middlewareError(w, r, http.StatusForbidden, "Missing X-REQUEST-SIGNATURE")
 				return
 			}
 			hash := sha256.New()
@@ -40,7 +40,7 @@ func RequestSignature(salt string) func(next http.Handler) http.Handler {
 			hashedSignature := hex.EncodeToString(hash.Sum(nil))
 
 			if signature != hashedSignature {
-				middlewareError(w, http.StatusBadRequest, "Bad Request", "Invalid X-API-KEY Header")
+				middlewareError(w, r, http.StatusBadRequest, "Invalid X-API-KEY Header")
 				return
 			}
 
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key text PRIMARY KEY,
+    request_hash text NOT NULL,
+    status integer NOT NULL DEFAULT 0,
+    header jsonb,
+    body bytea,
+    expires_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL schema migrations
+package migrations
+
+import "embed"
+
+// FS contains the SQL migrations, applied in lexical file name order
+//
+//go:embed *.sql
+var FS embed.FS
@@ -1,11 +1,38 @@
 -- name: GetUser :one
 SELECT * FROM users WHERE id = $1;
 
+-- name: GetUserForUpdate :one
+SELECT * FROM users WHERE id = $1 FOR UPDATE;
+
 -- name: CreateUser :one
 INSERT INTO users (name, email) VALUES ($1, $2) RETURNING *;
 
 -- name: UpdateUser :one
-UPDATE users SET name = $1, email = $2 WHERE id = $3 RETURNING *;
+UPDATE users SET name = $1, email = $2, updated_at = NOW() WHERE id = $3 RETURNING *;
 
 -- name: DeleteUser :exec
 DELETE FROM users WHERE id = $1;
+
+-- name: ReserveIdempotencyKey :one
+INSERT INTO idempotency_keys (key, request_hash, status, header, body, expires_at)
+VALUES ($1, $2, 0, NULL, NULL, $3)
+ON CONFLICT (key) DO UPDATE SET
+    request_hash = EXCLUDED.request_hash,
+    status = 0,
+    header = NULL,
+    body = NULL,
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= NOW()
+RETURNING key;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys WHERE key = $1;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys SET status = $2, header = $3, body = $4, expires_at = $5 WHERE key = $1;
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE key = $1 AND status = 0;
+
+-- name: PurgeIdempotencyKeys :exec
+DELETE FROM idempotency_keys WHERE expires_at <= NOW();
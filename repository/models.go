@@ -10,9 +10,19 @@ import (
 	"github.com/google/uuid"
 )
 
+type IdempotencyKey struct {
+	Key         string
+	RequestHash string
+	Status      int32
+	Header      []byte
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
 type User struct {
 	ID        uuid.UUID
 	Name      string
 	Email     string
 	CreatedAt time.Time
+	UpdatedAt time.Time
 }
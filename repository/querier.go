@@ -0,0 +1,26 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type Querier interface {
+	CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	GetIdempotencyKey(ctx context.Context, key string) (IdempotencyKey, error)
+	GetUser(ctx context.Context, id uuid.UUID) (User, error)
+	GetUserForUpdate(ctx context.Context, id uuid.UUID) (User, error)
+	PurgeIdempotencyKeys(ctx context.Context) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	ReserveIdempotencyKey(ctx context.Context, arg ReserveIdempotencyKeyParams) (string, error)
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+}
+
+var _ Querier = (*Queries)(nil)
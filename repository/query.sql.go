@@ -7,12 +7,36 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const completeIdempotencyKey = `-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys SET status = $2, header = $3, body = $4, expires_at = $5 WHERE key = $1
+`
+
+type CompleteIdempotencyKeyParams struct {
+	Key       string
+	Status    int32
+	Header    []byte
+	Body      []byte
+	ExpiresAt time.Time
+}
+
+func (q *Queries) CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error {
+	_, err := q.db.Exec(ctx, completeIdempotencyKey,
+		arg.Key,
+		arg.Status,
+		arg.Header,
+		arg.Body,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, name, email, created_at
+INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, name, email, created_at, updated_at
 `
 
 type CreateUserParams struct {
@@ -28,6 +52,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Name,
 		&i.Email,
 		&i.CreatedAt,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
@@ -41,8 +66,26 @@ func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT key, request_hash, status, header, body, expires_at FROM idempotency_keys WHERE key = $1
+`
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, key string) (IdempotencyKey, error) {
+	row := q.db.QueryRow(ctx, getIdempotencyKey, key)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Key,
+		&i.RequestHash,
+		&i.Status,
+		&i.Header,
+		&i.Body,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, name, email, created_at FROM users WHERE id = $1
+SELECT id, name, email, created_at, updated_at FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
@@ -53,12 +96,74 @@ func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Name,
 		&i.Email,
 		&i.CreatedAt,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
+const getUserForUpdate = `-- name: GetUserForUpdate :one
+SELECT id, name, email, created_at, updated_at FROM users WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetUserForUpdate(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRow(ctx, getUserForUpdate, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const purgeIdempotencyKeys = `-- name: PurgeIdempotencyKeys :exec
+DELETE FROM idempotency_keys WHERE expires_at <= NOW()
+`
+
+func (q *Queries) PurgeIdempotencyKeys(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, purgeIdempotencyKeys)
+	return err
+}
+
+const releaseIdempotencyKey = `-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE key = $1 AND status = 0
+`
+
+func (q *Queries) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := q.db.Exec(ctx, releaseIdempotencyKey, key)
+	return err
+}
+
+const reserveIdempotencyKey = `-- name: ReserveIdempotencyKey :one
+INSERT INTO idempotency_keys (key, request_hash, status, header, body, expires_at)
+VALUES ($1, $2, 0, NULL, NULL, $3)
+ON CONFLICT (key) DO UPDATE SET
+    request_hash = EXCLUDED.request_hash,
+    status = 0,
+    header = NULL,
+    body = NULL,
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= NOW()
+RETURNING key
+`
+
+type ReserveIdempotencyKeyParams struct {
+	Key         string
+	RequestHash string
+	ExpiresAt   time.Time
+}
+
+func (q *Queries) ReserveIdempotencyKey(ctx context.Context, arg ReserveIdempotencyKeyParams) (string, error) {
+	row := q.db.QueryRow(ctx, reserveIdempotencyKey, arg.Key, arg.RequestHash, arg.ExpiresAt)
+	var key string
+	err := row.Scan(&key)
+	return key, err
+}
+
 const updateUser = `-- name: UpdateUser :one
-UPDATE users SET name = $1, email = $2 WHERE id = $3 RETURNING id, name, email, created_at
+UPDATE users SET name = $1, email = $2, updated_at = NOW() WHERE id = $3 RETURNING id, name, email, created_at, updated_at
 `
 
 type UpdateUserParams struct {
@@ -75,6 +180,7 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Name,
 		&i.Email,
 		&i.CreatedAt,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+	"github.com/LexiconIndonesia/go-http-service-template/common/openapi"
+	"github.com/LexiconIndonesia/go-http-service-template/docs"
+
+	"github.com/rs/zerolog"
+)
+
+// routeTable builds every module with placeholder dependencies, without
+// connecting to anything, and returns the routes of the server and the spec
+func routeTable(cfg config) ([]openapi.Route, *openapi.Spec, error) {
+	// All modules are mounted, whatever the configuration disables
+	cfg.Modules.Disabled = nil
+	cfg.RateLimit.Store = "memory"
+
+	container := &module.Container{
+		Store:       db.NewFake(),
+		Nats:        &messaging.NatsClient{},
+		Environment: cfg.App.Environment,
+		Gates:       cfg.App.gates(),
+	}
+	server, err := NewAppHttpServer(cfg, container)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := server.setupRoute(); err != nil {
+		return nil, nil, err
+	}
+
+	routes, err := openapi.Walk(server.router)
+	if err != nil {
+		return nil, nil, err
+	}
+	spec, err := openapi.Load([]byte(docs.SwaggerInfo.ReadDoc()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return routes, spec, nil
+}
+
+// runRoutes prints the route table and, with -check, fails when the routes
+// and the OpenAPI spec drifted apart. It returns the exit code.
+func runRoutes(cfg config, args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("routes", flag.ContinueOnError)
+	fs.SetOutput(out)
+	check := fs.Bool("check", false, "exit with status 1 when the routes and the OpenAPI spec differ")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// Building the server logs every module and route
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	routes, spec, err := routeTable(cfg)
+	zerolog.SetGlobalLevel(level)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+
+	drift := spec.Compare(routes)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATH\tOPENAPI")
+	for _, route := range routes {
+		status := "documented"
+		switch {
+		case !spec.Covers(route):
+			status = "-"
+		case slices.Contains(drift.Undocumented, route):
+			status = "UNDOCUMENTED"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", route.Method, route.Path, status)
+	}
+	for _, route := range drift.Unmounted {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", route.Method, route.Path, "NOT MOUNTED")
+	}
+	w.Flush()
+
+	if drift.Empty() {
+		return 0
+	}
+	fmt.Fprintf(out, "\n%d undocumented and %d unmounted routes\n", len(drift.Undocumented), len(drift.Unmounted))
+	if *check {
+		return 1
+	}
+	return 0
+}
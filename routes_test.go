@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestRoutesMatchOpenAPI fails when a route is mounted without being
+// documented, or documented without being mounted. Regenerate the spec with
+// swag init after changing the routes or their annotations.
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	routes, spec, err := routeTable(defaultConfig())
+	if err != nil {
+		t.Fatalf("routeTable() error = %v", err)
+	}
+
+	drift := spec.Compare(routes)
+	for _, route := range drift.Undocumented {
+		t.Errorf("Route %s is not documented in the OpenAPI spec", route)
+	}
+	for _, route := range drift.Unmounted {
+		t.Errorf("Route %s is documented but not mounted", route)
+	}
+}
@@ -3,137 +3,432 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/LexiconIndonesia/go-http-service-template/common/db"
-	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
-	helloPkg "github.com/LexiconIndonesia/go-http-service-template/features/hello"
-	messagingPkg "github.com/LexiconIndonesia/go-http-service-template/features/messaging"
-	userPkg "github.com/LexiconIndonesia/go-http-service-template/features/user"
+	"github.com/LexiconIndonesia/go-http-service-template/common/buildinfo"
+	"github.com/LexiconIndonesia/go-http-service-template/common/certs"
+	"github.com/LexiconIndonesia/go-http-service-template/common/idempotency"
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+	"github.com/LexiconIndonesia/go-http-service-template/common/openapi"
+	"github.com/LexiconIndonesia/go-http-service-template/common/problem"
+	"github.com/LexiconIndonesia/go-http-service-template/common/ratelimit"
+	"github.com/LexiconIndonesia/go-http-service-template/common/utils"
+	"github.com/LexiconIndonesia/go-http-service-template/middlewares"
+	"github.com/LexiconIndonesia/go-http-service-template/repository"
 
-	_ "github.com/LexiconIndonesia/go-http-service-template/docs"
+	"github.com/LexiconIndonesia/go-http-service-template/docs"
+
+	// Features register their module from init
+	_ "github.com/LexiconIndonesia/go-http-service-template/features/hello"
+	_ "github.com/LexiconIndonesia/go-http-service-template/features/messaging"
+	_ "github.com/LexiconIndonesia/go-http-service-template/features/user"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/nats-io/nats.go/micro"
 	"github.com/rs/zerolog/log"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
 
 type AppHttpServer struct {
-	router     *chi.Mux
-	cfg        config
-	server     *http.Server
-	db         *db.DB
-	natsClient *messaging.NatsClient
+	router    *chi.Mux
+	cfg       config
+	server    *http.Server
+	container *module.Container
+	modules   []module.Loaded
+	// validate, idempotent and etag are the middlewares of all modules after
+	// those of their route group
+	validate   chi.Middlewares
+	idempotent func(http.Handler) http.Handler
+	etag       func(http.Handler) http.Handler
+	rateLimits ratelimit.Store
+	certs      *certs.Reloader
+	stopWatch  context.CancelFunc
+	errs       chan error
 }
 
-func NewAppHttpServer(cfg config) (*AppHttpServer, error) {
+// NewAppHttpServer creates the server, its modules get their dependencies
+// from container
+func NewAppHttpServer(cfg config, container *module.Container) (*AppHttpServer, error) {
 	r := chi.NewRouter()
 
-	// Basic CORS
-	// for more ideas, see: https://developer.github.com/v3/#cross-origin-resource-sharing
+	// CORS, configured with the CORS_* variables
 	r.Use(cors.Handler(cors.Options{
-		// AllowedOrigins:   []string{"https://foo.com"}, // Use this to allow specific origin hosts
-		// AllowedOrigins: []string{"https://bo.lexicon.id", "http://localhost:3000"},
-		AllowedOrigins: []string{"*"},
-		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-KEY", "X-ACCESS-TIME", "X-REQUEST-SIGNATURE", "X-API-USER", "X-REQUEST-IDENTITY"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300, // Maximum value not ignored by any of major browsers
+		AllowedOrigins:   cfg.Http.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.Http.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.Http.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.Http.CORS.ExposedHeaders,
+		AllowCredentials: cfg.Http.CORS.AllowCredentials,
+		MaxAge:           int(cfg.Http.CORS.MaxAge),
 	}))
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+	r.Use(middlewares.Recoverer())
+	r.Use(middlewares.Compress(middlewares.CompressOptions{}))
 
-	// Set a timeout value on the request context (ctx), that will signal
-	// through ctx.Done() that the request has timed out and further
-	// processing should be stopped.
-	r.Use(middleware.Timeout(2 * time.Minute))
+	// Render unmatched routes and methods as problems
+	r.NotFound(problem.NotFoundHandler)
+	r.MethodNotAllowed(problem.MethodNotAllowedHandler)
 
 	server := &AppHttpServer{
-		router: r,
-		cfg:    cfg,
+		router:    r,
+		cfg:       cfg,
+		container: container,
+		errs:      make(chan error, 1),
 	}
-	return server, nil
-}
 
-// SetDB sets the database dependency
-func (s *AppHttpServer) SetDB(db *db.DB) {
-	s.db = db
-}
+	// Load certificates up front so invalid files fail at startup
+	if cfg.Listen.TLS.Enabled() {
+		clientAuth, err := certs.ParseClientAuth(cfg.Listen.TLS.ClientAuth)
+		if err != nil {
+			return nil, err
+		}
+		server.certs, err = certs.NewReloader(certs.Config{
+			CertFile:     cfg.Listen.TLS.CertFile,
+			KeyFile:      cfg.Listen.TLS.KeyFile,
+			ClientCAFile: cfg.Listen.TLS.ClientCAFile,
+			ClientAuth:   clientAuth,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS files: %w", err)
+		}
+	}
 
-// SetNatsClient sets the NATS client dependency
-func (s *AppHttpServer) SetNatsClient(client *messaging.NatsClient) {
-	s.natsClient = client
+	return server, nil
 }
 
-func (s *AppHttpServer) setupRoute() {
+// setupRoute builds the registered modules and mounts the enabled ones
+func (s *AppHttpServer) setupRoute() error {
 	r := s.router
-	// cfg := s.cfg
 
-	// Check if dependencies are set
-	if s.db == nil {
-		log.Warn().Msg("DB dependency not set, using legacy global access")
-	}
-
-	if s.natsClient == nil {
-		log.Warn().Msg("NATS client dependency not set")
+	modules, err := module.Default().Build(s.container, s.cfg.Modules.enabled)
+	if err != nil {
+		return err
 	}
-
-	// Create the module with dependency injection
-	helloHandler := helloPkg.NewHello(s.db, s.natsClient)
-	messagingHandler := messagingPkg.NewMessaging(s.natsClient)
-	userHandler := userPkg.NewUser(s.db)
+	s.modules = modules
 
 	// API Documentation with Swagger
 	r.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"), // The URL pointing to API definition
 	))
 
+	// Health of the dependencies and modules, for load balancers
+	r.Get("/healthz", s.health)
+
+	// Reject requests not matching the spec before they are stored
+	if s.cfg.OpenAPI.enabled() {
+		mw, err := s.openAPIValidation()
+		if err != nil {
+			return err
+		}
+		s.validate = append(s.validate, mw)
+	}
+
+	// Replay responses of retried POST requests with an Idempotency-Key
+	s.idempotent = middlewares.Idempotency(s.idempotencyStore(), middlewares.IdempotencyOptions{})
+
+	// Tag GET responses and answer If-None-Match with 304
+	s.etag = middlewares.ETag()
+
 	r.Route("/v1", func(r chi.Router) {
 		// r.Use(middlewares.AccessTime())
 		// r.Use(middlewares.ApiKey(cfg.BackendApiKey, cfg.ServerSalt))
 		// r.Use(middlewares.RequestSignature(cfg.ServerSalt))
 
-		// Mount routers directly following the module convention
-		r.Mount("/messaging", messagingHandler.Router())
-		r.Mount("/users", userHandler.Router())
+		// Modules use their name as route group
+		for _, m := range s.modules {
+			switch m.Status {
+			case module.StatusEnabled:
+				r.With(s.moduleMiddlewares(m.Name)...).Mount(m.Path, m.Module.Router())
+			case module.StatusUnavailable:
+				// Answer rather than 404 so clients see why the routes fail
+				r.Mount(m.Path, unavailable(m))
+				log.Warn().Str("module", m.Name).Any("missing", m.Missing).Msg("Module dependencies not available")
+			}
+			log.Info().Str("module", m.Name).Str("path", "/v1"+m.Path).Str("status", string(m.Status)).Msg("Module")
+		}
+	})
+
+	s.logRoutes()
+	return nil
+}
+
+// openAPIValidation returns the middleware validating requests and responses
+// against the embedded OpenAPI spec
+func (s *AppHttpServer) openAPIValidation() (func(http.Handler) http.Handler, error) {
+	validator, err := openapi.NewValidator([]byte(docs.SwaggerInfo.ReadDoc()))
+	if err != nil {
+		return nil, fmt.Errorf("loading OpenAPI spec: %w", err)
+	}
+
+	log.Info().Bool("requests", s.cfg.OpenAPI.ValidateRequests).Bool("responses", s.cfg.OpenAPI.ValidateResponses).Msg("Validating against the OpenAPI spec")
+	return middlewares.OpenAPI(validator, middlewares.OpenAPIOptions{
+		Requests:  s.cfg.OpenAPI.ValidateRequests,
+		Responses: s.cfg.OpenAPI.ValidateResponses,
+	}), nil
+}
 
-		// Use new module structure with DI for other routes
-		r.Mount("/module", helloHandler.Router())
+// natsService serves the operations of the modules as a NATS micro service,
+// with the schemas of the embedded OpenAPI spec
+func (s *AppHttpServer) natsService() (micro.Service, error) {
+	if s.container.Nats == nil || s.container.Nats.GetConn() == nil {
+		return nil, errors.New("NATS is not connected")
+	}
+	spec, err := openapi.Load([]byte(docs.SwaggerInfo.ReadDoc()))
+	if err != nil {
+		return nil, err
+	}
 
+	svc, err := module.Serve(s.container.Nats.GetConn(), s.modules, module.ServiceOptions{
+		Name:        s.cfg.Nats.ServiceName,
+		Version:     serviceVersion(buildinfo.Version),
+		Description: docs.SwaggerInfo.Title,
+		Prefix:      s.cfg.Nats.ServicePrefix,
+		BasePath:    "/v1",
+		Spec:        spec,
+		Middlewares: s.natsMiddlewares,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range svc.Info().Endpoints {
+		log.Info().Str("endpoint", e.Name).Str("subject", e.Subject).Str("route", e.Metadata["method"]+" "+e.Metadata["path"]).Msg("NATS endpoint")
+	}
+	return svc, nil
+}
+
+// serviceVersion returns version as the semantic version micro services
+// need, without the v prefix of tags
+func serviceVersion(version string) string {
+	version = strings.TrimPrefix(version, "v")
+	if version == "" || version == "dev" {
+		return "0.0.0-dev"
+	}
+	return version
+}
+
+// logRoutes logs the route table, with the feature and status of the routes
+// registered through the gates
+func (s *AppHttpServer) logRoutes() {
+	gated := map[string]module.GatedRoute{}
+	for _, route := range s.container.Gates.Routes() {
+		gated[route.Method+" /v1"+route.Pattern] = route
+	}
+
+	_ = chi.Walk(s.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		entry := log.Info().Str("method", method).Str("route", route)
+		if g, ok := gated[method+" "+route]; ok {
+			status := "enabled"
+			if !g.Enabled {
+				status = "disabled"
+			}
+			entry = entry.Str("feature", g.Feature).Str("status", status).Str("reason", g.Reason)
+		}
+		entry.Msg("Route")
+		return nil
+	})
+}
+
+// unavailable answers the routes of a module missing a dependency with 503
+func unavailable(m module.Loaded) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.WriteError(w, r, problem.Unavailable(fmt.Sprintf("The %s module is not available", m.Name)))
+	})
+}
+
+// HealthResponse reports the health of each dependency and module
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
 }
 
+// health checks the database, NATS and the modules implementing
+// module.HealthChecker, answering 503 when one of them fails
+func (s *AppHttpServer) health(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	checks := module.HealthCheck(ctx, s.modules)
+	if s.container.Store != nil {
+		checks["database"] = s.container.Store.Ping(ctx)
+	}
+	if s.container.Nats != nil {
+		checks["nats"] = nil
+		if conn := s.container.Nats.GetConn(); conn == nil || !conn.IsConnected() {
+			checks["nats"] = errors.New("not connected to NATS")
+		}
+	}
+
+	resp := HealthResponse{Status: "ok", Checks: map[string]string{}}
+	status := http.StatusOK
+	for name, err := range checks {
+		resp.Checks[name] = "ok"
+		if err != nil {
+			log.Warn().Err(err).Str("check", name).Msg("Health check failed")
+			resp.Checks[name] = err.Error()
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	utils.WriteJSON(w, status, resp)
+}
+
+// idempotencyStore returns a store shared by all instances of the service:
+// Postgres when available, then NATS KV, falling back to memory
+func (s *AppHttpServer) idempotencyStore() idempotency.Store {
+	if s.container.DB != nil {
+		return idempotency.NewPostgresStore(repository.New(s.container.DB.Pool))
+	}
+
+	if nc := s.container.Nats; nc != nil && nc.GetJetStream() != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// The bucket outlives the default record TTL
+		store, err := idempotency.NewKVStore(ctx, nc.GetJetStream(), idempotency.DefaultBucket, 25*time.Hour)
+		if err == nil {
+			return store
+		}
+		log.Warn().Err(err).Msg("Failed to create idempotency bucket")
+	}
+
+	log.Warn().Msg("No shared idempotency store, keeping Idempotency-Key responses in memory")
+	return idempotency.NewMemoryStore()
+}
+
+// moduleMiddlewares returns the middlewares of a module, which uses its name as
+// route group. The group applies its own limits before the shared middlewares,
+// so bodies are bounded before they are buffered.
+func (s *AppHttpServer) moduleMiddlewares(name string) chi.Middlewares {
+	mws := append(s.routeGroup(name), s.validate...)
+	return append(mws, s.idempotent, s.etag)
+}
+
+// natsMiddlewares returns the middlewares of a module served over NATS. They
+// leave out idempotency: NATS callers have no verified identity, so they would
+// share their keys and could replay the responses of each other.
+func (s *AppHttpServer) natsMiddlewares(name string) chi.Middlewares {
+	mws := append(s.routeGroup(name), s.validate...)
+	return append(mws, s.etag)
+}
+
+// routeGroup returns the middlewares of a route group: its timeouts and body
+// limit, then its rate limit when it has one
+func (s *AppHttpServer) routeGroup(group string) chi.Middlewares {
+	mws := chi.Middlewares{middlewares.Limits(s.cfg.Http.limits(group))}
+
+	limit := s.cfg.RateLimit.limitFor(group)
+	if !limit.Enabled() {
+		return mws
+	}
+
+	if s.rateLimits == nil {
+		s.rateLimits = s.rateLimitStore()
+	}
+	log.Info().Str("group", group).Str("limit", limit.String()).Msg("Rate limiting route group")
+	return append(mws, middlewares.RateLimit(s.rateLimits, middlewares.RateLimitOptions{Name: group, Limit: limit}))
+}
+
+// rateLimitStore returns the store configured by RATE_LIMIT_STORE: NATS KV to
+// share limits across instances, falling back to memory
+func (s *AppHttpServer) rateLimitStore() ratelimit.Store {
+	if s.cfg.RateLimit.Store != "nats" {
+		return ratelimit.NewMemoryStore()
+	}
+
+	if nc := s.container.Nats; nc != nil && nc.GetJetStream() != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Entries must outlive the longest window
+		ttl := s.cfg.RateLimit.Default.Window
+		for _, limit := range s.cfg.RateLimit.Groups {
+			ttl = max(ttl, limit.Window)
+		}
+		store, err := ratelimit.NewKVStore(ctx, nc.GetJetStream(), ratelimit.DefaultBucket, max(ttl, time.Minute))
+		if err == nil {
+			return store
+		}
+		log.Warn().Err(err).Msg("Failed to create rate limit bucket")
+	}
+
+	log.Warn().Msg("NATS unavailable, keeping rate limits in memory")
+	return ratelimit.NewMemoryStore()
+}
+
+// start listens on the configured address and serves requests in the
+// background. Listen failures are returned, later failures are reported on
+// Err.
 func (s *AppHttpServer) start() error {
 	r := s.router
 	cfg := s.cfg
 	log.Info().Msg("Starting up server...")
 
 	s.server = &http.Server{
-		Addr:         cfg.Listen.Addr(),
-		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              cfg.Listen.Addr(),
+		Handler:           r,
+		ReadTimeout:       cfg.Http.ReadTimeout,
+		ReadHeaderTimeout: cfg.Http.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Http.WriteTimeout,
+		IdleTimeout:       cfg.Http.IdleTimeout,
+		MaxHeaderBytes:    int(cfg.Http.MaxHeaderBytes),
 	}
 
-	// This starts the server in a goroutine from main
-	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return err
+	// HTTP/2 without TLS, for internal traffic from proxies and services
+	if cfg.Listen.H2C {
+		var protocols http.Protocols
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		s.server.Protocols = &protocols
+	}
+
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", s.server.Addr, err)
+	}
+
+	if s.certs != nil {
+		s.server.TLSConfig = s.certs.TLSConfig()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopWatch = cancel
+		go s.certs.Watch(ctx, cfg.Listen.TLS.ReloadInterval)
 	}
 
+	go func() {
+		var err error
+		if s.certs != nil {
+			err = s.server.ServeTLS(ln, "", "")
+		} else {
+			err = s.server.Serve(ln)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.errs <- err
+		}
+	}()
+
 	return nil
 }
 
-// stop gracefully shuts down the server
+// Err reports the failure of a started server
+func (s *AppHttpServer) Err() <-chan error {
+	return s.errs
+}
+
+// stop stops accepting connections and waits for in-flight requests
 func (s *AppHttpServer) stop(ctx context.Context) error {
 	if s.server == nil {
 		return nil
 	}
+	if s.stopWatch != nil {
+		s.stopWatch()
+	}
 	return s.server.Shutdown(ctx)
 }
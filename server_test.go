@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LexiconIndonesia/go-http-service-template/common/db"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging"
+	"github.com/LexiconIndonesia/go-http-service-template/common/messaging/natstest"
+	"github.com/LexiconIndonesia/go-http-service-template/common/module"
+	"github.com/LexiconIndonesia/go-http-service-template/common/pagination"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TestOpenAPIValidation runs requests through the embedded spec, so responses
+// of the handlers that drift from their annotations fail
+func TestOpenAPIValidation(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.OpenAPI.ValidateRequests = true
+	cfg.OpenAPI.ValidateResponses = true
+
+	container := &module.Container{Store: db.NewFake(), Cursors: pagination.NewSigner(nil)}
+	server, err := NewAppHttpServer(cfg, container)
+	if err != nil {
+		t.Fatalf("NewAppHttpServer() error = %v", err)
+	}
+	if err := server.setupRoute(); err != nil {
+		t.Fatalf("setupRoute() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"create user", http.MethodPost, "/v1/users", `{"email":"jane@example.com","first_name":"Jane","last_name":"Doe","password":"Password123!"}`, http.StatusCreated},
+		{"create user without email", http.MethodPost, "/v1/users", `{"first_name":"Jane","last_name":"Doe","password":"Password123!"}`, http.StatusBadRequest},
+		{"create user with wrong type", http.MethodPost, "/v1/users", `{"email":"jane@example.com","first_name":1,"last_name":"Doe","password":"Password123!"}`, http.StatusBadRequest},
+		{"list users", http.MethodGet, "/v1/users", "", http.StatusOK},
+		{"hello", http.MethodGet, "/v1/module", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("Handler returned wrong status code: got %v want %v (%s)", status, tt.expectedStatus, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestNatsService calls the users module over NATS, through the same
+// handlers and route group middlewares as the HTTP routes
+func TestNatsService(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.OpenAPI.ValidateRequests = true
+	container := &module.Container{Store: db.NewFake(), Nats: natstest.NewClient(t), Cursors: pagination.NewSigner(nil)}
+	server, err := NewAppHttpServer(cfg, container)
+	if err != nil {
+		t.Fatalf("NewAppHttpServer() error = %v", err)
+	}
+	if err := server.setupRoute(); err != nil {
+		t.Fatalf("setupRoute() error = %v", err)
+	}
+	svc, err := server.natsService()
+	if err != nil {
+		t.Fatalf("natsService() error = %v", err)
+	}
+	t.Cleanup(func() { _ = svc.Stop() })
+
+	for _, e := range svc.Info().Endpoints {
+		if e.Name == "users_create" && e.Metadata["request_schema"] == "" {
+			t.Errorf("Endpoint %s has no request schema: %v", e.Name, e.Metadata)
+		}
+	}
+
+	conn := container.Nats.GetConn()
+	reply, err := conn.Request("api.users.create", []byte(`{"email":"jane@example.com","first_name":"Jane","last_name":"Doe","password":"Password123!"}`), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if perr := messaging.ReplyError(reply); perr != nil {
+		t.Fatalf("Create replied an error: %v", perr)
+	}
+	var created struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(reply.Data, &created); err != nil || created.Data.ID == "" {
+		t.Fatalf("Failed to decode created user %s: %v", reply.Data, err)
+	}
+	if reply.Header.Get("ETag") == "" {
+		t.Error("Create reply has no ETag header")
+	}
+
+	reply, err = conn.Request("api.users.get."+created.Data.ID, nil, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if perr := messaging.ReplyError(reply); perr != nil {
+		t.Fatalf("Get replied an error: %v", perr)
+	}
+
+	reply, err = conn.Request("api.users.get.not-a-uuid", nil, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if perr := messaging.ReplyError(reply); perr == nil || perr.Status != http.StatusBadRequest {
+		t.Errorf("Get with invalid ID replied %v, want status %v", perr, http.StatusBadRequest)
+	}
+
+	// Requests are validated against the spec like over HTTP
+	reply, err = conn.Request("api.users.create", []byte(`{"email":"jane@example.com","first_name":1,"last_name":"Doe","password":"Password123!"}`), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	perr := messaging.ReplyError(reply)
+	if perr == nil || perr.Status != http.StatusBadRequest || len(perr.Errors) == 0 || perr.Errors[0].Field != "body.first_name" {
+		t.Errorf("Create with invalid body replied %v, want the OpenAPI violation of body.first_name", perr)
+	}
+
+	// Callers cannot replay the responses of each other with the same
+	// Idempotency-Key, each request runs
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		msg := nats.NewMsg("api.users.create")
+		msg.Header.Set("Idempotency-Key", "shared-key")
+		msg.Data = []byte(`{"email":"` + email + `","first_name":"Jane","last_name":"Doe","password":"Password123!"}`)
+		reply, err := conn.RequestMsg(msg, 5*time.Second)
+		if err != nil {
+			t.Fatalf("RequestMsg() error = %v", err)
+		}
+		if perr := messaging.ReplyError(reply); perr != nil {
+			t.Fatalf("Create of %s replied an error: %v", email, perr)
+		}
+		if reply.Header.Get("Idempotent-Replayed") != "" || !strings.Contains(string(reply.Data), email) {
+			t.Errorf("Create of %s replied another response: %s", email, reply.Data)
+		}
+	}
+}
+
+// TestCompressedETag checks that compressed responses have their own entity
+// tag, which conditional requests still match
+func TestCompressedETag(t *testing.T) {
+	container := &module.Container{Store: db.NewFake(), Cursors: pagination.NewSigner(nil)}
+	server, err := NewAppHttpServer(defaultConfig(), container)
+	if err != nil {
+		t.Fatalf("NewAppHttpServer() error = %v", err)
+	}
+	if err := server.setupRoute(); err != nil {
+		t.Fatalf("setupRoute() error = %v", err)
+	}
+
+	// Enough users for the list to be compressed
+	for i := range 20 {
+		body := fmt.Sprintf(`{"email":"user%d@example.com","first_name":"User","last_name":"Number %d","password":"Password123!"}`, i, i)
+		req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Handler returned wrong status code: got %v want %v (%s)", rr.Code, http.StatusCreated, rr.Body.String())
+		}
+	}
+
+	list := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users?limit=20", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	plain := list(nil)
+	compressed := list(map[string]string{"Accept-Encoding": "gzip"})
+	if compressed.Code != http.StatusOK || compressed.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzip response, got %v with encoding %q", compressed.Code, compressed.Header().Get("Content-Encoding"))
+	}
+
+	etag := compressed.Header().Get("ETag")
+	if etag == plain.Header().Get("ETag") || !strings.HasSuffix(etag, `-gzip"`) {
+		t.Errorf("Compressed response has ETag %s, want a gzip variant of %s", etag, plain.Header().Get("ETag"))
+	}
+
+	for _, encoding := range []string{"gzip", ""} {
+		rr := list(map[string]string{"Accept-Encoding": encoding, "If-None-Match": etag})
+		if rr.Code != http.StatusNotModified {
+			t.Errorf("Handler returned wrong status code with Accept-Encoding %q: got %v want %v", encoding, rr.Code, http.StatusNotModified)
+		}
+	}
+}